@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// allowedPrefixes parses the repeatable allow-cidr flag. Bare addresses are
+// accepted as single-address ranges.
+func allowedPrefixes(v *viper.Viper) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range v.GetStringSlice("allow-cidr") {
+		value = strings.TrimSpace(value)
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow-cidr %q, expected a CIDR range or an address", value)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// clientAddr returns the address of the client of r. Behind a proxy that is
+// the last X-Forwarded-For address, appended by the proxy in front of medic,
+// since earlier ones can be forged by the client.
+func clientAddr(r *http.Request, trustProxyHeaders bool) (netip.Addr, bool) {
+	if trustProxyHeaders {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) != 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[len(hops)-1]))
+			return addr.Unmap(), err == nil
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr.Unmap(), err == nil
+}
+
+// restrictAccess rejects requests from outside allow-cidr with 403 and
+// requests to the protected paths without the status token with 401. The
+// admin token is accepted as the status token too.
+func restrictAccess(next http.Handler) http.Handler {
+	// validateConfig has already checked the ranges
+	prefixes, _ := allowedPrefixes(settings())
+	trustProxyHeaders := settings().GetBool("trust-proxy-headers")
+	statusToken := settings().GetString("status-token")
+	if len(prefixes) == 0 && statusToken == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(prefixes) != 0 {
+			addr, ok := clientAddr(r, trustProxyHeaders)
+			if !ok || !containsAddr(prefixes, addr) {
+				rejectedRequestsCounter.WithLabelValues("address").Inc()
+				log.Debug().Str("remote_addr", r.RemoteAddr).Str("path", r.URL.Path).Msg("Rejected a request from outside the allowed ranges")
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
+		if statusToken != "" && isProtected(r.URL.Path) && !hasToken(r, statusToken, settings().GetString("admin-token")) {
+			rejectedRequestsCounter.WithLabelValues("token").Inc()
+			log.Debug().Str("remote_addr", r.RemoteAddr).Str("path", r.URL.Path).Msg("Rejected a request without the status token")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// containsAddr reports whether addr is in any of prefixes
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isProtected reports whether path needs the status token when one is set.
+// The probes stay open since kubelet cannot send a token.
+func isProtected(path string) bool {
+	return path == "/status" || path == "/events" || strings.HasPrefix(path, "/events/") || path == "/config" || path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
+
+// hasToken reports whether r carries one of the non-empty tokens as a bearer
+// token
+func hasToken(r *http.Request, tokens ...string) bool {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	for _, token := range tokens {
+		if token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+)
+
+// beaconClientDetector caches the beacon client detected behind cl-url, the
+// way nodeClient caches the execution client
+type beaconClientDetector struct {
+	mu       sync.RWMutex
+	url      string
+	info     clients.ClientInfo
+	redetect chan struct{}
+}
+
+// beaconClient is the detector of the beacon node shared by all nodes
+var beaconClient = &beaconClientDetector{redetect: make(chan struct{}, 1)}
+
+// clientInfo returns the cached client info, with type Unknown until the
+// beacon client has been detected
+func (d *beaconClientDetector) clientInfo() clients.ClientInfo {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.info.Type == "" {
+		return clients.ClientInfo{Type: "Unknown"}
+	}
+	return d.info
+}
+
+// detect refreshes the cached client type of the beacon node at cl-url,
+// falling back to Unknown
+func (d *beaconClientDetector) detect(ctx context.Context) error {
+	url := settings().GetString("cl-url")
+	if url == "" {
+		return nil
+	}
+
+	start := time.Now()
+	info, err := clients.DetectBeaconClient(ctx, url)
+	observeRPC(ctx, "beacon_node_version", start)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// A reload may point cl-url at another beacon node
+	if url != d.url {
+		d.url, d.info = url, clients.ClientInfo{}
+	}
+	if err != nil {
+		if d.info.Type == "" {
+			d.info.Type = "Unknown"
+		}
+		return err
+	}
+
+	if info != d.info {
+		log.Info().
+			Str("cl_client_type", info.Type).
+			Str("cl_client_version", info.Version).
+			Str("raw_cl_client_version", info.Raw).
+			Msg("Detected beacon client type")
+		recordBeaconClientInfo(info.Type, info.Version)
+	}
+	d.info = info
+
+	return nil
+}
+
+// requestDetection asks the background detector to refresh the client type,
+// e.g. after a request error that suggests the beacon node restarted
+func (d *beaconClientDetector) requestDetection() {
+	select {
+	case d.redetect <- struct{}{}:
+	default:
+	}
+}
+
+// start detects the beacon client once and then refreshes it every interval
+// in the background, retrying sooner while detection fails
+func (d *beaconClientDetector) start(interval time.Duration) {
+	detect := func() time.Duration {
+		ctx, cancel := context.WithTimeout(rpcContext(context.Background()), settings().GetDuration("check-timeout"))
+		defer cancel()
+
+		if err := d.detect(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to detect the beacon client type")
+			return min(interval, clientDetectRetryInterval)
+		}
+		return interval
+	}
+
+	next := detect()
+	go func() {
+		for {
+			select {
+			case <-time.After(next):
+			case <-d.redetect:
+			}
+			next = detect()
+		}
+	}()
+}
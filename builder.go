@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+)
+
+var builderHealthyGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "medic_builder_healthy",
+	Help: "Whether mev-boost at mev-boost-url answered its status endpoint (1) or not (0)",
+})
+
+// measureBuilder calls the status endpoint of mev-boost at url
+func measureBuilder(ctx context.Context, url string) *health.Builder {
+	m := &health.Builder{}
+
+	start := time.Now()
+	m.Err = clients.BuilderStatus(ctx, url)
+	observeRPC(ctx, "builder_status", start)
+	if m.Err != nil {
+		log.Error().Err(clients.WithoutURL(m.Err)).Msg("Failed to retrieve the mev-boost status")
+	}
+	return m
+}
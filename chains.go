@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// chainMaxBlockAge holds a default max-block-age for well-known chains,
+// scaled to their block times
+var chainMaxBlockAge = map[uint64]time.Duration{
+	1:        30 * time.Second, // Ethereum mainnet
+	100:      15 * time.Second, // Gnosis
+	10:       6 * time.Second,  // OP Mainnet
+	8453:     6 * time.Second,  // Base
+	11155111: 30 * time.Second, // Sepolia
+	17000:    30 * time.Second, // Holesky
+}
+
+// unknownChainMaxBlockAge is the default for chains missing from the table,
+// loose enough for slow block times
+const unknownChainMaxBlockAge = 60 * time.Second
+
+// Setting is a resolved configuration value along with where it came from
+type Setting struct {
+	Value  time.Duration
+	Source string
+}
+
+// MarshalJSON reports the value in whole seconds, as the integer flag it
+// replaced did
+func (s Setting) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Value  int    `json:"value"`
+		Source string `json:"source"`
+	}{int(s.Value.Seconds()), s.Source})
+}
+
+var (
+	settingsMu          sync.RWMutex
+	maxBlockAgeOverride *Setting
+)
+
+// durationSetting returns the duration flag key when set, falling back to the
+// deprecated whole-seconds flag. ok reports whether either was set.
+func durationSetting(v *viper.Viper, key, secondsKey string) (value time.Duration, ok bool) {
+	switch {
+	case v.IsSet(key):
+		return v.GetDuration(key), true
+	case v.IsSet(secondsKey):
+		return time.Duration(v.GetInt(secondsKey)) * time.Second, true
+	}
+	return v.GetDuration(key), false
+}
+
+// maxBlockAge returns the max-block-age in effect. An explicit flag always
+// wins over the chain default, which a profile may tighten.
+func maxBlockAge() Setting {
+	value, ok := durationSetting(settings(), "max-block-age", "max-seconds-behind")
+	if ok {
+		return Setting{Value: value, Source: "flag"}
+	}
+
+	setting := Setting{Value: value, Source: "default"}
+	settingsMu.RLock()
+	if maxBlockAgeOverride != nil {
+		setting = *maxBlockAgeOverride
+	}
+	settingsMu.RUnlock()
+
+	// A profile only tightens the default
+	if p, ok := activeProfile(); ok && p.maxBlockAge > 0 && p.maxBlockAge < setting.Value {
+		return Setting{Value: p.maxBlockAge, Source: "profile"}
+	}
+	return setting
+}
+
+// resolveChainDefaults looks up the chain ID of node, or the block time of
+// the op-node of rollup-url, and picks the max-block-age default for it
+// unless the flag was set explicitly
+func resolveChainDefaults(ctx context.Context, node *nodeClient) {
+	if setting := maxBlockAge(); setting.Source == "flag" {
+		log.Info().Dur("max_block_age", setting.Value).Str("source", "flag").Msg("Using max-block-age")
+		return
+	}
+
+	// OP Stack chains are covered by their block time
+	if rollupURL := settings().GetString("rollup-url"); rollupURL != "" {
+		rollupCtx, cancel := context.WithTimeout(rpcContext(ctx), settings().GetDuration("check-timeout"))
+		config, err := rollupConfigInfo(rollupCtx, rollupURL)
+		cancel()
+		if err == nil {
+			value := time.Duration(config.BlockTime*rollupBlocksBehind) * time.Second
+			settingsMu.Lock()
+			maxBlockAgeOverride = &Setting{Value: value, Source: "rollup-default"}
+			settingsMu.Unlock()
+
+			log.Info().
+				Uint64("block_time", config.BlockTime).
+				Dur("max_block_age", value).
+				Str("source", "rollup-default").
+				Msg("Using max-block-age")
+			return
+		}
+		log.Warn().Err(clients.WithoutURL(err)).Msg("Failed to retrieve the rollup config, using the chain default max-block-age")
+	}
+
+	ctx, cancel := context.WithTimeout(node.context(ctx), settings().GetDuration("check-timeout"))
+	defer cancel()
+
+	chainID, err := clients.ChainID(ctx, node.url)
+	if err != nil {
+		log.Warn().Err(err).Dur("max_block_age", maxBlockAge().Value).Msg("Failed to retrieve the chain ID, using the default max-block-age")
+		return
+	}
+
+	value, ok := chainMaxBlockAge[chainID]
+	if !ok {
+		value = unknownChainMaxBlockAge
+	}
+
+	settingsMu.Lock()
+	maxBlockAgeOverride = &Setting{Value: value, Source: "chain-default"}
+	settingsMu.Unlock()
+
+	log.Info().
+		Uint64("chain_id", chainID).
+		Dur("max_block_age", value).
+		Str("source", "chain-default").
+		Msg("Using max-block-age")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rarecrumb/medic/health"
+)
+
+// checkRegistry lists every check in evaluation order
+var checkRegistry = health.DefaultChecks()
+
+var (
+	checksMu sync.RWMutex
+	// enabledChecks are the checks selected with --checks, all by default
+	enabledChecks, _ = health.NewRegistry(checkRegistry...)
+)
+
+// activeChecks returns the checks currently selected
+func activeChecks() *health.Registry {
+	checksMu.RLock()
+	defer checksMu.RUnlock()
+	return enabledChecks
+}
+
+// setEnabledChecks replaces the selected checks, e.g. on a config reload
+func setEnabledChecks(checks *health.Registry) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	enabledChecks = checks
+}
+
+// checkNames returns the names of the given checks
+func checkNames(checks []health.Check) []string {
+	names := make([]string, len(checks))
+	for i, check := range checks {
+		names[i] = check.Name()
+	}
+	return names
+}
+
+// selectChecks resolves a comma-separated list of check names, returning
+// every registered check for an empty list
+func selectChecks(spec string) (*health.Registry, error) {
+	if strings.TrimSpace(spec) == "" {
+		return health.NewRegistry(checkRegistry...)
+	}
+
+	selected := map[string]bool{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, check := range checkRegistry {
+			if check.Name() == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown check %q, valid checks are %s", name, strings.Join(checkNames(checkRegistry), ", "))
+		}
+		selected[name] = true
+	}
+
+	// Keep the registry order so results do not depend on the flag order
+	var checks []health.Check
+	for _, check := range checkRegistry {
+		if selected[check.Name()] {
+			checks = append(checks, check)
+		}
+	}
+	return health.NewRegistry(checks...)
+}
+
+// checkEnabled reports whether the named check was selected, so that the
+// measurements only it needs can be skipped
+func checkEnabled(name string) bool {
+	_, ok := activeChecks().Lookup(name)
+	return ok
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/clients/clienttest"
+	"github.com/rarecrumb/medic/health"
+)
+
+// An eth_syncing error on a staged-sync client used to dereference the
+// missing sync status and crash the poller
+func TestEvaluateErigonSyncingError(t *testing.T) {
+	node := clienttest.NewServer()
+	defer node.Close()
+	node.Handle("web3_clientVersion", "erigon/2.60.0/linux-amd64/go1.21.5")
+	node.HandleError("eth_syncing", -32000, "internal error")
+
+	client := newNodeClient("", node.URL)
+	client.forceClientType("Erigon")
+
+	ctx := context.Background()
+	result := evaluate(ctx, measure(ctx, client), health.Thresholds{MaxBlockAge: 30 * time.Second, MaxStageDistance: 32})
+
+	check := result.Checks["syncing"]
+	if check.OK || check.Reason != "rpc_error" {
+		t.Errorf("syncing = %+v, want a failed check with reason rpc_error", check)
+	}
+	if result.Healthy {
+		t.Error("node with a failing eth_syncing reported healthy")
+	}
+}
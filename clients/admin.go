@@ -0,0 +1,42 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PeerBreakdown splits the peers reported by admin_peers into all connected
+// peers and those that completed the eth handshake
+type PeerBreakdown struct {
+	Total  int `json:"total"`
+	Useful int `json:"useful"`
+}
+
+// AdminPeers calls admin_peers and counts the peers that serve the eth
+// protocol. Clients only complete the eth handshake with peers on the same
+// network ID, genesis and fork ID, and report peers that are still in the
+// handshake or that failed it with a string or without an eth entry.
+func AdminPeers(ctx context.Context, url string) (*PeerBreakdown, error) {
+	rpcResponse, err := call(ctx, url, "admin_peers")
+	if err != nil {
+		return nil, err
+	}
+
+	var peers []struct {
+		Protocols map[string]json.RawMessage `json:"protocols"`
+	}
+	if err := json.Unmarshal(rpcResponse.Result, &peers); err != nil {
+		return nil, fmt.Errorf("unexpected admin_peers result: %w", err)
+	}
+
+	breakdown := &PeerBreakdown{Total: len(peers)}
+	for _, peer := range peers {
+		var eth map[string]json.RawMessage
+		if err := json.Unmarshal(peer.Protocols["eth"], &eth); err != nil || eth == nil {
+			continue
+		}
+		breakdown.Useful++
+	}
+	return breakdown, nil
+}
@@ -0,0 +1,57 @@
+package clients
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+)
+
+// StripCredentials removes the user info from rawURL, so the returned URL can
+// be logged and exposed safely, and returns it separately. Set it as the
+// Credentials of the options the URL is requested with.
+func StripCredentials(rawURL string) (string, *url.Userinfo) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil || u.Host == "" {
+		return rawURL, nil
+	}
+
+	user := u.User
+	u.User = nil
+	return u.String(), user
+}
+
+// BasicAuth returns the Authorization header value of basic auth
+func BasicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// basicAuth returns the Authorization header value for user
+func basicAuth(user *url.Userinfo) string {
+	password, _ := user.Password()
+	return BasicAuth(user.Username(), password)
+}
+
+// BasicAuthTransport adds the Credentials of the options of the request
+// context to requests that carry no Authorization header yet
+type BasicAuthTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *BasicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	user := optionsFor(req.Context()).Credentials
+	if user == nil || req.Header.Get("Authorization") != "" {
+		return t.base().RoundTrip(req)
+	}
+
+	// Requests must not be modified by a RoundTripper, so authenticate a copy
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", basicAuth(user))
+	return t.base().RoundTrip(req)
+}
+
+func (t *BasicAuthTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
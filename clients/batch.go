@@ -0,0 +1,151 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ErrBatchUnsupported is returned when the node or a proxy in front of it
+// does not answer a JSON-RPC batch with a batch response
+var ErrBatchUnsupported = errors.New("json-rpc batch not supported")
+
+// BatchRequest is a single call within a JSON-RPC batch
+type BatchRequest struct {
+	Method string
+	Params []interface{}
+}
+
+// BatchCall sends the requests as a single JSON-RPC batch and returns the
+// responses in request order
+func BatchCall(ctx context.Context, url string, requests []BatchRequest) ([]RPCResponse, error) {
+	if IsPersistent(url) {
+		return persistentBatch(ctx, url, requests)
+	}
+
+	payload := make([]map[string]interface{}, len(requests))
+	for i, request := range requests {
+		payload[i] = newRequest(i+1, request.Method, request.Params)
+	}
+
+	body, err := post(ctx, url, payload)
+	if err != nil {
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) {
+			switch statusErr.StatusCode {
+			case http.StatusBadRequest, http.StatusMethodNotAllowed,
+				http.StatusRequestEntityTooLarge, http.StatusNotImplemented:
+				return nil, fmt.Errorf("%w: %w", ErrBatchUnsupported, err)
+			}
+		}
+		return nil, err
+	}
+
+	var responses []RPCResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBatchUnsupported, err)
+	}
+
+	// Responses may arrive in any order, match them by ID
+	ordered := make([]RPCResponse, len(requests))
+	for _, response := range responses {
+		if response.ID < 1 || response.ID > len(requests) {
+			return nil, fmt.Errorf("%w: unexpected response id %d", ErrBatchUnsupported, response.ID)
+		}
+		ordered[response.ID-1] = response
+	}
+
+	return ordered, nil
+}
+
+// ExecutionStatus holds the execution client measurements fetched in one
+// batch. Errors is keyed by method and records calls that failed in the batch.
+type ExecutionStatus struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	BlockTime   uint64
+	PeerCount   uint64
+	SyncStatus  *SyncStatus
+	Errors      map[string]error
+}
+
+// ExecutionQuery selects the calls made by FetchExecutionStatus
+type ExecutionQuery struct {
+	Block   bool
+	Peers   bool
+	Syncing bool
+}
+
+// FetchExecutionStatus fetches the latest header, peer count and sync status
+// selected by query in a single JSON-RPC batch round trip
+func FetchExecutionStatus(ctx context.Context, url string, query ExecutionQuery) (*ExecutionStatus, error) {
+	var requests []BatchRequest
+	if query.Peers {
+		requests = append(requests, BatchRequest{Method: "net_peerCount"})
+	}
+	if query.Syncing {
+		requests = append(requests, BatchRequest{Method: "eth_syncing"})
+	}
+	if query.Block {
+		requests = append(requests, BatchRequest{Method: "eth_getBlockByNumber", Params: []interface{}{"latest", false}})
+	}
+
+	responses, err := BatchCall(ctx, url, requests)
+	if err != nil {
+		return nil, err
+	}
+	byMethod := make(map[string]RPCResponse, len(requests))
+	for i, request := range requests {
+		byMethod[request.Method] = responses[i]
+	}
+
+	status := &ExecutionStatus{Errors: map[string]error{}}
+	failed := func(method string) bool {
+		switch response := byMethod[method]; {
+		case response.Error != nil:
+			status.Errors[method] = fmt.Errorf("%s: %w", method, response.Error)
+		case len(response.Result) == 0:
+			status.Errors[method] = fmt.Errorf("%s: %w", method, ErrEmptyResult)
+		default:
+			return false
+		}
+		return true
+	}
+
+	if query.Block && !failed("eth_getBlockByNumber") {
+		var header struct {
+			Number    hexutil.Uint64 `json:"number"`
+			Hash      common.Hash    `json:"hash"`
+			Timestamp hexutil.Uint64 `json:"timestamp"`
+		}
+		if err := json.Unmarshal(byMethod["eth_getBlockByNumber"].Result, &header); err != nil {
+			status.Errors["eth_getBlockByNumber"] = err
+		} else {
+			status.BlockNumber = uint64(header.Number)
+			status.BlockHash = header.Hash
+			status.BlockTime = uint64(header.Timestamp)
+		}
+	}
+
+	if query.Peers && !failed("net_peerCount") {
+		var peers hexutil.Uint64
+		if err := json.Unmarshal(byMethod["net_peerCount"].Result, &peers); err != nil {
+			status.Errors["net_peerCount"] = err
+		} else {
+			status.PeerCount = uint64(peers)
+		}
+	}
+
+	if query.Syncing && !failed("eth_syncing") {
+		if status.SyncStatus, err = parseSyncStatus(byMethod["eth_syncing"].Result); err != nil {
+			status.Errors["eth_syncing"] = err
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,173 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// BeaconSyncing is the data returned by /eth/v1/node/syncing. IsOptimistic
+// and ELOffline are nil on older beacon APIs that do not report them.
+type BeaconSyncing struct {
+	HeadSlot     uint64 `json:"head_slot,string"`
+	SyncDistance uint64 `json:"sync_distance,string"`
+	IsSyncing    bool   `json:"is_syncing"`
+	IsOptimistic *bool  `json:"is_optimistic"`
+	ELOffline    *bool  `json:"el_offline"`
+}
+
+// BeaconPeerCount is the data returned by /eth/v1/node/peer_count
+type BeaconPeerCount struct {
+	Disconnected  uint64 `json:"disconnected,string"`
+	Connecting    uint64 `json:"connecting,string"`
+	Connected     uint64 `json:"connected,string"`
+	Disconnecting uint64 `json:"disconnecting,string"`
+}
+
+// beaconGet performs a GET against the beacon node API
+func beaconGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	return clientFor(ctx).Do(req)
+}
+
+// beaconData decodes the data envelope of a beacon API response into out
+func beaconData(ctx context.Context, url string, out interface{}) error {
+	resp, err := beaconGet(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: out}
+	return json.NewDecoder(resp.Body).Decode(&envelope)
+}
+
+// BeaconHealth returns the status code of /eth/v1/node/health: 200 when the
+// node is ready, 206 while syncing and 503 when it is not initialized
+func BeaconHealth(ctx context.Context, url string) (int, error) {
+	resp, err := beaconGet(ctx, url+"/eth/v1/node/health")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// BeaconVersion returns the version string reported by /eth/v1/node/version
+func BeaconVersion(ctx context.Context, url string) (string, error) {
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := beaconData(ctx, url+"/eth/v1/node/version", &version); err != nil {
+		return "", err
+	}
+	if version.Version == "" {
+		return "", fmt.Errorf("/eth/v1/node/version: %w", ErrEmptyResult)
+	}
+
+	return version.Version, nil
+}
+
+// DetectBeaconClient identifies the beacon client by calling
+// /eth/v1/node/version
+func DetectBeaconClient(ctx context.Context, url string) (ClientInfo, error) {
+	version, err := BeaconVersion(ctx, url)
+	if err != nil {
+		return ClientInfo{}, err
+	}
+
+	return ParseBeaconVersion(version), nil
+}
+
+// BeaconSyncStatus returns the sync status reported by the beacon node
+func BeaconSyncStatus(ctx context.Context, url string) (*BeaconSyncing, error) {
+	var syncing BeaconSyncing
+	if err := beaconData(ctx, url+"/eth/v1/node/syncing", &syncing); err != nil {
+		return nil, err
+	}
+
+	return &syncing, nil
+}
+
+// BeaconPeers returns the peer counts reported by the beacon node
+func BeaconPeers(ctx context.Context, url string) (*BeaconPeerCount, error) {
+	var peers BeaconPeerCount
+	if err := beaconData(ctx, url+"/eth/v1/node/peer_count", &peers); err != nil {
+		return nil, err
+	}
+
+	return &peers, nil
+}
+
+// BeaconClock is the slot timing of the beacon chain
+type BeaconClock struct {
+	GenesisTime    uint64
+	SecondsPerSlot uint64
+	SlotsPerEpoch  uint64
+}
+
+// SlotStart returns the time at which slot starts
+func (c BeaconClock) SlotStart(slot uint64) time.Time {
+	return time.Unix(int64(c.GenesisTime+slot*c.SecondsPerSlot), 0)
+}
+
+// EpochStart returns the time at which epoch starts
+func (c BeaconClock) EpochStart(epoch uint64) time.Time {
+	return c.SlotStart(epoch * c.SlotsPerEpoch)
+}
+
+// BeaconClockInfo returns the genesis time and slot duration of the beacon
+// chain, which do not change for a running node
+func BeaconClockInfo(ctx context.Context, url string) (*BeaconClock, error) {
+	var genesis struct {
+		GenesisTime uint64 `json:"genesis_time,string"`
+	}
+	if err := beaconData(ctx, url+"/eth/v1/beacon/genesis", &genesis); err != nil {
+		return nil, err
+	}
+
+	var spec struct {
+		SecondsPerSlot uint64 `json:"SECONDS_PER_SLOT,string"`
+		SlotsPerEpoch  uint64 `json:"SLOTS_PER_EPOCH,string"`
+	}
+	if err := beaconData(ctx, url+"/eth/v1/config/spec", &spec); err != nil {
+		return nil, err
+	}
+	if spec.SecondsPerSlot == 0 {
+		return nil, fmt.Errorf("beacon spec has no SECONDS_PER_SLOT")
+	}
+	if spec.SlotsPerEpoch == 0 {
+		return nil, fmt.Errorf("beacon spec has no SLOTS_PER_EPOCH")
+	}
+
+	return &BeaconClock{GenesisTime: genesis.GenesisTime, SecondsPerSlot: spec.SecondsPerSlot, SlotsPerEpoch: spec.SlotsPerEpoch}, nil
+}
+
+// BeaconFinalizedEpoch returns the epoch of the finalized checkpoint of the
+// head state
+func BeaconFinalizedEpoch(ctx context.Context, url string) (uint64, error) {
+	var checkpoints struct {
+		Finalized struct {
+			Epoch uint64 `json:"epoch,string"`
+		} `json:"finalized"`
+	}
+	if err := beaconData(ctx, url+"/eth/v1/beacon/states/head/finality_checkpoints", &checkpoints); err != nil {
+		return 0, err
+	}
+
+	return checkpoints.Finalized.Epoch, nil
+}
@@ -0,0 +1,58 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// BesuHealth is the response of the Besu readiness and liveness endpoints
+type BesuHealth struct {
+	// StatusCode is the HTTP status of the response. Besu answers 503 with a
+	// DOWN status when a check fails.
+	StatusCode int `json:"-"`
+
+	Status string `json:"status"`
+}
+
+// Up reports whether Besu considers the check passed
+func (h *BesuHealth) Up() bool {
+	return h.Status == "UP"
+}
+
+// BesuReadiness queries /readiness, which fails when the node has fewer than
+// minPeers peers or is more than maxBlocksBehind blocks behind the chain head
+func BesuReadiness(ctx context.Context, baseURL string, minPeers, maxBlocksBehind int) (*BesuHealth, error) {
+	query := url.Values{}
+	query.Set("minPeers", strconv.Itoa(minPeers))
+	query.Set("maxBlocksBehind", strconv.Itoa(maxBlocksBehind))
+	return besuHealth(ctx, baseURL+"/readiness?"+query.Encode())
+}
+
+// BesuLiveness queries /liveness, which fails when the node is not running
+func BesuLiveness(ctx context.Context, baseURL string) (*BesuHealth, error) {
+	return besuHealth(ctx, baseURL+"/liveness")
+}
+
+func besuHealth(ctx context.Context, endpoint string) (*BesuHealth, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := clientFor(ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var health BesuHealth
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, fmt.Errorf("unexpected status %d from %s: %w", resp.StatusCode, req.URL, err)
+	}
+	health.StatusCode = resp.StatusCode
+
+	return &health, nil
+}
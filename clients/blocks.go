@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ErrUnknownBlock is matched by errors returned when the node does not know
+// the requested block, e.g. the finalized tag on a pre-merge chain
+var ErrUnknownBlock = errors.New("unknown block")
+
+// BlockHeader holds the header fields medic needs from eth_getBlockByNumber
+type BlockHeader struct {
+	Number    uint64
+	Hash      common.Hash
+	Timestamp uint64
+}
+
+// BlockByTag returns the header of the block with the given tag or number,
+// such as latest, safe or finalized
+func BlockByTag(ctx context.Context, url string, tag string) (*BlockHeader, error) {
+	rpcResponse, err := call(ctx, url, "eth_getBlockByNumber", tag, false)
+	if err != nil {
+		// Clients word this differently, e.g. "unknown block" or "finalized
+		// block not found"
+		message := strings.ToLower(err.Error())
+		if strings.Contains(message, "unknown block") || strings.Contains(message, "not found") {
+			return nil, fmt.Errorf("%s block: %w: %w", tag, ErrUnknownBlock, err)
+		}
+		return nil, err
+	}
+	if len(rpcResponse.Result) == 0 || string(rpcResponse.Result) == "null" {
+		return nil, fmt.Errorf("%s block: %w", tag, ErrUnknownBlock)
+	}
+
+	var header struct {
+		Number    hexutil.Uint64 `json:"number"`
+		Hash      common.Hash    `json:"hash"`
+		Timestamp hexutil.Uint64 `json:"timestamp"`
+	}
+	if err := json.Unmarshal(rpcResponse.Result, &header); err != nil {
+		return nil, fmt.Errorf("malformed %s block: %w", tag, err)
+	}
+
+	return &BlockHeader{Number: uint64(header.Number), Hash: header.Hash, Timestamp: uint64(header.Timestamp)}, nil
+}
@@ -0,0 +1,33 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+)
+
+// builderClient is used for mev-boost, which is not the node: it gets none of
+// the node's headers, and no retries so that a hanging or refusing builder is
+// reported as such
+var builderClient = &http.Client{}
+
+// BuilderStatus calls /eth/v1/builder/status of mev-boost, which answers 200
+// while at least one relay is reachable. Other status codes are returned as
+// an HTTPStatusError.
+func BuilderStatus(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/eth/v1/builder/status", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := builderClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package clients
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ClientInfo describes the client behind an endpoint, as parsed from the
+// web3_clientVersion string of an execution client or the /eth/v1/node/version
+// string of a beacon node
+type ClientInfo struct {
+	Type    string `json:"type"`
+	Version string `json:"version,omitempty"`
+	Raw     string `json:"raw,omitempty"`
+}
+
+// clientName maps the lowercased name at the start of a version string to the
+// client type reported by medic
+type clientName struct {
+	prefix string
+	name   string
+}
+
+// clientNames maps the lowercased name at the start of a clientVersion string
+// to the client type reported by medic. Forks that keep the behavior of their
+// upstream client map to the upstream type.
+var clientNames = []clientName{
+	{"geth", "Geth"},
+	{"besu", "Besu"},
+	{"nethermind", "Nethermind"},
+	{"erigon", "Erigon"},
+	{"reth", "Reth"},
+	{"nimbus-eth1", "Nimbus-eth1"},
+	{"ethereumjs", "EthereumJS"},
+	{"nitro", "Nitro"},
+	{"bor", "Geth"},
+}
+
+// beaconClientNames maps the lowercased name at the start of a beacon node
+// version string to the client type reported by medic
+var beaconClientNames = []clientName{
+	{"lighthouse", "Lighthouse"},
+	{"prysm", "Prysm"},
+	{"teku", "Teku"},
+	{"nimbus", "Nimbus"},
+	{"lodestar", "Lodestar"},
+	{"grandine", "Grandine"},
+}
+
+// ClientType returns the canonical spelling of a client type name, matched
+// case-insensitively, and false for names medic does not know
+func ClientType(name string) (string, bool) {
+	for _, client := range clientNames {
+		if strings.EqualFold(name, client.name) {
+			return client.name, true
+		}
+	}
+	if strings.EqualFold(name, "Unknown") {
+		return "Unknown", true
+	}
+	return "", false
+}
+
+// semverPattern matches the major.minor.patch version in a clientVersion
+// segment such as v1.14.11-stable or 24.1.2
+var semverPattern = regexp.MustCompile(`^v?(\d+\.\d+\.\d+)`)
+
+// ParseClientVersion identifies the client type and semantic version from a
+// web3_clientVersion string such as Geth/v1.14.11-stable/linux-amd64/go1.23.1.
+// Names are matched case-insensitively on well-known prefixes first and then
+// anywhere in the name, so builds such as CoreGeth are still recognized.
+// Unrecognized clients are reported as Unknown.
+func ParseClientVersion(raw string) ClientInfo {
+	return parseVersion(raw, clientNames)
+}
+
+// ParseBeaconVersion identifies the beacon client type and semantic version
+// from an /eth/v1/node/version string such as
+// Lighthouse/v5.3.0-d6ba8c3/x86_64-linux. Unrecognized clients are reported
+// as Unknown.
+func ParseBeaconVersion(raw string) ClientInfo {
+	return parseVersion(raw, beaconClientNames)
+}
+
+// parseVersion parses a name/version/... string, matching the name against
+// names
+func parseVersion(raw string, names []clientName) ClientInfo {
+	info := ClientInfo{Type: "Unknown", Raw: raw}
+
+	segments := strings.Split(raw, "/")
+	name := strings.ToLower(segments[0])
+	info.Type = matchClientName(name, names)
+
+	for _, segment := range segments[1:] {
+		if match := semverPattern.FindStringSubmatch(segment); match != nil {
+			info.Version = match[1]
+			break
+		}
+	}
+
+	return info
+}
+
+// matchClientName returns the client type for a lowercased client name,
+// preferring prefix matches over matches anywhere in the name
+func matchClientName(name string, names []clientName) string {
+	if name == "" {
+		return "Unknown"
+	}
+	for _, client := range names {
+		if strings.HasPrefix(name, client.prefix) {
+			return client.name
+		}
+	}
+	for _, client := range names {
+		if len(client.prefix) > 3 && strings.Contains(name, client.prefix) {
+			return client.name
+		}
+	}
+	return "Unknown"
+}
@@ -0,0 +1,83 @@
+package clients
+
+import "testing"
+
+func TestParseClientVersion(t *testing.T) {
+	tests := []struct {
+		raw        string
+		clientType string
+		version    string
+	}{
+		{raw: "Geth/v1.14.11-stable-f3c696fa/linux-amd64/go1.23.1", clientType: "Geth", version: "1.14.11"},
+		{raw: "Geth/v1.13.15-stable-c5ba367e/linux-arm64/go1.21.6", clientType: "Geth", version: "1.13.15"},
+		{raw: "Geth/my-node/v1.14.0-stable-87246f3c/linux-amd64/go1.22.2", clientType: "Geth", version: "1.14.0"},
+		{raw: "besu/v24.10.0/linux-x86_64/openjdk-java-21", clientType: "Besu", version: "24.10.0"},
+		{raw: "besu/v23.4.1/linux-x86_64/openjdk-java-17", clientType: "Besu", version: "23.4.1"},
+		{raw: "Nethermind/v1.29.1+dfea5240/linux-x64/dotnet8.0.10", clientType: "Nethermind", version: "1.29.1"},
+		{raw: "Nethermind/v1.25.4+20b10b35/linux-x64/dotnet8.0.2", clientType: "Nethermind", version: "1.25.4"},
+		{raw: "erigon/2.60.10/linux-amd64/go1.22.8", clientType: "Erigon", version: "2.60.10"},
+		{raw: "erigon/3.0.0-beta1/linux-amd64/go1.23.2", clientType: "Erigon", version: "3.0.0"},
+		{raw: "reth/v1.1.0-1ba631ba/x86_64-unknown-linux-gnu", clientType: "Reth", version: "1.1.0"},
+		{raw: "reth/v0.2.0-beta.6-ac29b4b73/x86_64-unknown-linux-gnu", clientType: "Reth", version: "0.2.0"},
+		{raw: "Nimbus-eth1/v0.1.0-9ec7ad4d/linux-amd64/Nim-2.0.8", clientType: "Nimbus-eth1", version: "0.1.0"},
+		{raw: "EthereumJS/7.1.0/linux/node20.11.0", clientType: "EthereumJS", version: "7.1.0"},
+		{raw: "nitro/v3.2.1-d81324d/linux-amd64/go1.22.6", clientType: "Nitro", version: "3.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			info := ParseClientVersion(tt.raw)
+			if info.Type != tt.clientType || info.Version != tt.version || info.Raw != tt.raw {
+				t.Errorf("ParseClientVersion() = %+v, want type %s, version %s", info, tt.clientType, tt.version)
+			}
+		})
+	}
+}
+
+func TestParseUnusualClientVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		clientType string
+		version    string
+	}{
+		{name: "lowercase nethermind", raw: "nethermind/v1.25.4+20b10b35/linux-x64/dotnet8.0.2", clientType: "Nethermind", version: "1.25.4"},
+		{name: "uppercase geth", raw: "GETH/v1.14.0-stable/linux-amd64/go1.22.0", clientType: "Geth", version: "1.14.0"},
+		{name: "capitalized erigon", raw: "Erigon/2.60.0/linux-amd64/go1.21.5", clientType: "Erigon", version: "2.60.0"},
+		{name: "capitalized besu", raw: "Besu/v24.1.2/linux-x86_64/openjdk-java-21", clientType: "Besu", version: "24.1.2"},
+		{name: "bor fork of geth", raw: "bor/v1.3.7/linux-amd64/go1.22.5", clientType: "Geth", version: "1.3.7"},
+		{name: "geth fork by name", raw: "CoreGeth/v1.12.20-stable-c2d2f4ed/linux-amd64/go1.21.10", clientType: "Geth", version: "1.12.20"},
+		{name: "name only", raw: "Geth", clientType: "Geth"},
+		{name: "no semantic version", raw: "reth/nightly/x86_64-unknown-linux-gnu", clientType: "Reth"},
+		{name: "empty", raw: "", clientType: "Unknown"},
+		{name: "unknown client", raw: "FooClient/v0.1.0/linux", clientType: "Unknown", version: "0.1.0"},
+		{name: "short names only match as prefix", raw: "taborclient/v1.0.0", clientType: "Unknown", version: "1.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := ParseClientVersion(tt.raw)
+			if info.Type != tt.clientType || info.Version != tt.version {
+				t.Errorf("ParseClientVersion(%q) = %+v, want type %s, version %q", tt.raw, info, tt.clientType, tt.version)
+			}
+		})
+	}
+}
+
+func TestClientType(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{name: "Nethermind", want: "Nethermind", ok: true},
+		{name: "nethermind", want: "Nethermind", ok: true},
+		{name: "GETH", want: "Geth", ok: true},
+		{name: "unknown", want: "Unknown", ok: true},
+		{name: "bor"},
+		{name: ""},
+	}
+	for _, tt := range tests {
+		if got, ok := ClientType(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("ClientType(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
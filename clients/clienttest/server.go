@@ -0,0 +1,379 @@
+// Package clienttest provides an in-process JSON-RPC node for exercising the
+// clients and health packages without a live node. A Server answers every
+// method with a programmed response, serves single calls and batches, and
+// can delay or corrupt its responses to reach timeout and error paths.
+//
+//	node := clienttest.NewServer()
+//	defer node.Close()
+//	node.Handle("net_peerCount", clienttest.PeerCount(2))
+//	node.Handle("eth_getBlockByNumber", clienttest.Block(100, time.Now().Add(-time.Minute)))
+//	monitor, err := health.New(health.DefaultConfig(node.URL))
+package clienttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/rarecrumb/medic/clients"
+)
+
+// Server is a JSON-RPC node backed by httptest. Methods without a response
+// answer with a method not found error, like a node with the namespace
+// disabled.
+type Server struct {
+	// URL is the base URL of the node, e.g. http://127.0.0.1:41234
+	URL string
+
+	server *httptest.Server
+
+	mu         sync.Mutex
+	responses  map[string]response
+	delays     map[string]time.Duration
+	latency    time.Duration
+	noBatches  bool
+	health     *healthResponse
+	healthWait time.Duration
+	calls      map[string]int
+	params     map[string]json.RawMessage
+	headers    map[string]http.Header
+	healthHits int
+}
+
+// response is the programmed answer to one method
+type response struct {
+	result json.RawMessage
+	err    *clients.RPCError
+	raw    []byte
+	status int
+}
+
+// healthResponse is the programmed answer of the /health endpoint
+type healthResponse struct {
+	status int
+	body   []byte
+}
+
+// NewServer starts a node that answers web3_clientVersion like Geth, a synced
+// eth_syncing, 25 peers and a block produced just now
+func NewServer() *Server {
+	s := &Server{
+		responses: map[string]response{},
+		delays:    map[string]time.Duration{},
+		calls:     map[string]int{},
+		params:    map[string]json.RawMessage{},
+		headers:   map[string]http.Header{},
+	}
+	s.Handle("web3_clientVersion", "Geth/v1.14.0-stable/linux-amd64/go1.22.0")
+	s.Handle("eth_syncing", false)
+	s.Handle("net_peerCount", PeerCount(25))
+	s.Handle("eth_getBlockByNumber", Block(1000, time.Now()))
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts the node down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Handle answers method with result, encoded as JSON
+func (s *Server) Handle(method string, result interface{}) {
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		panic(fmt.Sprintf("clienttest: cannot encode the result of %s: %v", method, err))
+	}
+	s.set(method, response{result: encoded})
+}
+
+// HandleError answers method with a JSON-RPC error
+func (s *Server) HandleError(method string, code int, message string) {
+	s.set(method, response{err: &clients.RPCError{Code: code, Message: message}})
+}
+
+// HandleRaw answers method with body as the whole HTTP response, for
+// malformed JSON. A batch that includes method is answered with body too.
+func (s *Server) HandleRaw(method string, body string) {
+	s.set(method, response{raw: []byte(body)})
+}
+
+// HandleStatus answers method with an HTTP status and body, like a proxy in
+// front of the node that rate limits or rejects the call
+func (s *Server) HandleStatus(method string, status int, body string) {
+	s.set(method, response{raw: []byte(body), status: status})
+}
+
+// Unhandle answers method with a method not found error again
+func (s *Server) Unhandle(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.responses, method)
+}
+
+func (s *Server) set(method string, r response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[method] = r
+}
+
+// SetLatency delays every response by latency
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// SetMethodLatency delays requests that call method by latency, on top of
+// the latency of every response
+func (s *Server) SetMethodLatency(method string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delays[method] = latency
+}
+
+// RejectBatches answers batches with 400 Bad Request, like proxies that only
+// forward single calls
+func (s *Server) RejectBatches(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noBatches = reject
+}
+
+// SetHealth answers GET /health with status and body, such as one of the
+// canned Nethermind payloads. The endpoint is not found until it is set.
+func (s *Server) SetHealth(status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.health = &healthResponse{status: status, body: []byte(body)}
+}
+
+// SetHealthLatency delays the /health endpoint by latency, on top of the
+// latency of every response
+func (s *Server) SetHealthLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthWait = latency
+}
+
+// Calls returns how often method was called, counting every call of a batch
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// Params returns the params of the last call of method, or nil when it was
+// not called
+func (s *Server) Params(method string) json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.params[method]
+}
+
+// Header returns the HTTP headers of the last request that called method, or
+// nil when it was not called
+func (s *Server) Header(method string) http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers[method]
+}
+
+// HealthCalls returns how often the /health endpoint was requested
+func (s *Server) HealthCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.healthHits
+}
+
+// request is a single JSON-RPC call as sent by the clients package
+type request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/health" {
+		s.serveHealth(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var requests []request
+	batch := len(body) != 0 && body[0] == '['
+	if batch {
+		err = json.Unmarshal(body, &requests)
+	} else {
+		requests = make([]request, 1)
+		err = json.Unmarshal(body, &requests[0])
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	if batch && s.noBatches {
+		s.mu.Unlock()
+		http.Error(w, "batch requests are not supported", http.StatusBadRequest)
+		return
+	}
+	delay := s.latency
+	answers := make([]interface{}, len(requests))
+	var raw []byte
+	status := http.StatusOK
+	for i, req := range requests {
+		s.calls[req.Method]++
+		s.params[req.Method] = req.Params
+		s.headers[req.Method] = r.Header.Clone()
+		delay += s.delays[req.Method]
+		resp, ok := s.responses[req.Method]
+		if resp.raw != nil {
+			raw = resp.raw
+		}
+		if resp.status != 0 {
+			status = resp.status
+		}
+		answers[i] = answer(req, resp, ok)
+	}
+	s.mu.Unlock()
+
+	if !sleep(r, delay) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	switch {
+	case raw != nil:
+		w.Write(raw)
+	case batch:
+		json.NewEncoder(w).Encode(answers)
+	default:
+		json.NewEncoder(w).Encode(answers[0])
+	}
+}
+
+// answer builds the JSON-RPC response to req
+func answer(req request, resp response, ok bool) interface{} {
+	message := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	switch {
+	case !ok:
+		message["error"] = clients.RPCError{Code: -32601, Message: fmt.Sprintf("the method %s does not exist/is not available", req.Method)}
+	case resp.err != nil:
+		message["error"] = resp.err
+	default:
+		message["result"] = resp.result
+	}
+	return message
+}
+
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.healthHits++
+	health, latency := s.health, s.latency+s.healthWait
+	s.mu.Unlock()
+
+	if !sleep(r, latency) {
+		return
+	}
+	if health == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(health.status)
+	w.Write(health.body)
+}
+
+// sleep waits for delay and reports whether the client is still waiting for
+// the response
+func sleep(r *http.Request, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// Block returns an eth_getBlockByNumber result for block number produced at
+// produced. The header is complete, so that ethclient, which rejects headers
+// with missing fields, can decode it too.
+func Block(number uint64, produced time.Time) interface{} {
+	return map[string]interface{}{
+		"number":           hexutil.Uint64(number),
+		"hash":             fmt.Sprintf("0x%064x", number),
+		"parentHash":       fmt.Sprintf("0x%064x", number-1),
+		"sha3Uncles":       types.EmptyUncleHash,
+		"miner":            common.Address{},
+		"stateRoot":        types.EmptyRootHash,
+		"transactionsRoot": types.EmptyTxsHash,
+		"receiptsRoot":     types.EmptyReceiptsHash,
+		"logsBloom":        types.Bloom{},
+		"difficulty":       (*hexutil.Big)(common.Big0),
+		"gasLimit":         hexutil.Uint64(30_000_000),
+		"gasUsed":          hexutil.Uint64(0),
+		"timestamp":        hexutil.Uint64(produced.Unix()),
+		"extraData":        hexutil.Bytes{},
+		"mixHash":          common.Hash{},
+		"nonce":            types.BlockNonce{},
+		"baseFeePerGas":    (*hexutil.Big)(big.NewInt(params.InitialBaseFee)),
+		"transactions":     []common.Hash{},
+		"uncles":           []common.Hash{},
+	}
+}
+
+// PeerCount returns a net_peerCount result
+func PeerCount(peers uint64) interface{} {
+	return hexutil.Uint64(peers)
+}
+
+// Syncing returns an eth_syncing result for a node at current syncing to
+// highest
+func Syncing(current, highest uint64) interface{} {
+	return map[string]interface{}{
+		"startingBlock": hexutil.Uint64(0),
+		"currentBlock":  hexutil.Uint64(current),
+		"highestBlock":  hexutil.Uint64(highest),
+	}
+}
+
+// Canned bodies of the Nethermind /health endpoint, which Nethermind answers
+// with 200 when healthy and 503 otherwise
+const (
+	NethermindHealthy = `{"status":"Healthy","totalDuration":"00:00:00.0012","entries":{` +
+		`"node-health":{"status":"Healthy","description":"The node is now fully synced with a network. Peers: 25.","data":{"IsSyncing":false,"Errors":[]}},` +
+		`"process":{"status":"Healthy","data":{}}}}`
+	NethermindSyncing = `{"status":"Unhealthy","totalDuration":"00:00:00.0012","entries":{` +
+		`"node-health":{"status":"Unhealthy","description":"The node is still syncing.","data":{"IsSyncing":true,"Errors":["Synchronization"]}},` +
+		`"process":{"status":"Healthy","data":{}}}}`
+	NethermindDiskFull = `{"status":"Unhealthy","totalDuration":"00:00:00.0012","entries":{` +
+		`"node-health":{"status":"Healthy","description":"The node is now fully synced with a network. Peers: 25.","data":{"IsSyncing":false,"Errors":[]}},` +
+		`"db-size":{"status":"Unhealthy","description":"disk almost full","data":{}}}}`
+)
@@ -0,0 +1,82 @@
+package clients
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// SyncStage is the progress of one Erigon or Reth sync stage
+type SyncStage struct {
+	Name        string `json:"name"`
+	BlockNumber uint64 `json:"block_number"`
+}
+
+// StageProgress describes the first sync stage that has not caught up with
+// the highest known block
+type StageProgress struct {
+	Name         string  `json:"name"`
+	BlockNumber  uint64  `json:"block_number"`
+	HighestBlock uint64  `json:"highest_block"`
+	Progress     float64 `json:"progress"`
+}
+
+// syncStages holds the stage list Erigon and Reth add to the eth_syncing
+// result. Erigon names the fields stage_name and block_number, Reth uses name
+// and block.
+type syncStages struct {
+	Stages []struct {
+		ErigonName  string          `json:"stage_name"`
+		ErigonBlock *hexutil.Uint64 `json:"block_number"`
+		RethName    string          `json:"name"`
+		RethBlock   *hexutil.Uint64 `json:"block"`
+	} `json:"stages"`
+}
+
+// parseStages extracts the staged-sync progress from an eth_syncing result.
+// Clients and versions without stage details yield nil.
+func parseStages(result json.RawMessage) []SyncStage {
+	var decoded syncStages
+	if err := json.Unmarshal(result, &decoded); err != nil {
+		return nil
+	}
+
+	var stages []SyncStage
+	for _, raw := range decoded.Stages {
+		stage := SyncStage{Name: raw.ErigonName}
+		if stage.Name == "" {
+			stage.Name = raw.RethName
+		}
+		switch {
+		case raw.ErigonBlock != nil:
+			stage.BlockNumber = uint64(*raw.ErigonBlock)
+		case raw.RethBlock != nil:
+			stage.BlockNumber = uint64(*raw.RethBlock)
+		}
+		stages = append(stages, stage)
+	}
+	return stages
+}
+
+// LaggingStage returns the first stage that is more than maxDistance blocks
+// behind the highest block, or nil when every stage has caught up or no
+// stage details were reported
+func (s *SyncStatus) LaggingStage(maxDistance uint64) *StageProgress {
+	for _, stage := range s.Stages {
+		if stage.BlockNumber+maxDistance >= s.HighestBlock {
+			continue
+		}
+
+		progress := &StageProgress{
+			Name:         stage.Name,
+			BlockNumber:  stage.BlockNumber,
+			HighestBlock: s.HighestBlock,
+		}
+		if s.HighestBlock != 0 {
+			progress.Progress = float64(stage.BlockNumber) / float64(s.HighestBlock)
+		}
+		return progress
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// methodNotFoundCode is the JSON-RPC error code for methods the node does
+// not serve
+const methodNotFoundCode = -32601
+
+var (
+	// ErrRPCError is matched by errors returned when the node answers with a
+	// JSON-RPC error object
+	ErrRPCError = errors.New("json-rpc error")
+
+	// ErrHTTPStatus is matched by errors returned when the node answers with
+	// a non-2xx HTTP status
+	ErrHTTPStatus = errors.New("unexpected http status")
+
+	// ErrEmptyResult is returned when a call that must produce a value
+	// returns an empty result
+	ErrEmptyResult = errors.New("empty json-rpc result")
+)
+
+// RPCError is a JSON-RPC error object
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+func (e *RPCError) Is(target error) bool {
+	return target == ErrRPCError
+}
+
+// HTTPStatusError records a non-2xx HTTP response, typically from a proxy
+// in front of the node
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected http status: %s", e.Status)
+}
+
+func (e *HTTPStatusError) Is(target error) bool {
+	return target == ErrHTTPStatus
+}
+
+// IsMethodNotFound reports whether err means that the node does not serve the
+// called method, either by error code or by the "does not exist" message that
+// hosted providers return with other codes
+func IsMethodNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == methodNotFoundCode {
+		return true
+	}
+	// Errors from the go-ethereum RPC client carry the code the same way
+	var codeErr interface{ ErrorCode() int }
+	if errors.As(err, &codeErr) && codeErr.ErrorCode() == methodNotFoundCode {
+		return true
+	}
+
+	return strings.Contains(strings.ToLower(err.Error()), "does not exist")
+}
+
+// stateUnavailableMessages are the error messages each client returns when it
+// is at head but cannot serve the requested state, e.g. while healing after a
+// snap sync or when the state has been pruned
+var stateUnavailableMessages = []struct {
+	client  string
+	message string
+}{
+	{"Geth", "missing trie node"},
+	{"Geth", "required historical state unavailable"},
+	{"Geth", "historical state not available"},
+	{"Geth", "state is not available"},
+	{"Erigon", "state not available"},
+	{"Erigon", "no history available"},
+	{"Erigon", "is pruned"},
+	{"Nethermind", "missingtrienode"},
+	{"Nethermind", "no state available"},
+	{"Nethermind", "trie node missing"},
+	{"Reth", "state at block"},
+	{"Reth", "missing trie updates"},
+}
+
+// IsStateUnavailable reports whether err means that the node could not read
+// the requested state rather than failing the call for another reason
+func IsStateUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, known := range stateUnavailableMessages {
+		if strings.Contains(message, known.message) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithoutURL strips the URL that transport errors quote, since it may hold
+// an API key
+func WithoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
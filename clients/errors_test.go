@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsStateUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil},
+		{
+			name: "geth missing trie node",
+			err:  &RPCError{Code: -32000, Message: "missing trie node 9f2a3c0e5b9dd6a6c1d9e53a43e1b0d4e2c1f00d3f8bf3c4aa0c9f2d9a7c1b2e (path )"},
+			want: true,
+		},
+		{
+			name: "geth pruned state",
+			err:  &RPCError{Code: -32000, Message: "required historical state unavailable (reexec=128)"},
+			want: true,
+		},
+		{
+			name: "geth path scheme",
+			err:  &RPCError{Code: -32000, Message: "historical state not available in path scheme yet"},
+			want: true,
+		},
+		{
+			name: "geth healing",
+			err:  &RPCError{Code: -32000, Message: "state is not available"},
+			want: true,
+		},
+		{
+			name: "erigon pruned history",
+			err:  &RPCError{Code: -32000, Message: "block 17034870 is pruned"},
+			want: true,
+		},
+		{
+			name: "erigon no history",
+			err:  &RPCError{Code: -32000, Message: "no history available for block 12000000"},
+			want: true,
+		},
+		{
+			name: "nethermind exception",
+			err:  &RPCError{Code: -32002, Message: "MissingTrieNodeException: Node 0x4f1c...e2a0 is missing from the DB"},
+			want: true,
+		},
+		{
+			name: "nethermind no state",
+			err:  &RPCError{Code: -32002, Message: "No state available for block 0x1a2b3c"},
+			want: true,
+		},
+		{
+			name: "reth pruned state",
+			err:  &RPCError{Code: -32000, Message: "state at block #17000000 is pruned"},
+			want: true,
+		},
+		{
+			name: "reth missing trie updates",
+			err:  &RPCError{Code: -32000, Message: "missing trie updates for block 19000000"},
+			want: true,
+		},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("eth_getBalance: %w", &RPCError{Code: -32000, Message: "missing trie node abc (path 0a)"}),
+			want: true,
+		},
+		{name: "reverted", err: &RPCError{Code: 3, Message: "execution reverted"}},
+		{name: "unknown block", err: &RPCError{Code: -32000, Message: "header not found"}},
+		{name: "method not found", err: &RPCError{Code: -32601, Message: "the method eth_getBalance does not exist/is not available"}},
+		{name: "rate limited", err: &HTTPStatusError{StatusCode: 429, Status: "429 Too Many Requests"}},
+		{name: "timeout", err: errors.New("context deadline exceeded")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsStateUnavailable(tt.err); got != tt.want {
+				t.Errorf("IsStateUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// GasPrice returns the gas price in wei suggested by eth_gasPrice
+func GasPrice(ctx context.Context, url string) (*big.Int, error) {
+	rpcResponse, err := call(ctx, url, "eth_gasPrice")
+	if err != nil {
+		return nil, err
+	}
+	if len(rpcResponse.Result) == 0 {
+		return nil, fmt.Errorf("eth_gasPrice: %w", ErrEmptyResult)
+	}
+
+	var price hexutil.Big
+	if err := decodeResult(rpcResponse, &price); err != nil {
+		return nil, fmt.Errorf("eth_gasPrice: %w", err)
+	}
+	return price.ToInt(), nil
+}
+
+// NextBaseFee calls eth_feeHistory over the last blocks and returns the base
+// fee in wei of the block after the latest one, which the node reports as the
+// last entry of baseFeePerGas
+func NextBaseFee(ctx context.Context, url string, blocks uint64) (*big.Int, error) {
+	rpcResponse, err := call(ctx, url, "eth_feeHistory", hexutil.EncodeUint64(blocks), "latest", []float64{})
+	if err != nil {
+		return nil, err
+	}
+
+	var history struct {
+		BaseFeePerGas []*hexutil.Big `json:"baseFeePerGas"`
+	}
+	if err := decodeResult(rpcResponse, &history); err != nil {
+		return nil, fmt.Errorf("eth_feeHistory: %w", err)
+	}
+	if len(history.BaseFeePerGas) == 0 {
+		return nil, fmt.Errorf("eth_feeHistory: %w", ErrEmptyResult)
+	}
+
+	last := history.BaseFeePerGas[len(history.BaseFeePerGas)-1]
+	if last == nil {
+		return nil, fmt.Errorf("eth_feeHistory: null base fee")
+	}
+	return last.ToInt(), nil
+}
@@ -0,0 +1,124 @@
+package clients_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/clients/clienttest"
+)
+
+func TestGasPrice(t *testing.T) {
+	aboveUint64, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		name    string
+		result  interface{}
+		want    *big.Int
+		wantErr bool
+	}{
+		{name: "zero", result: "0x0", want: big.NewInt(0)},
+		{name: "gwei", result: "0x3b9aca00", want: big.NewInt(1_000_000_000)},
+		{name: "above uint64", result: "0x18ee90ff6c373e0ee4e3f0ad2", want: aboveUint64},
+		{name: "malformed hex", result: "0xzz", wantErr: true},
+		{name: "missing prefix", result: "3b9aca00", wantErr: true},
+		{name: "leading zero", result: "0x0a", wantErr: true},
+		{name: "number", result: 1000000000, wantErr: true},
+		{name: "null", result: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			node.Handle("eth_gasPrice", tt.result)
+
+			got, err := clients.GasPrice(context.Background(), node.URL)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GasPrice() = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GasPrice() error = %v", err)
+			}
+			if got.Cmp(tt.want) != 0 {
+				t.Errorf("GasPrice() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextBaseFee(t *testing.T) {
+	aboveUint64, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		name    string
+		result  interface{}
+		want    *big.Int
+		target  error
+		wantErr bool
+	}{
+		{
+			name:   "next block",
+			result: map[string]interface{}{"oldestBlock": "0x1", "baseFeePerGas": []string{"0x3b9aca00", "0x77359400"}},
+			want:   big.NewInt(2_000_000_000),
+		},
+		{
+			// Geth reports a zero base fee for blocks before London
+			name:   "zero before London",
+			result: map[string]interface{}{"oldestBlock": "0x1", "baseFeePerGas": []string{"0x0", "0x0"}},
+			want:   big.NewInt(0),
+		},
+		{
+			name:   "above uint64",
+			result: map[string]interface{}{"oldestBlock": "0x1", "baseFeePerGas": []string{"0x0", "0x18ee90ff6c373e0ee4e3f0ad2"}},
+			want:   aboveUint64,
+		},
+		{
+			// Other clients leave the field out before London
+			name:   "missing before London",
+			result: map[string]interface{}{"oldestBlock": "0x1", "reward": []interface{}{}},
+			target: clients.ErrEmptyResult,
+		},
+		{
+			name:   "empty",
+			result: map[string]interface{}{"oldestBlock": "0x1", "baseFeePerGas": []string{}},
+			target: clients.ErrEmptyResult,
+		},
+		{
+			name:    "null base fee",
+			result:  map[string]interface{}{"oldestBlock": "0x1", "baseFeePerGas": []interface{}{"0x1", nil}},
+			wantErr: true,
+		},
+		{
+			name:    "malformed hex",
+			result:  map[string]interface{}{"oldestBlock": "0x1", "baseFeePerGas": []string{"0x1", "0xzz"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			node.Handle("eth_feeHistory", tt.result)
+
+			got, err := clients.NextBaseFee(context.Background(), node.URL, 2)
+			if tt.wantErr || tt.target != nil {
+				if err == nil {
+					t.Fatalf("NextBaseFee() = %s, want an error", got)
+				}
+				if tt.target != nil && !errors.Is(err, tt.target) {
+					t.Errorf("NextBaseFee() error = %v, want %v", err, tt.target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NextBaseFee() error = %v", err)
+			}
+			if got.Cmp(tt.want) != 0 {
+				t.Errorf("NextBaseFee() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
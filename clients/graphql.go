@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// graphQLBlockQuery asks for the number of the latest block
+const graphQLBlockQuery = "{ block { number } }"
+
+// ErrGraphQLSchema is matched by errors returned when a GraphQL response does
+// not have the shape of the query
+var ErrGraphQLSchema = errors.New("unexpected graphql response")
+
+// GraphQLError is an entry of the errors array of a GraphQL response
+type GraphQLError struct {
+	Message string `json:"message"`
+}
+
+// GraphQLBlockNumber returns the number of the latest block served by the
+// GraphQL endpoint at url, as exposed by Geth and Besu
+func GraphQLBlockNumber(ctx context.Context, url string) (uint64, error) {
+	body, err := post(ctx, url, map[string]string{"query": graphQLBlockQuery})
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Data *struct {
+			Block *struct {
+				Number json.RawMessage `json:"number"`
+			} `json:"block"`
+		} `json:"data"`
+		Errors []GraphQLError `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrGraphQLSchema, err)
+	}
+	if len(response.Errors) != 0 {
+		messages := make([]string, len(response.Errors))
+		for i, e := range response.Errors {
+			messages[i] = e.Message
+		}
+		return 0, fmt.Errorf("graphql errors: %s", strings.Join(messages, "; "))
+	}
+	if response.Data == nil || response.Data.Block == nil || len(response.Data.Block.Number) == 0 {
+		return 0, fmt.Errorf("%w: no block number", ErrGraphQLSchema)
+	}
+
+	// Geth encodes Long values as hex strings, Besu as JSON numbers
+	raw := string(response.Data.Block.Number)
+	if unquoted, err := strconv.Unquote(raw); err == nil {
+		raw = unquoted
+	}
+	number, err := strconv.ParseUint(raw, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid block number %s", ErrGraphQLSchema, response.Data.Block.Number)
+	}
+	return number, nil
+}
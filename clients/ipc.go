@@ -0,0 +1,22 @@
+package clients
+
+import (
+	"strings"
+)
+
+// IsIPC reports whether url refers to an IPC socket, either as an ipc:// URL
+// or as a plain filesystem path
+func IsIPC(url string) bool {
+	if strings.HasPrefix(strings.ToLower(url), "ipc://") {
+		return true
+	}
+	return !strings.Contains(url, "://") && url != ""
+}
+
+// IPCPath returns the filesystem path of an IPC socket URL
+func IPCPath(url string) string {
+	if strings.HasPrefix(strings.ToLower(url), "ipc://") {
+		return url[len("ipc://"):]
+	}
+	return url
+}
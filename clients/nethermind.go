@@ -1,24 +1,75 @@
 package clients
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 )
 
+// NethermindHealth is the response of the Nethermind /health endpoint
 type NethermindHealth struct {
-	Status  string `json:"status"`
-	Entries struct {
-		NodeHealth struct {
-			Data struct {
-				Errors    []string `json:"Errors"`
-				IsSyncing bool     `json:"IsSyncing"`
-			} `json:"data"`
-		} `json:"node-health"`
-	} `json:"entries"`
+	// StatusCode is the HTTP status of the response. Nethermind answers 503
+	// with a regular health body when the node is unhealthy.
+	StatusCode int `json:"-"`
+
+	Status  string                 `json:"status"`
+	Entries map[string]HealthEntry `json:"entries"`
+}
+
+// HealthEntry is a single named check of the Nethermind health response,
+// such as node-health, db, sync or process
+type HealthEntry struct {
+	Status      string          `json:"status"`
+	Description string          `json:"description,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+}
+
+// Healthy reports whether the entry passed. Entries without a status, as
+// returned by older versions, are judged by their data alone.
+func (e HealthEntry) Healthy() bool {
+	return e.Status == "" || strings.EqualFold(e.Status, "Healthy")
+}
+
+// NodeHealthData is the data payload of the node-health entry
+type NodeHealthData struct {
+	Errors    []string `json:"Errors"`
+	IsSyncing bool     `json:"IsSyncing"`
+}
+
+// NodeHealth decodes the data of the node-health entry, returning nil when
+// the entry is missing or carries no data
+func (h *NethermindHealth) NodeHealth() *NodeHealthData {
+	entry, ok := h.Entries["node-health"]
+	if !ok || len(entry.Data) == 0 {
+		return nil
+	}
+
+	var data NodeHealthData
+	if err := json.Unmarshal(entry.Data, &data); err != nil {
+		return nil
+	}
+	return &data
 }
 
-func NethermindHealthCheck(url string) (*NethermindHealth, error) {
-	resp, err := http.Get(url + "/health")
+// FailingEntries returns the names of the entries that did not pass
+func (h *NethermindHealth) FailingEntries() []string {
+	var failing []string
+	for name, entry := range h.Entries {
+		if !entry.Healthy() {
+			failing = append(failing, name)
+		}
+	}
+	return failing
+}
+
+func NethermindHealthCheck(ctx context.Context, url string) (*NethermindHealth, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := clientFor(ctx).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -26,8 +77,12 @@ func NethermindHealthCheck(url string) (*NethermindHealth, error) {
 
 	var health NethermindHealth
 	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d from %s: %w", resp.StatusCode, req.URL, err)
+		}
 		return nil, err
 	}
+	health.StatusCode = resp.StatusCode
 
 	return &health, nil
 }
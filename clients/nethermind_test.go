@@ -0,0 +1,112 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/clients/clienttest"
+)
+
+// The fixtures in testdata/nethermind follow the /health responses of
+// Nethermind 1.25 and later, which answers 503 with the same body when a
+// check fails
+func TestNethermindHealthCheck(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		status   int
+		overall  string
+		nodeData *clients.NodeHealthData
+		failing  []string
+	}{
+		{
+			fixture:  "healthy.json",
+			status:   http.StatusOK,
+			overall:  "Healthy",
+			nodeData: &clients.NodeHealthData{Errors: []string{}},
+		},
+		{
+			fixture:  "syncing.json",
+			status:   http.StatusServiceUnavailable,
+			overall:  "Unhealthy",
+			nodeData: &clients.NodeHealthData{Errors: []string{"SyncIssue"}, IsSyncing: true},
+			failing:  []string{"node-health"},
+		},
+		{
+			fixture:  "no-cl-messages.json",
+			status:   http.StatusServiceUnavailable,
+			overall:  "Unhealthy",
+			nodeData: &clients.NodeHealthData{Errors: []string{"NoMessagesFromCL"}},
+			failing:  []string{"node-health"},
+		},
+		{
+			fixture:  "low-disk.json",
+			status:   http.StatusServiceUnavailable,
+			overall:  "Unhealthy",
+			nodeData: &clients.NodeHealthData{Errors: []string{}},
+			failing:  []string{"db-size"},
+		},
+		{
+			// Entries of older versions carry no status of their own
+			fixture:  "legacy.json",
+			status:   http.StatusOK,
+			overall:  "Healthy",
+			nodeData: &clients.NodeHealthData{Errors: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "nethermind", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			node := clienttest.NewServer()
+			defer node.Close()
+			node.SetHealth(tt.status, string(body))
+
+			health, err := clients.NethermindHealthCheck(context.Background(), node.URL)
+			if err != nil {
+				t.Fatalf("NethermindHealthCheck() error = %v", err)
+			}
+			if health.StatusCode != tt.status || health.Status != tt.overall {
+				t.Errorf("status = %d %s, want %d %s", health.StatusCode, health.Status, tt.status, tt.overall)
+			}
+			if data := health.NodeHealth(); !reflect.DeepEqual(data, tt.nodeData) {
+				t.Errorf("NodeHealth() = %+v, want %+v", data, tt.nodeData)
+			}
+			failing := health.FailingEntries()
+			sort.Strings(failing)
+			if !reflect.DeepEqual(failing, tt.failing) {
+				t.Errorf("FailingEntries() = %v, want %v", failing, tt.failing)
+			}
+		})
+	}
+}
+
+func TestNethermindHealthCheckErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "not enabled", status: http.StatusNotFound, body: "Not Found"},
+		{name: "proxy error page", status: http.StatusServiceUnavailable, body: "<html>503 Service Unavailable</html>"},
+		{name: "truncated body", status: http.StatusOK, body: `{"status":"Healthy","entries":{`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			node.SetHealth(tt.status, tt.body)
+
+			if health, err := clients.NethermindHealthCheck(context.Background(), node.URL); err == nil {
+				t.Errorf("NethermindHealthCheck() = %+v, want an error", health)
+			}
+		})
+	}
+}
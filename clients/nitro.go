@@ -0,0 +1,96 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// NitroProgress is the Arbitrum Nitro specific part of eth_syncing, which
+// Nitro reports while it is behind. Fields are nil on versions that do not
+// report them.
+type NitroProgress struct {
+	// MsgCount is the number of messages the node has processed
+	MsgCount *uint64 `json:"msg_count,omitempty"`
+	// MaxMessageCount is the highest message count seen from the sequencer
+	// feed or L1, reported by newer versions
+	MaxMessageCount *uint64 `json:"max_message_count,omitempty"`
+	// BroadcasterQueuedMessagesPos is the position of the first message
+	// queued from the sequencer feed, 0 when none are queued
+	BroadcasterQueuedMessagesPos *uint64 `json:"broadcaster_queued_messages_pos,omitempty"`
+	LastL1BlockNum               *uint64 `json:"last_l1_block_num,omitempty"`
+	BatchSeen                    *uint64 `json:"batch_seen,omitempty"`
+	BatchProcessed               *uint64 `json:"batch_processed,omitempty"`
+}
+
+// MessageLag returns the number of messages seen from the sequencer feed or
+// L1 that the node has not processed yet, and false when the version reports
+// neither the processed nor the seen count
+func (p *NitroProgress) MessageLag() (uint64, bool) {
+	if p.MsgCount == nil {
+		return 0, false
+	}
+
+	var seen uint64
+	switch {
+	case p.MaxMessageCount != nil:
+		seen = *p.MaxMessageCount
+	case p.BroadcasterQueuedMessagesPos != nil:
+		seen = *p.BroadcasterQueuedMessagesPos
+	default:
+		return 0, false
+	}
+	if seen > *p.MsgCount {
+		return seen - *p.MsgCount, true
+	}
+	return 0, true
+}
+
+// parseNitroProgress extracts the Nitro fields from an eth_syncing result,
+// returning nil for other clients
+func parseNitroProgress(result json.RawMessage) *NitroProgress {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(result, &fields); err != nil {
+		return nil
+	}
+
+	progress := &NitroProgress{}
+	found := false
+	for key, target := range map[string]**uint64{
+		"msgCount":                     &progress.MsgCount,
+		"maxMessageCount":              &progress.MaxMessageCount,
+		"broadcasterQueuedMessagesPos": &progress.BroadcasterQueuedMessagesPos,
+		"lastL1BlockNum":               &progress.LastL1BlockNum,
+		"batchSeen":                    &progress.BatchSeen,
+		"batchProcessed":               &progress.BatchProcessed,
+	} {
+		raw, ok := fields[key]
+		if !ok {
+			continue
+		}
+		if value, err := parseQuantity(raw); err == nil {
+			*target = &value
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return progress
+}
+
+// NitroHealth returns the status code of the health endpoint in front of a
+// Nitro node, 200 when it is healthy
+func NitroHealth(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := clientFor(ctx).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
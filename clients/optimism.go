@@ -0,0 +1,88 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// L1BlockRef is an L1 block as reported by op-node
+type L1BlockRef struct {
+	Hash      string `json:"hash"`
+	Number    uint64 `json:"number"`
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// L2BlockRef is an L2 block as reported by op-node, along with the L1 block
+// it was derived from
+type L2BlockRef struct {
+	Hash      string `json:"hash"`
+	Number    uint64 `json:"number"`
+	Timestamp uint64 `json:"timestamp"`
+	L1Origin  struct {
+		Hash   string `json:"hash"`
+		Number uint64 `json:"number"`
+	} `json:"l1origin"`
+}
+
+// Time returns the timestamp of the block
+func (b L2BlockRef) Time() time.Time {
+	return time.Unix(int64(b.Timestamp), 0)
+}
+
+// OPSyncStatus is the result of optimism_syncStatus
+type OPSyncStatus struct {
+	CurrentL1   L1BlockRef `json:"current_l1"`
+	HeadL1      L1BlockRef `json:"head_l1"`
+	UnsafeL2    L2BlockRef `json:"unsafe_l2"`
+	SafeL2      L2BlockRef `json:"safe_l2"`
+	FinalizedL2 L2BlockRef `json:"finalized_l2"`
+}
+
+// RollupConfig holds the fields of optimism_rollupConfig medic uses
+type RollupConfig struct {
+	// BlockTime is the L2 block time in seconds
+	BlockTime uint64 `json:"block_time"`
+	// SeqWindowSize is the number of L1 blocks within which a batch must be
+	// submitted before the chain is derived without the sequencer's blocks
+	SeqWindowSize uint64 `json:"seq_window_size"`
+	L2ChainID     uint64 `json:"l2_chain_id"`
+}
+
+// OptimismSyncStatus returns the sync status of the op-node at url
+func OptimismSyncStatus(ctx context.Context, url string) (*OPSyncStatus, error) {
+	rpcResponse, err := call(ctx, url, "optimism_syncStatus")
+	if err != nil {
+		return nil, err
+	}
+	if len(rpcResponse.Result) == 0 {
+		return nil, fmt.Errorf("optimism_syncStatus: %w", ErrEmptyResult)
+	}
+
+	var status OPSyncStatus
+	if err := decodeResult(rpcResponse, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// OptimismRollupConfig returns the rollup configuration of the op-node at
+// url, which does not change for a running node
+func OptimismRollupConfig(ctx context.Context, url string) (*RollupConfig, error) {
+	rpcResponse, err := call(ctx, url, "optimism_rollupConfig")
+	if err != nil {
+		return nil, err
+	}
+	if len(rpcResponse.Result) == 0 {
+		return nil, fmt.Errorf("optimism_rollupConfig: %w", ErrEmptyResult)
+	}
+
+	var config RollupConfig
+	if err := decodeResult(rpcResponse, &config); err != nil {
+		return nil, err
+	}
+	if config.BlockTime == 0 {
+		return nil, fmt.Errorf("optimism_rollupConfig has no block_time")
+	}
+	return &config, nil
+}
@@ -0,0 +1,104 @@
+package clients
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Options are the settings requests to a node are made with. They travel
+// with the context of each call, see WithOptions, so that programs reaching
+// several nodes can use different settings for each.
+type Options struct {
+	// HTTPClient sends the HTTP requests, including those of the RPC clients
+	// returned by Dial. The shared default client is used when nil.
+	HTTPClient *http.Client
+	// Headers are sent with WebSocket handshakes. HTTP requests get theirs
+	// from the transport of HTTPClient instead.
+	Headers http.Header
+	// TLSConfig is the TLS config of WebSocket handshakes
+	TLSConfig *tls.Config
+	// Proxy is the proxy of WebSocket handshakes, which tunnel through it
+	// with CONNECT, and of requests to reference endpoints. Nil connects
+	// directly.
+	Proxy func(*http.Request) (*url.URL, error)
+	// Credentials are sent as basic auth to the node, see StripCredentials.
+	// HTTP requests get them from a BasicAuthTransport, WebSocket handshakes
+	// from Dial.
+	Credentials *url.Userinfo
+
+	referenceOnce   sync.Once
+	referenceClient *http.Client
+}
+
+// defaultOptions are used for calls whose context carries no options
+var defaultOptions = &Options{
+	HTTPClient: &http.Client{
+		Timeout: DefaultTimeout,
+		Transport: &BasicAuthTransport{Base: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConns:        10,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		}},
+	},
+	Proxy: http.ProxyFromEnvironment,
+}
+
+type optionsKey struct{}
+
+// WithOptions returns a context whose calls are made with options. The
+// options must not be modified afterwards.
+func WithOptions(ctx context.Context, options *Options) context.Context {
+	return context.WithValue(ctx, optionsKey{}, options)
+}
+
+// optionsFor returns the options of the calls made with ctx
+func optionsFor(ctx context.Context) *Options {
+	if options, ok := ctx.Value(optionsKey{}).(*Options); ok && options != nil {
+		return options
+	}
+	return defaultOptions
+}
+
+// WithCredentials returns options like o that send user as basic auth, or o
+// itself when user is nil. A nil o stands for the default options.
+func (o *Options) WithCredentials(user *url.Userinfo) *Options {
+	if user == nil {
+		return o
+	}
+	if o == nil {
+		o = defaultOptions
+	}
+	return &Options{
+		HTTPClient:  o.HTTPClient,
+		Headers:     o.Headers,
+		TLSConfig:   o.TLSConfig,
+		Proxy:       o.Proxy,
+		Credentials: user,
+	}
+}
+
+// clientFor returns the HTTP client for requests made with ctx
+func clientFor(ctx context.Context) *http.Client {
+	return optionsFor(ctx).httpClient()
+}
+
+func (o *Options) httpClient() *http.Client {
+	if o.HTTPClient == nil {
+		return defaultOptions.HTTPClient
+	}
+	return o.HTTPClient
+}
+
+// references returns the client for reference endpoints, which are run by
+// third parties and must not receive the headers configured for the node
+func (o *Options) references() *http.Client {
+	o.referenceOnce.Do(func() {
+		o.referenceClient = &http.Client{Timeout: DefaultTimeout, Transport: referenceTransport(o.Proxy)}
+	})
+	return o.referenceClient
+}
@@ -0,0 +1,274 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// reconnectMaxBackoff caps the delay between reconnect attempts
+const reconnectMaxBackoff = 30 * time.Second
+
+var (
+	connsMu         sync.Mutex
+	persistentConns = map[connKey]*persistentConn{}
+)
+
+// connKey identifies a persistent connection. Connections are kept per
+// options, so callers with different settings never share one.
+type connKey struct {
+	options *Options
+	url     string
+}
+
+// IsPersistent reports whether url is served over a persistent connection
+// rather than individual HTTP requests
+func IsPersistent(url string) bool {
+	return IsWebSocket(url) || IsIPC(url)
+}
+
+// IsWebSocket reports whether url uses the ws or wss scheme
+func IsWebSocket(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "ws://") || strings.HasPrefix(lower, "wss://")
+}
+
+// HTTPURL converts ws and wss URLs to their http and https equivalents, for
+// endpoints such as /health that are only served over HTTP
+func HTTPURL(url string) string {
+	lower := strings.ToLower(url)
+	switch {
+	case strings.HasPrefix(lower, "ws://"):
+		return "http://" + url[len("ws://"):]
+	case strings.HasPrefix(lower, "wss://"):
+		return "https://" + url[len("wss://"):]
+	}
+	return url
+}
+
+// ConnectionState describes a persistent WebSocket or IPC connection
+type ConnectionState struct {
+	Connected  bool   `json:"connected"`
+	Reconnects int    `json:"reconnects"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+// persistentConn is a persistent WebSocket or IPC connection that reconnects
+// with exponential backoff after it drops
+type persistentConn struct {
+	mu        sync.Mutex
+	client    *rpc.Client
+	dialed    bool
+	failures  int
+	retryAt   time.Time
+	lastError string
+	reconnect int
+}
+
+// persistentConnFor returns the connection to url for the options of ctx
+func persistentConnFor(ctx context.Context, url string) *persistentConn {
+	connsMu.Lock()
+	defer connsMu.Unlock()
+
+	key := connKey{options: optionsFor(ctx), url: url}
+	conn, ok := persistentConns[key]
+	if !ok {
+		conn = &persistentConn{}
+		persistentConns[key] = conn
+	}
+	return conn
+}
+
+// ConnectionStateFor returns the state of the persistent connection to url
+// made with the options of ctx, or nil when no such connection has been made
+func ConnectionStateFor(ctx context.Context, url string) *ConnectionState {
+	connsMu.Lock()
+	conn, ok := persistentConns[connKey{options: optionsFor(ctx), url: url}]
+	connsMu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return &ConnectionState{
+		Connected:  conn.client != nil,
+		Reconnects: conn.reconnect,
+		LastError:  conn.lastError,
+	}
+}
+
+// get returns the connected client, dialing when needed. Dials are skipped
+// until the backoff after the previous failure has passed.
+func (c *persistentConn) get(ctx context.Context, url string) (*rpc.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+	if wait := time.Until(c.retryAt); wait > 0 {
+		return nil, fmt.Errorf("reconnect in %s: %s", wait.Round(time.Millisecond), c.lastError)
+	}
+
+	client, err := Dial(ctx, url)
+	if err != nil {
+		c.failures++
+		backoff := min(time.Second<<min(c.failures, 5), reconnectMaxBackoff)
+		c.retryAt = time.Now().Add(backoff)
+		c.lastError = err.Error()
+		return nil, err
+	}
+
+	if c.dialed {
+		c.reconnect++
+	}
+	c.client = client
+	c.dialed = true
+	c.failures = 0
+	c.lastError = ""
+
+	return client, nil
+}
+
+// drop closes the connection after a transport error so the next call
+// reconnects. RPC errors and deadlines leave the connection in place.
+func (c *persistentConn) drop(err error) {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+	c.lastError = err.Error()
+}
+
+// Dial opens an RPC client for a WebSocket, IPC or HTTP endpoint. WebSocket
+// handshakes carry the headers and TLS config of the options of ctx and HTTP
+// requests go through their HTTP client.
+func Dial(ctx context.Context, url string) (*rpc.Client, error) {
+	if IsIPC(url) {
+		return rpc.DialIPC(ctx, IPCPath(url))
+	}
+
+	options := optionsFor(ctx)
+	headers := options.Headers
+
+	// Handshakes do not go through the HTTP client, so add the credentials
+	// here
+	if user := options.Credentials; user != nil && headers.Get("Authorization") == "" {
+		headers = headers.Clone()
+		if headers == nil {
+			headers = http.Header{}
+		}
+		headers.Set("Authorization", basicAuth(user))
+	}
+
+	// Mirrors the default dialer of go-ethereum
+	dialer := websocket.Dialer{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Proxy:           options.Proxy,
+		TLSClientConfig: options.TLSConfig,
+	}
+	return rpc.DialOptions(ctx, url, rpc.WithHTTPClient(options.httpClient()), rpc.WithHeaders(headers), rpc.WithWebsocketDialer(dialer))
+}
+
+// toRPCError converts errors returned by the go-ethereum RPC client into
+// RPCError so they match ErrRPCError like HTTP responses do
+func toRPCError(err error) error {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return &RPCError{Code: rpcErr.ErrorCode(), Message: rpcErr.Error()}
+	}
+	return err
+}
+
+// persistentCall sends a single JSON-RPC request over the persistent connection
+func persistentCall(ctx context.Context, url string, method string, params []interface{}) (*RPCResponse, error) {
+	conn := persistentConnFor(ctx, url)
+	client, err := conn.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var result json.RawMessage
+	if err := client.CallContext(ctx, &result, method, params...); err != nil {
+		conn.drop(err)
+		return nil, fmt.Errorf("%s: %w", method, toRPCError(err))
+	}
+
+	return &RPCResponse{JSONRPC: "2.0", Result: result, ID: 1}, nil
+}
+
+// SubscribeNewHeads subscribes to newHeads over the persistent connection to
+// url, so the subscription shares the connection with the calls made to url
+func SubscribeNewHeads(ctx context.Context, url string, headers chan<- *types.Header) (*rpc.ClientSubscription, error) {
+	conn := persistentConnFor(ctx, url)
+	client, err := conn.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := client.EthSubscribe(ctx, headers, "newHeads")
+	if err != nil {
+		conn.drop(err)
+		return nil, fmt.Errorf("eth_subscribe: %w", toRPCError(err))
+	}
+	return sub, nil
+}
+
+// DropConnection closes the persistent connection to url made with the
+// options of ctx after err, e.g. a failed subscription, so the next call
+// reconnects
+func DropConnection(ctx context.Context, url string, err error) {
+	persistentConnFor(ctx, url).drop(err)
+}
+
+// persistentBatch sends the requests as a batch over the persistent connection
+func persistentBatch(ctx context.Context, url string, requests []BatchRequest) ([]RPCResponse, error) {
+	conn := persistentConnFor(ctx, url)
+	client, err := conn.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]json.RawMessage, len(requests))
+	elems := make([]rpc.BatchElem, len(requests))
+	for i, request := range requests {
+		elems[i] = rpc.BatchElem{Method: request.Method, Args: request.Params, Result: &results[i]}
+	}
+	if err := client.BatchCallContext(ctx, elems); err != nil {
+		conn.drop(err)
+		return nil, err
+	}
+
+	responses := make([]RPCResponse, len(requests))
+	for i, elem := range elems {
+		responses[i] = RPCResponse{JSONRPC: "2.0", Result: results[i], ID: i + 1}
+		if elem.Error != nil {
+			rpcErr, ok := toRPCError(elem.Error).(*RPCError)
+			if !ok {
+				rpcErr = &RPCError{Message: elem.Error.Error()}
+			}
+			responses[i].Error = rpcErr
+		}
+	}
+
+	return responses, nil
+}
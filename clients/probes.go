@@ -0,0 +1,90 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// GetLogs calls eth_getLogs over the inclusive block range without an
+// address filter and returns the number of logs returned
+func GetLogs(ctx context.Context, url string, fromBlock, toBlock uint64) (int, error) {
+	filter := map[string]interface{}{
+		"fromBlock": hexutil.EncodeUint64(fromBlock),
+		"toBlock":   hexutil.EncodeUint64(toBlock),
+	}
+	rpcResponse, err := call(ctx, url, "eth_getLogs", filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var logs []json.RawMessage
+	if err := decodeResult(rpcResponse, &logs); err != nil {
+		return 0, err
+	}
+	return len(logs), nil
+}
+
+// Balance returns the balance of address at block, which is either a block
+// tag such as "latest" or a hex block number
+func Balance(ctx context.Context, url string, address common.Address, block string) (*big.Int, error) {
+	rpcResponse, err := call(ctx, url, "eth_getBalance", address, block)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance hexutil.Big
+	if err := decodeResult(rpcResponse, &balance); err != nil {
+		return nil, err
+	}
+	return balance.ToInt(), nil
+}
+
+// Call executes a read-only eth_call of data against the contract at to and
+// returns the raw return data
+func Call(ctx context.Context, url string, to common.Address, data []byte, block string) ([]byte, error) {
+	msg := map[string]interface{}{
+		"to":   to,
+		"data": hexutil.Bytes(data),
+	}
+	rpcResponse, err := call(ctx, url, "eth_call", msg, block)
+	if err != nil {
+		return nil, err
+	}
+
+	var output hexutil.Bytes
+	if err := decodeResult(rpcResponse, &output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// TraceMethod returns the method used to trace a block on clientType. Erigon
+// and Nethermind serve the Parity-style trace module, the other clients only
+// the debug namespace.
+func TraceMethod(clientType string) string {
+	switch clientType {
+	case "Erigon", "Nethermind":
+		return "trace_block"
+	default:
+		return "debug_traceBlockByNumber"
+	}
+}
+
+// TraceBlock traces every transaction of the block with the given number
+// using the method TraceMethod picks for clientType, discarding the traces
+func TraceBlock(ctx context.Context, url string, clientType string, number uint64) error {
+	block := hexutil.EncodeUint64(number)
+
+	var err error
+	if method := TraceMethod(clientType); method == "trace_block" {
+		_, err = call(ctx, url, method, block)
+	} else {
+		// The call tracer is far cheaper than the default struct logger
+		_, err = call(ctx, url, method, block, map[string]string{"tracer": "callTracer"})
+	}
+	return err
+}
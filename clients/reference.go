@@ -0,0 +1,39 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// referenceTransport returns a transport like http.DefaultTransport that goes
+// through proxy
+func referenceTransport(proxy func(*http.Request) (*url.URL, error)) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	return transport
+}
+
+// ReferenceBlockNumber returns the eth_blockNumber of an independent HTTP
+// endpoint used to verify that the node follows the canonical chain
+func ReferenceBlockNumber(ctx context.Context, url string) (uint64, error) {
+	body, err := postWith(ctx, optionsFor(ctx).references(), url, newRequest(1, "eth_blockNumber", nil))
+	if err != nil {
+		return 0, err
+	}
+
+	var rpcResponse RPCResponse
+	if err := json.Unmarshal(body, &rpcResponse); err != nil {
+		return 0, fmt.Errorf("malformed json-rpc response to eth_blockNumber: %w", err)
+	}
+	if rpcResponse.Error != nil {
+		return 0, fmt.Errorf("eth_blockNumber: %w", rpcResponse.Error)
+	}
+	if len(rpcResponse.Result) == 0 {
+		return 0, fmt.Errorf("eth_blockNumber: %w", ErrEmptyResult)
+	}
+
+	return parseQuantity(rpcResponse.Result)
+}
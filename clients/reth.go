@@ -0,0 +1,86 @@
+package clients
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// rethCheckpointMetric is the Reth gauge holding the block checkpoint of each
+// pipeline stage, labeled by stage
+const rethCheckpointMetric = "reth_sync_checkpoint"
+
+// RethStages scrapes the Reth Prometheus endpoint and returns the checkpoint
+// of every pipeline stage. Reth may report eth_syncing false between stages,
+// while the checkpoints show that the pipeline is still running.
+func RethStages(ctx context.Context, metricsURL string) ([]SyncStage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metricsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := clientFor(ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	var stages []SyncStage
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		stage, ok, err := parseRethCheckpoint(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			stages = append(stages, stage)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("%s not found at %s: %w", rethCheckpointMetric, metricsURL, ErrEmptyResult)
+	}
+
+	return stages, nil
+}
+
+// parseRethCheckpoint parses a Prometheus text exposition line such as
+// reth_sync_checkpoint{stage="Headers"} 19000000, reporting false for lines
+// that hold other metrics
+func parseRethCheckpoint(line string) (SyncStage, bool, error) {
+	if !strings.HasPrefix(line, rethCheckpointMetric+"{") {
+		return SyncStage{}, false, nil
+	}
+
+	labels, value, found := strings.Cut(line[len(rethCheckpointMetric)+1:], "} ")
+	if !found {
+		return SyncStage{}, false, fmt.Errorf("malformed metric line %q", line)
+	}
+
+	var stage SyncStage
+	for _, label := range strings.Split(labels, ",") {
+		if name, quoted, ok := strings.Cut(label, "="); ok && name == "stage" {
+			stage.Name = strings.Trim(quoted, `"`)
+		}
+	}
+	if stage.Name == "" {
+		return SyncStage{}, false, nil
+	}
+
+	// The value may be followed by a timestamp
+	number, err := strconv.ParseFloat(strings.Fields(value)[0], 64)
+	if err != nil {
+		return SyncStage{}, false, fmt.Errorf("malformed metric line %q: %w", line, err)
+	}
+	stage.BlockNumber = uint64(number)
+
+	return stage, true, nil
+}
@@ -0,0 +1,63 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// SyncStatus is the decoded result of eth_syncing
+type SyncStatus struct {
+	Syncing       bool   `json:"syncing"`
+	StartingBlock uint64 `json:"starting_block,omitempty"`
+	CurrentBlock  uint64 `json:"current_block,omitempty"`
+	HighestBlock  uint64 `json:"highest_block,omitempty"`
+
+	// Stages is only reported by Erigon and Reth
+	Stages []SyncStage `json:"stages,omitempty"`
+
+	// Nitro is only reported by Arbitrum Nitro
+	Nitro *NitroProgress `json:"nitro,omitempty"`
+}
+
+// syncProgress holds the progress fields shared by every client. Extra fields
+// such as Geth's state sync counters are ignored.
+type syncProgress struct {
+	StartingBlock hexutil.Uint64 `json:"startingBlock"`
+	CurrentBlock  hexutil.Uint64 `json:"currentBlock"`
+	HighestBlock  hexutil.Uint64 `json:"highestBlock"`
+}
+
+// CheckSyncStatus calls eth_syncing, which returns either false or an object
+// describing the sync progress
+func CheckSyncStatus(ctx context.Context, url string) (*SyncStatus, error) {
+	rpcResponse, err := call(ctx, url, "eth_syncing")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSyncStatus(rpcResponse.Result)
+}
+
+func parseSyncStatus(result json.RawMessage) (*SyncStatus, error) {
+	var syncing bool
+	if err := json.Unmarshal(result, &syncing); err == nil {
+		return &SyncStatus{Syncing: syncing}, nil
+	}
+
+	var progress syncProgress
+	if err := json.Unmarshal(result, &progress); err != nil {
+		return nil, fmt.Errorf("unexpected eth_syncing result %s: %w", result, err)
+	}
+
+	return &SyncStatus{
+		Syncing:       true,
+		StartingBlock: uint64(progress.StartingBlock),
+		CurrentBlock:  uint64(progress.CurrentBlock),
+		HighestBlock:  uint64(progress.HighestBlock),
+		Stages:        parseStages(result),
+		Nitro:         parseNitroProgress(result),
+	}, nil
+}
@@ -0,0 +1,81 @@
+package clients
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseSyncStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		result string
+		want   *SyncStatus
+	}{
+		{name: "synced", result: `false`, want: &SyncStatus{}},
+		{
+			// Geth 1.14 adds the snap sync counters, which are ignored
+			name: "geth",
+			result: `{"startingBlock":"0x13a8a00","currentBlock":"0x13b2d1f","highestBlock":"0x13c1f80",` +
+				`"syncedAccounts":"0x1a2b3c","syncedAccountBytes":"0x3b9aca00","syncedBytecodes":"0x2710","syncedBytecodeBytes":"0x5f5e100",` +
+				`"syncedStorage":"0x4c4b40","syncedStorageBytes":"0x77359400","healedTrienodes":"0x0","healedTrienodeBytes":"0x0",` +
+				`"healedBytecodes":"0x0","healedBytecodeBytes":"0x0","healingTrienodes":"0x0","healingBytecode":"0x0",` +
+				`"txIndexFinishedBlocks":"0x0","txIndexRemainingBlocks":"0x1"}`,
+			want: &SyncStatus{Syncing: true, StartingBlock: 20613632, CurrentBlock: 20655391, HighestBlock: 20717440},
+		},
+		{
+			name: "erigon",
+			result: `{"startingBlock":"0x0","currentBlock":"0x13b2d1f","highestBlock":"0x13c1f80","stages":[` +
+				`{"stage_name":"Snapshots","block_number":"0x13c1f80"},{"stage_name":"Headers","block_number":"0x13c1f80"},` +
+				`{"stage_name":"Execution","block_number":"0x13b2d1f"},{"stage_name":"Finish","block_number":"0x13b2d1f"}]}`,
+			want: &SyncStatus{
+				Syncing:      true,
+				CurrentBlock: 20655391,
+				HighestBlock: 20717440,
+				Stages: []SyncStage{
+					{Name: "Snapshots", BlockNumber: 20717440},
+					{Name: "Headers", BlockNumber: 20717440},
+					{Name: "Execution", BlockNumber: 20655391},
+					{Name: "Finish", BlockNumber: 20655391},
+				},
+			},
+		},
+		{
+			name:   "reth",
+			result: `{"startingBlock":"0x0","currentBlock":"0x0","highestBlock":"0x13c1f80","stages":[{"name":"Headers","block":"0x13c1f80"},{"name":"Bodies","block":"0x1000"}]}`,
+			want: &SyncStatus{
+				Syncing:      true,
+				HighestBlock: 20717440,
+				Stages:       []SyncStage{{Name: "Headers", BlockNumber: 20717440}, {Name: "Bodies", BlockNumber: 4096}},
+			},
+		},
+		{
+			// Missing quantities read as zero
+			name:   "missing fields",
+			result: `{"currentBlock":"0x10"}`,
+			want:   &SyncStatus{Syncing: true, CurrentBlock: 16},
+		},
+		{name: "decimal quantity", result: `{"currentBlock":"16"}`},
+		{name: "number quantity", result: `{"currentBlock":16}`},
+		{name: "leading zero", result: `{"currentBlock":"0x010"}`},
+		{name: "string", result: `"syncing"`},
+		{name: "array", result: `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSyncStatus(json.RawMessage(tt.result))
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("parseSyncStatus() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSyncStatus() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSyncStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
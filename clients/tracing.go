@@ -0,0 +1,106 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of the spans started by this package
+const tracerName = "github.com/rarecrumb/medic/clients"
+
+// TracingTransport records a span for every request sent through it, named
+// after the JSON-RPC method, and propagates the trace context to the node.
+// Requests outside a sampled trace are passed through untouched.
+type TracingTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !trace.SpanFromContext(req.Context()).IsRecording() {
+		return t.base().RoundTrip(req)
+	}
+
+	// Requests must not be modified by a RoundTripper, so trace a copy
+	req = req.Clone(req.Context())
+	methods := requestMethods(req)
+	name := req.Method + " " + req.URL.Path
+	if len(methods) != 0 {
+		name = strings.Join(methods, ",")
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(req.Context(), name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.StringSlice("rpc.method", methods),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("http.request.method", req.Method),
+		),
+	)
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, fmt.Sprintf("unexpected status %d", resp.StatusCode))
+	}
+	return resp, nil
+}
+
+func (t *TracingTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// requestMethods returns the JSON-RPC methods in the body of req, restoring
+// the body for the request that is sent. Bodies that are not JSON-RPC yield
+// no methods.
+func requestMethods(req *http.Request) []string {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	type call struct {
+		Method string `json:"method"`
+	}
+	var batch []call
+	if err := json.Unmarshal(body, &batch); err != nil {
+		var single call
+		if err := json.Unmarshal(body, &single); err != nil || single.Method == "" {
+			return nil
+		}
+		batch = []call{single}
+	}
+
+	methods := make([]string, 0, len(batch))
+	for _, c := range batch {
+		methods = append(methods, c.Method)
+	}
+	return methods
+}
@@ -0,0 +1,31 @@
+package clients
+
+import "net/http"
+
+// HeaderTransport adds a fixed set of headers, such as authentication, to
+// every request sent through it
+type HeaderTransport struct {
+	Base    http.RoundTripper
+	Headers http.Header
+}
+
+func (t *HeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.Headers) == 0 {
+		return t.base().RoundTrip(req)
+	}
+
+	// Requests must not be modified by a RoundTripper, so add headers to a copy
+	req = req.Clone(req.Context())
+	for key, values := range t.Headers {
+		req.Header[key] = values
+	}
+
+	return t.base().RoundTrip(req)
+}
+
+func (t *HeaderTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
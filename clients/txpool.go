@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// TxPoolStatus is the number of transactions waiting in the node's txpool
+type TxPoolStatus struct {
+	Pending uint64 `json:"pending"`
+	Queued  uint64 `json:"queued"`
+}
+
+// TxPool returns the txpool counts of the node. Geth, Erigon, Reth and
+// Nethermind serve txpool_status, with hex or decimal counts, while Besu only
+// reports the pool size through txpool_besuStatistics.
+func TxPool(ctx context.Context, url string, clientType string) (*TxPoolStatus, error) {
+	if clientType == "Besu" {
+		return besuTxPool(ctx, url)
+	}
+
+	rpcResponse, err := call(ctx, url, "txpool_status")
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Pending json.RawMessage `json:"pending"`
+		Queued  json.RawMessage `json:"queued"`
+	}
+	if err := json.Unmarshal(rpcResponse.Result, &result); err != nil {
+		return nil, fmt.Errorf("unexpected txpool_status result %s: %w", rpcResponse.Result, err)
+	}
+
+	pending, err := parseQuantity(result.Pending)
+	if err != nil {
+		return nil, fmt.Errorf("txpool_status pending: %w", err)
+	}
+	queued, err := parseQuantity(result.Queued)
+	if err != nil {
+		return nil, fmt.Errorf("txpool_status queued: %w", err)
+	}
+	return &TxPoolStatus{Pending: pending, Queued: queued}, nil
+}
+
+// besuTxPool counts every transaction in Besu's pool as pending since Besu
+// does not separate queued transactions
+func besuTxPool(ctx context.Context, url string) (*TxPoolStatus, error) {
+	rpcResponse, err := call(ctx, url, "txpool_besuStatistics")
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		LocalCount  json.RawMessage `json:"localCount"`
+		RemoteCount json.RawMessage `json:"remoteCount"`
+	}
+	if err := json.Unmarshal(rpcResponse.Result, &result); err != nil {
+		return nil, fmt.Errorf("unexpected txpool_besuStatistics result %s: %w", rpcResponse.Result, err)
+	}
+
+	local, err := parseQuantity(result.LocalCount)
+	if err != nil {
+		return nil, fmt.Errorf("txpool_besuStatistics localCount: %w", err)
+	}
+	remote, err := parseQuantity(result.RemoteCount)
+	if err != nil {
+		return nil, fmt.Errorf("txpool_besuStatistics remoteCount: %w", err)
+	}
+	return &TxPoolStatus{Pending: local + remote}, nil
+}
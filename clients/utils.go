@@ -2,56 +2,191 @@ package clients
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
+// DefaultTimeout bounds every request made by this package. Callers can
+// tighten it further through the request context.
+const DefaultTimeout = 10 * time.Second
+
 // RPCResponse represents a standard JSON-RPC response
 type RPCResponse struct {
-	JSONRPC string `json:"jsonrpc"`
-	Result  string `json:"result"`
-	ID      int    `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      int             `json:"id"`
 }
 
-// DetectClientType determines the type of Ethereum client by calling web3_clientVersion
-func DetectClientType(url string) (string, error) {
-	// JSON-RPC request payload
-	payload := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"method":  "web3_clientVersion",
-		"params":  []interface{}{},
-		"id":      1,
+// decodeResult unmarshals the result of a JSON-RPC response into out,
+// leaving out untouched when the response carries no result
+func decodeResult(resp *RPCResponse, out interface{}) error {
+	if len(resp.Result) == 0 {
+		return nil
 	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+// post sends a JSON-RPC payload to the node and returns the raw response body
+func post(ctx context.Context, url string, payload interface{}) ([]byte, error) {
+	return postWith(ctx, clientFor(ctx), url, payload)
+}
+
+// postWith sends a JSON-RPC payload through client
+func postWith(ctx context.Context, client *http.Client, url string, payload interface{}) ([]byte, error) {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Send the request
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(payloadBytes))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// Read and unmarshal the response
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// newRequest builds a JSON-RPC request payload
+func newRequest(id int, method string, params []interface{}) map[string]interface{} {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"id":      id,
+	}
+}
+
+// call sends a single JSON-RPC request and decodes the response. WebSocket
+// and IPC URLs are served over a persistent connection.
+func call(ctx context.Context, url string, method string, params ...interface{}) (*RPCResponse, error) {
+	if IsPersistent(url) {
+		return persistentCall(ctx, url, method, params)
+	}
+
+	body, err := post(ctx, url, newRequest(1, method, params))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+
+	// Unmarshal the response
 	var rpcResponse RPCResponse
 	err = json.Unmarshal(body, &rpcResponse)
 	if err != nil {
+		return nil, fmt.Errorf("malformed json-rpc response to %s: %w", method, err)
+	}
+	if rpcResponse.Error != nil {
+		return nil, fmt.Errorf("%s: %w", method, rpcResponse.Error)
+	}
+
+	return &rpcResponse, nil
+}
+
+// ClientVersion returns the raw web3_clientVersion string reported by the node
+func ClientVersion(ctx context.Context, url string) (string, error) {
+	rpcResponse, err := call(ctx, url, "web3_clientVersion")
+	if err != nil {
+		return "", err
+	}
+	var version string
+	if err := decodeResult(rpcResponse, &version); err != nil {
+		return "", err
+	}
+	if version == "" {
+		return "", fmt.Errorf("web3_clientVersion: %w", ErrEmptyResult)
+	}
+
+	return version, nil
+}
+
+// DetectClientType identifies the Ethereum client by calling web3_clientVersion
+func DetectClientType(ctx context.Context, url string) (ClientInfo, error) {
+	version, err := ClientVersion(ctx, url)
+	if err != nil {
+		return ClientInfo{}, err
+	}
+
+	return ParseClientVersion(version), nil
+}
+
+// NetVersion returns the network ID reported by net_version. It is the
+// cheapest call every client serves, which makes it a good reachability check.
+func NetVersion(ctx context.Context, url string) (string, error) {
+	rpcResponse, err := call(ctx, url, "net_version")
+	if err != nil {
+		return "", err
+	}
+	var version string
+	if err := decodeResult(rpcResponse, &version); err != nil {
 		return "", err
 	}
 
-	// Determine the client type
-	if strings.Contains(rpcResponse.Result, "Nethermind") {
-		return "Nethermind", nil
+	return version, nil
+}
+
+// parseQuantity decodes a JSON-RPC quantity, accepting both the standard hex
+// encoding and plain decimal numbers or strings returned by some clients
+func parseQuantity(raw json.RawMessage) (uint64, error) {
+	var hex hexutil.Uint64
+	if err := json.Unmarshal(raw, &hex); err == nil {
+		return uint64(hex), nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return 0, fmt.Errorf("invalid quantity %s: %w", raw, err)
+	}
+	value, err := strconv.ParseUint(number.String(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %s: %w", raw, err)
+	}
+	return value, nil
+}
+
+// PeerCount returns the number of peers reported by net_peerCount
+func PeerCount(ctx context.Context, url string) (uint64, error) {
+	rpcResponse, err := call(ctx, url, "net_peerCount")
+	if err != nil {
+		return 0, err
+	}
+	if len(rpcResponse.Result) == 0 {
+		return 0, fmt.Errorf("net_peerCount: %w", ErrEmptyResult)
+	}
+
+	return parseQuantity(rpcResponse.Result)
+}
+
+// ChainID returns the chain ID reported by eth_chainId
+func ChainID(ctx context.Context, url string) (uint64, error) {
+	rpcResponse, err := call(ctx, url, "eth_chainId")
+	if err != nil {
+		return 0, err
+	}
+	if len(rpcResponse.Result) == 0 {
+		return 0, fmt.Errorf("eth_chainId: %w", ErrEmptyResult)
 	}
-	// Add additional client checks as needed
 
-	return "Unknown", nil
+	return parseQuantity(rpcResponse.Result)
 }
@@ -0,0 +1,163 @@
+package clients_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/clients/clienttest"
+)
+
+// Every call gives up at the deadline of its context, even when the node
+// never answers
+func TestCallsHonorDeadline(t *testing.T) {
+	node := clienttest.NewServer()
+	defer node.Close()
+	node.SetLatency(5 * time.Second)
+	node.SetHealth(http.StatusOK, clienttest.NethermindHealthy)
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{name: "DetectClientType", call: func(ctx context.Context) error {
+			_, err := clients.DetectClientType(ctx, node.URL)
+			return err
+		}},
+		{name: "PeerCount", call: func(ctx context.Context) error {
+			_, err := clients.PeerCount(ctx, node.URL)
+			return err
+		}},
+		{name: "BlockByTag", call: func(ctx context.Context) error {
+			_, err := clients.BlockByTag(ctx, node.URL, "latest")
+			return err
+		}},
+		{name: "CheckSyncStatus", call: func(ctx context.Context) error {
+			_, err := clients.CheckSyncStatus(ctx, node.URL)
+			return err
+		}},
+		{name: "NethermindHealthCheck", call: func(ctx context.Context) error {
+			_, err := clients.NethermindHealthCheck(ctx, node.URL)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			err := tt.call(ctx)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want a deadline error", err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("call returned after %s, past the 100ms deadline", elapsed)
+			}
+		})
+	}
+}
+
+func TestDetectClientTypeErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(node *clienttest.Server)
+		clientType string
+		target     error
+		code       int
+		status     int
+	}{
+		{
+			name:       "version",
+			setup:      func(node *clienttest.Server) {},
+			clientType: "Geth",
+		},
+		{
+			name:   "web3 namespace disabled",
+			setup:  func(node *clienttest.Server) { node.Unhandle("web3_clientVersion") },
+			target: clients.ErrRPCError,
+			code:   -32601,
+		},
+		{
+			name:   "error object",
+			setup:  func(node *clienttest.Server) { node.HandleError("web3_clientVersion", -32000, "method not allowed") },
+			target: clients.ErrRPCError,
+			code:   -32000,
+		},
+		{
+			name:   "empty result",
+			setup:  func(node *clienttest.Server) { node.Handle("web3_clientVersion", "") },
+			target: clients.ErrEmptyResult,
+		},
+		{
+			name:   "null result",
+			setup:  func(node *clienttest.Server) { node.Handle("web3_clientVersion", nil) },
+			target: clients.ErrEmptyResult,
+		},
+		{
+			name: "rate limited",
+			setup: func(node *clienttest.Server) {
+				node.HandleStatus("web3_clientVersion", http.StatusTooManyRequests, `{"error":"too many requests"}`)
+			},
+			target: clients.ErrHTTPStatus,
+			status: http.StatusTooManyRequests,
+		},
+		{
+			name:   "unauthorized",
+			setup:  func(node *clienttest.Server) { node.HandleStatus("web3_clientVersion", http.StatusUnauthorized, "") },
+			target: clients.ErrHTTPStatus,
+			status: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			tt.setup(node)
+
+			info, err := clients.DetectClientType(context.Background(), node.URL)
+			if tt.target == nil {
+				if err != nil || info.Type != tt.clientType {
+					t.Fatalf("DetectClientType() = %+v, %v, want %s", info, err, tt.clientType)
+				}
+				return
+			}
+			if !errors.Is(err, tt.target) {
+				t.Fatalf("error = %v, want %v", err, tt.target)
+			}
+			if info.Type != "" {
+				t.Errorf("client type = %q on error, want none", info.Type)
+			}
+
+			var rpcErr *clients.RPCError
+			if tt.code != 0 && (!errors.As(err, &rpcErr) || rpcErr.Code != tt.code) {
+				t.Errorf("error = %v, want json-rpc error code %d", err, tt.code)
+			}
+			var statusErr *clients.HTTPStatusError
+			if tt.status != 0 && (!errors.As(err, &statusErr) || statusErr.StatusCode != tt.status) {
+				t.Errorf("error = %v, want http status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestDetectClientTypeMalformed(t *testing.T) {
+	for _, body := range []string{`{"jsonrpc":"2.0","id":1,"result":`, `<html>Bad Gateway</html>`, `{"jsonrpc":"2.0","id":1,"result":42}`} {
+		node := clienttest.NewServer()
+		node.HandleRaw("web3_clientVersion", body)
+
+		_, err := clients.DetectClientType(context.Background(), node.URL)
+		node.Close()
+
+		if err == nil {
+			t.Errorf("DetectClientType() accepted %s", body)
+			continue
+		}
+		if errors.Is(err, clients.ErrRPCError) || errors.Is(err, clients.ErrHTTPStatus) {
+			t.Errorf("error for %s = %v, want a decoding error", body, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+)
+
+// clockSkewWarning is how far in the future a block may appear before the
+// local clock is assumed to be wrong
+const clockSkewWarning = 2 * time.Second
+
+// headDelta returns the time between a block timestamp and the local clock,
+// less the clock skew tolerance. Blocks that appear to be from the future
+// count as fresh.
+func headDelta(timestamp uint64) time.Duration {
+	raw := time.Since(time.Unix(int64(timestamp), 0))
+	if raw < -clockSkewWarning {
+		log.Warn().
+			Dur("ahead", -raw).
+			Msg("Latest block is from the future, the local clock is probably behind")
+	}
+
+	delta := raw - settings().GetDuration("clock-skew-tolerance")
+	if delta < 0 {
+		return 0
+	}
+	return delta
+}
+
+var (
+	beaconClockMu sync.Mutex
+	beaconClock   *clients.BeaconClock
+)
+
+// beaconClockInfo returns the slot timing of the beacon chain. It is fetched
+// once and retried on the next call after a failure.
+func beaconClockInfo(ctx context.Context, url string) (*clients.BeaconClock, error) {
+	beaconClockMu.Lock()
+	defer beaconClockMu.Unlock()
+
+	if beaconClock == nil {
+		clock, err := clients.BeaconClockInfo(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		beaconClock = clock
+	}
+	return beaconClock, nil
+}
+
+// checkBeaconClock warns when the beacon head slot starts in the future,
+// which means that the local clock is behind the chain
+func checkBeaconClock(ctx context.Context, url string, headSlot uint64) {
+	clock, err := beaconClockInfo(ctx, url)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to retrieve the beacon chain slot timing")
+		return
+	}
+
+	ahead := time.Until(clock.SlotStart(headSlot))
+	if ahead > settings().GetDuration("clock-skew-tolerance")+clockSkewWarning {
+		log.Warn().
+			Uint64("head_slot", headSlot).
+			Dur("ahead", ahead).
+			Msg("Beacon head slot starts in the future, the local clock is probably behind")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/clients/clienttest"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// captureLogs collects the log output of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&logs)
+	t.Cleanup(func() { log.Logger = logger })
+	return &logs
+}
+
+func TestHeadDelta(t *testing.T) {
+	tests := []struct {
+		name      string
+		age       time.Duration
+		tolerance time.Duration
+		min, max  time.Duration
+		warn      bool
+	}{
+		{name: "past", age: 10 * time.Second, min: 9 * time.Second, max: 11 * time.Second},
+		{name: "past with tolerance", age: 10 * time.Second, tolerance: 4 * time.Second, min: 5 * time.Second, max: 7 * time.Second},
+		{name: "within tolerance", age: 3 * time.Second, tolerance: 5 * time.Second},
+		// Blocks slightly ahead of the local clock are normal and quiet
+		{name: "slightly in the future", age: -time.Second},
+		{name: "future", age: -30 * time.Second, warn: true},
+		{name: "far future", age: -24 * time.Hour, warn: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSettings(t, map[string]interface{}{"clock-skew-tolerance": tt.tolerance})
+			logs := captureLogs(t)
+
+			delta := headDelta(uint64(time.Now().Add(-tt.age).Unix()))
+
+			if delta < tt.min || delta > tt.max {
+				t.Errorf("delta = %v, want between %v and %v", delta, tt.min, tt.max)
+			}
+			if warned := strings.Contains(logs.String(), "from the future"); warned != tt.warn {
+				t.Errorf("warned = %v, want %v, logs %s", warned, tt.warn, logs)
+			}
+		})
+	}
+}
+
+// A block from the future counts as fresh and never as a negative or wrapped
+// delta, whichever way the head is measured
+func TestMeasureFutureBlock(t *testing.T) {
+	for _, batches := range []bool{true, false} {
+		name := "batch"
+		if !batches {
+			name = "individual"
+		}
+		t.Run(name, func(t *testing.T) {
+			useSettings(t, nil)
+			node := clienttest.NewServer()
+			defer node.Close()
+			node.RejectBatches(!batches)
+			node.Handle("eth_getBlockByNumber", clienttest.Block(1234, time.Now().Add(time.Hour)))
+
+			client := newNodeClient("", node.URL)
+			client.forceClientType("Geth")
+
+			ctx := context.Background()
+			m := measure(ctx, client)
+			if err := m.Errors["block_delta"]; err != nil {
+				t.Fatalf("block_delta error: %v", err)
+			}
+			if m.BlockDelta != 0 {
+				t.Errorf("block delta = %v, want 0", m.BlockDelta)
+			}
+
+			result := evaluate(ctx, m, health.Thresholds{MaxBlockAge: 30 * time.Second})
+			if check := result.Checks["block_delta"]; !check.OK || check.Value != 0 {
+				t.Errorf("block_delta = %+v, want ok with value 0", check)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/pflag"
+)
+
+// command is a subcommand of medic
+type command struct {
+	name    string
+	summary string
+	// flags reports whether the command accepts a flag, nil accepts all
+	flags func(name string) bool
+	run   func() int
+}
+
+var commands = []command{
+	{name: "serve", summary: "Serve the health endpoints until terminated (default)", run: runServe},
+	{name: "check", summary: "Check the node once, print the result as JSON and exit non-zero if unhealthy", run: runCheck},
+	{name: "detect", summary: "Print the client detected at eth-url as JSON", flags: detectFlag, run: runDetect},
+	{name: "version", summary: "Print the version and exit", flags: func(string) bool { return false }, run: runVersion},
+}
+
+// sharedFlags are accepted by every command but version
+var sharedFlags = map[string]bool{
+	"config":     true,
+	"eth-url":    true,
+	"log-level":  true,
+	"log-format": true,
+}
+
+// detectFlag reports whether detect accepts a flag: the shared flags and the
+// ones that shape requests to the node
+func detectFlag(name string) bool {
+	return sharedFlags[name] || name == "timeout" || strings.HasPrefix(name, "rpc-") || strings.HasPrefix(name, "retry-")
+}
+
+// selectedCommand is the command given on the command line
+var selectedCommand = commands[0]
+
+// implicitServe is set when medic runs without a command
+var implicitServe bool
+
+// parseCommand selects the command named by the first argument and returns
+// the remaining arguments. Without a command medic serves, as it did before
+// commands existed.
+func parseCommand(args []string) []string {
+	if len(args) != 0 && args[0] == "--version" {
+		selectedCommand = lookupCommand("version")
+		return args[1:]
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		implicitServe = true
+		return args
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			selectedCommand = cmd
+			return args[1:]
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	implicitServe = true
+	usage()
+	os.Exit(2)
+	return nil
+}
+
+func lookupCommand(name string) command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	panic("unknown command " + name)
+}
+
+// checkCommandFlags exits when a flag the selected command does not accept
+// is given on the command line
+func checkCommandFlags() {
+	if selectedCommand.flags == nil {
+		return
+	}
+	pflag.Visit(func(flag *pflag.Flag) {
+		if !selectedCommand.flags(flag.Name) {
+			fmt.Fprintf(os.Stderr, "flag --%s is not supported by medic %s\n", flag.Name, selectedCommand.name)
+			os.Exit(2)
+		}
+	})
+}
+
+// usage prints the commands and the flags accepted by the selected command
+func usage() {
+	if implicitServe {
+		fmt.Fprintf(os.Stderr, "Usage: medic [command] [flags]\n\nCommands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+		}
+		fmt.Fprintf(os.Stderr, "\nFlags of serve and check:\n")
+	} else {
+		fmt.Fprintf(os.Stderr, "Usage: medic %s [flags]\n\n%s\n\nFlags:\n", selectedCommand.name, selectedCommand.summary)
+	}
+
+	flags := pflag.NewFlagSet(selectedCommand.name, pflag.ContinueOnError)
+	pflag.VisitAll(func(flag *pflag.Flag) {
+		if selectedCommand.flags == nil || selectedCommand.flags(flag.Name) {
+			flags.AddFlag(flag)
+		}
+	})
+	fmt.Fprint(os.Stderr, flags.FlagUsages())
+}
+
+// runServe serves the health endpoints
+func runServe() int {
+	if implicitServe {
+		log.Warn().Msg("Running medic without a command is deprecated, use medic serve")
+	}
+	if settings().GetBool("one-shot") {
+		log.Warn().Msg("The one-shot setting is deprecated, use medic check")
+	}
+	run()
+	return 0
+}
+
+// runCheck checks the node once, exiting with the code of runOneShot
+func runCheck() int {
+	settings().Set("one-shot", true)
+	run()
+	return 0
+}
+
+// runDetect prints the client detected at eth-url as JSON, returning 1 when
+// the node cannot be reached
+func runDetect() int {
+	if err := validateConfig(settings()); err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	configureRPC()
+
+	node := newNodeClient("", settings().GetString("eth-url"))
+	ctx, cancel := context.WithTimeout(node.context(context.Background()), settings().GetDuration("timeout"))
+	defer cancel()
+
+	info, err := clients.DetectClientType(ctx, node.url)
+	if err != nil {
+		log.Error().Err(clients.WithoutURL(err)).Msg("Failed to detect the client")
+		return 1
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(info); err != nil {
+		log.Error().Err(err).Msg("Failed to write the client info")
+		return 1
+	}
+	return 0
+}
+
+// runVersion prints the build information
+func runVersion() int {
+	info := buildInfo()
+	fmt.Printf("medic %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Printf("commit:     %s\n", info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Printf("built:      %s\n", info.BuildDate)
+	}
+	fmt.Printf("go version: %s\n", info.GoVersion)
+	return 0
+}
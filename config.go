@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// validateConfig rejects flag combinations medic cannot run with, checking
+// the settings of v so a reloaded config can be validated before it is used
+func validateConfig(v *viper.Viper) error {
+	if liveCheck := v.GetString("live-check"); liveCheck != "rpc" && liveCheck != "none" {
+		return fmt.Errorf("invalid live-check mode %q, expected rpc or none", liveCheck)
+	}
+
+	if v.GetInt("failure-threshold") < 1 || v.GetInt("success-threshold") < 1 {
+		return errors.New("failure and success thresholds must be at least 1")
+	}
+
+	if age, _ := durationSetting(v, "max-block-age", "max-seconds-behind"); age <= 0 {
+		return errors.New("max block age must be positive")
+	}
+	if maxHeadStall(v) < 0 {
+		return errors.New("max head stall must not be negative")
+	}
+	if v.GetDuration("probe-cache-ttl") < 0 {
+		return errors.New("probe cache ttl must not be negative")
+	}
+	if v.GetDuration("check-timeout") <= 0 {
+		return errors.New("check timeout must be positive")
+	}
+	if v.GetDuration("poll-interval") < 0 {
+		return errors.New("poll interval must not be negative")
+	}
+	if graphQLURL := v.GetString("graphql-url"); graphQLURL != "" {
+		if u, err := url.Parse(graphQLURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid graphql url, expected an http or https URL")
+		}
+	}
+	if builderURL := v.GetString("mev-boost-url"); builderURL != "" {
+		if u, err := url.Parse(builderURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid mev-boost url, expected an http or https URL")
+		}
+	}
+	if wsURL := v.GetString("ws-url"); wsURL != "" && !clients.IsWebSocket(wsURL) {
+		return errors.New("invalid ws url, expected a ws or wss URL")
+	}
+	if v.GetDuration("ws-head-timeout") < 0 {
+		return errors.New("ws head timeout must not be negative")
+	}
+	if v.GetDuration("max-rpc-latency") < 0 {
+		return errors.New("max rpc latency must not be negative")
+	}
+	if v.GetInt("rpc-latency-window") < 1 {
+		return errors.New("rpc latency window must be at least 1")
+	}
+	if p := v.GetFloat64("rpc-latency-percentile"); p <= 0 || p > 100 {
+		return errors.New("rpc latency percentile must be greater than 0 and at most 100")
+	}
+	if _, err := parseMaintenanceWindows(v); err != nil {
+		return err
+	}
+	if v.GetDuration("initial-grace-period") < 0 {
+		return errors.New("initial grace period must not be negative")
+	}
+	if from := v.GetString("initial-grace-from"); from != "start" && from != "reachable" {
+		return fmt.Errorf("unknown initial grace start %q, expected start or reachable", from)
+	}
+	if v.GetDuration("startup-stall-timeout") <= 0 {
+		return errors.New("startup stall timeout must be positive")
+	}
+	if webhook := v.GetString("slack-webhook-url"); webhook != "" {
+		if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid slack webhook url, expected an http or https URL")
+		}
+	}
+	if v.GetDuration("slack-escalate-after") < 0 || v.GetDuration("slack-min-interval") < 0 {
+		return errors.New("slack durations must not be negative")
+	}
+	if _, err := allowedPrefixes(v); err != nil {
+		return err
+	}
+	for _, heartbeat := range v.GetStringSlice("heartbeat-url") {
+		if u, err := url.Parse(heartbeat); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid heartbeat url for %s, expected an http or https URL", referenceEndpoint(heartbeat))
+		}
+	}
+	switch v.GetString("k8s-update") {
+	case "", "pod-label", "pod-condition":
+	default:
+		return fmt.Errorf("unknown k8s update mode %q, expected pod-label or pod-condition", v.GetString("k8s-update"))
+	}
+	if v.GetDuration("k8s-min-interval") < 0 {
+		return errors.New("k8s min interval must not be negative")
+	}
+	if v.GetDuration("hook-timeout") <= 0 {
+		return errors.New("hook timeout must be positive")
+	}
+	for _, key := range []string{"on-healthy-cmd", "on-unhealthy-cmd"} {
+		if command := v.GetString(key); command != "" && strings.TrimSpace(command) == "" {
+			return fmt.Errorf("%s is blank, leave it unset to run no hook", key)
+		}
+	}
+	if v.GetDuration("heartbeat-interval") <= 0 {
+		return errors.New("heartbeat interval must be positive")
+	}
+	if gateway := v.GetString("pushgateway-url"); gateway != "" {
+		if u, err := url.Parse(gateway); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid pushgateway url, expected an http or https URL")
+		}
+		if v.GetString("push-job") == "" {
+			return errors.New("push job must not be empty")
+		}
+		if _, err := pushGrouping(v); err != nil {
+			return err
+		}
+	}
+	if endpoint := v.GetString("otel-endpoint"); endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid otel endpoint, expected an http or https URL")
+		}
+	}
+	if ratio := v.GetFloat64("otel-sample-ratio"); ratio < 0 || ratio > 1 {
+		return fmt.Errorf("otel sample ratio %g must be between 0 and 1", ratio)
+	}
+	if (v.GetString("rpc-client-cert") == "") != (v.GetString("rpc-client-key") == "") {
+		return errors.New("rpc client cert and rpc client key must be set together")
+	}
+	if (v.GetString("tls-cert") == "") != (v.GetString("tls-key") == "") {
+		return errors.New("tls cert and tls key must be set together")
+	}
+	if v.GetString("tls-client-ca") != "" && v.GetString("tls-cert") == "" {
+		return errors.New("tls client ca requires a tls cert and key")
+	}
+	if v.GetInt("event-log-size") < 1 {
+		return errors.New("event log size must be at least 1")
+	}
+	if v.GetDuration("client-detect-interval") <= 0 {
+		return errors.New("client detect interval must be positive")
+	}
+	if v.GetUint64("getlogs-range") == 0 {
+		return errors.New("getlogs range must be at least 1 block")
+	}
+	if address := v.GetString("state-address"); !common.IsHexAddress(address) {
+		return fmt.Errorf("invalid state address %q", address)
+	}
+	if to := v.GetString("state-call-to"); to != "" && !common.IsHexAddress(to) {
+		return fmt.Errorf("invalid state call contract address %q", to)
+	}
+	if _, err := hexutil.Decode(v.GetString("state-call-data")); err != nil {
+		return fmt.Errorf("invalid state call data: %w", err)
+	}
+	minGas, maxGas := v.GetFloat64("min-gas-price"), v.GetFloat64("max-gas-price")
+	if minGas < 0 || maxGas < 0 {
+		return errors.New("gas price bounds must not be negative")
+	}
+	if maxGas != 0 && minGas > maxGas {
+		return fmt.Errorf("min gas price %g is greater than max gas price %g", minGas, maxGas)
+	}
+	for _, reference := range v.GetStringSlice("reference-url") {
+		if u, err := url.Parse(reference); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid reference url for %s, expected an http or https URL", referenceEndpoint(reference))
+		}
+	}
+	if _, err := pinnedBlocks(v); err != nil {
+		return err
+	}
+	if v.GetDuration("admin-peers-timeout") <= 0 {
+		return errors.New("admin peers timeout must be positive")
+	}
+	if v.GetDuration("clock-skew-tolerance") < 0 {
+		return errors.New("clock skew tolerance must not be negative")
+	}
+	specs, err := parseTargets(v)
+	if err != nil {
+		return err
+	}
+	if len(specs) != 0 {
+		if quorum := v.GetInt("quorum"); quorum < 1 || quorum > len(specs) {
+			return fmt.Errorf("quorum %d must be between 1 and the number of targets (%d)", quorum, len(specs))
+		}
+		if v.GetBool("one-shot") {
+			return errors.New("one-shot mode does not support targets, use eth-url")
+		}
+		if v.GetString("proxy-listen") != "" {
+			return errors.New("the rpc proxy does not support targets, use eth-url")
+		}
+		if v.GetString("state-output-file") != "" || v.GetString("healthy-touch-file") != "" {
+			return errors.New("state output files do not support targets, use eth-url")
+		}
+		if v.GetString("graphql-url") != "" {
+			return errors.New("graphql-url does not support targets, use eth-url")
+		}
+		if v.GetString("ws-url") != "" {
+			return errors.New("ws-url does not support targets, use eth-url")
+		}
+		if v.GetString("rollup-url") != "" {
+			return errors.New("rollup-url does not support targets, use eth-url")
+		}
+		if v.GetString("k8s-update") != "" {
+			return errors.New("kubernetes pod updates do not support targets, use eth-url")
+		}
+	}
+	if v.GetString("proxy-listen") != "" {
+		if clients.IsIPC(v.GetString("eth-url")) {
+			return errors.New("the rpc proxy requires an http, https, ws or wss eth-url")
+		}
+		// The proxy only reads the cached result so that it adds no load
+		if v.GetDuration("poll-interval") <= 0 {
+			return errors.New("the rpc proxy requires a positive poll interval")
+		}
+	}
+	if fallback := v.GetString("fallback-url"); fallback != "" {
+		if v.GetString("proxy-listen") == "" {
+			return errors.New("fallback url requires proxy-listen")
+		}
+		if u, err := url.Parse(fallback); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid fallback url for %s, expected an http or https URL", referenceEndpoint(fallback))
+		}
+	}
+	if v.GetInt("failback-after") < 1 {
+		return errors.New("failback after must be at least 1")
+	}
+	if v.GetDuration("trace-timeout") <= 0 {
+		return errors.New("trace timeout must be positive")
+	}
+	if _, err := selectChecks(v.GetString("checks")); err != nil {
+		return err
+	}
+	if clientType := v.GetString("client-type"); clientType != "" {
+		if _, ok := clients.ClientType(clientType); !ok {
+			return fmt.Errorf("unknown client type %q", clientType)
+		}
+	}
+	if v.GetDuration("subscription-timeout") <= 0 {
+		return errors.New("subscription timeout must be positive")
+	}
+
+	if v.GetInt("retry-max") < 0 {
+		return errors.New("retry max must not be negative")
+	}
+	waitMin, waitMax := v.GetDuration("retry-wait-min"), v.GetDuration("retry-wait-max")
+	if waitMin < 0 || waitMax < 0 {
+		return errors.New("retry wait durations must not be negative")
+	}
+	if waitMin > waitMax {
+		return fmt.Errorf("retry wait min %s is greater than retry wait max %s", waitMin, waitMax)
+	}
+
+	if _, err := rpcHeaders(v); err != nil {
+		return err
+	}
+	if _, err := rpcProxy(v); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// deprecatedSettings maps the whole-seconds flags to the duration flags that
+// replace them
+var deprecatedSettings = map[string]string{
+	"max-seconds-behind":            "max-block-age",
+	"max-seconds-without-new-block": "max-head-stall",
+}
+
+// warnDeprecatedSettings logs every deprecated setting that is set, whether
+// from a flag, the environment or the config file
+func warnDeprecatedSettings() {
+	for key, replacement := range deprecatedSettings {
+		if !settings().IsSet(key) {
+			continue
+		}
+		if settings().IsSet(replacement) {
+			log.Warn().Str("setting", key).Str("replacement", replacement).Msg("Ignoring deprecated setting, its replacement is set")
+			continue
+		}
+		log.Warn().Str("setting", key).Str("replacement", replacement).Msg("Setting is deprecated, use its replacement")
+	}
+}
+
+// maxHeadStall returns the max-head-stall of v, falling back to the
+// deprecated max-seconds-without-new-block
+func maxHeadStall(v *viper.Viper) time.Duration {
+	value, _ := durationSetting(v, "max-head-stall", "max-seconds-without-new-block")
+	return value
+}
+
+// rpcHeaders builds the headers sent with every request to the node from the
+// rpc-header, rpc-basic-auth and rpc-bearer-token flags
+func rpcHeaders(v *viper.Viper) (http.Header, error) {
+	headers := http.Header{}
+	for _, header := range v.GetStringSlice("rpc-header") {
+		key, value, ok := strings.Cut(header, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			// Only the key is echoed back since the value may be a secret
+			return nil, fmt.Errorf("invalid rpc-header %q, expected Key=Value", key)
+		}
+		headers.Add(strings.TrimSpace(key), value)
+	}
+
+	basicAuth, token := v.GetString("rpc-basic-auth"), v.GetString("rpc-bearer-token")
+	if basicAuth != "" && token != "" {
+		return nil, errors.New("rpc-basic-auth and rpc-bearer-token cannot be used together")
+	}
+	if basicAuth != "" {
+		user, password, ok := strings.Cut(basicAuth, ":")
+		if !ok || user == "" {
+			return nil, errors.New("invalid rpc-basic-auth, expected user:password")
+		}
+		headers.Set("Authorization", clients.BasicAuth(user, password))
+	}
+	if token != "" {
+		headers.Set("Authorization", "Bearer "+token)
+	}
+
+	return headers, nil
+}
+
+// rpcProxy returns the proxy of RPC requests: rpc-proxy-url when set, which
+// may carry basic auth credentials, and the proxy environment variables
+// otherwise
+func rpcProxy(v *viper.Viper) (func(*http.Request) (*url.URL, error), error) {
+	raw := v.GetString("rpc-proxy-url")
+	if raw == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(raw)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid rpc proxy url %q", redactURL(raw))
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported rpc proxy scheme %q, expected http, https or socks5", proxyURL.Scheme)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/clients/clienttest"
+	"github.com/spf13/viper"
+)
+
+// The configured headers and credentials reach the node on every path a
+// request takes: the startup wait, batches and the ethclient connection
+// used when the node rejects batches
+func TestRPCHeadersReachNode(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    map[string]interface{}
+		credentials string
+		want        map[string]string
+	}{
+		{
+			name:     "custom headers",
+			settings: map[string]interface{}{"rpc-header": []string{"X-Api-Key=secret", "X-Tenant=medic"}},
+			want:     map[string]string{"X-Api-Key": "secret", "X-Tenant": "medic"},
+		},
+		{
+			name:     "bearer token",
+			settings: map[string]interface{}{"rpc-bearer-token": "token"},
+			want:     map[string]string{"Authorization": "Bearer token"},
+		},
+		{
+			name:     "basic auth",
+			settings: map[string]interface{}{"rpc-basic-auth": "medic:secret"},
+			want:     map[string]string{"Authorization": clients.BasicAuth("medic", "secret")},
+		},
+		{
+			name:        "credentials in the url",
+			settings:    map[string]interface{}{},
+			credentials: "medic:secret@",
+			want:        map[string]string{"Authorization": clients.BasicAuth("medic", "secret")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, batches := range []bool{true, false} {
+				node := clienttest.NewServer()
+				defer node.Close()
+				node.RejectBatches(!batches)
+
+				values := map[string]interface{}{"retry-max": 0}
+				for key, value := range tt.settings {
+					values[key] = value
+				}
+				useSettings(t, values)
+				previous := rpcOptions
+				t.Cleanup(func() { rpcOptions = previous })
+
+				retryClient := configureRPC()
+				client := newNodeClient("", strings.Replace(node.URL, "://", "://"+tt.credentials, 1))
+				ctx, cancel := context.WithTimeout(client.context(context.Background()), 5*time.Second)
+				defer cancel()
+				if err := waitForNode(ctx, retryClient, client.url); err != nil {
+					t.Fatal(err)
+				}
+				measure(ctx, client)
+
+				for _, method := range []string{"web3_clientVersion", "eth_getBlockByNumber"} {
+					header := node.Header(method)
+					if header == nil {
+						t.Errorf("batches %v: %s not called", batches, method)
+						continue
+					}
+					for key, value := range tt.want {
+						if got := header.Get(key); got != value {
+							t.Errorf("batches %v: %s %s = %q, want %q", batches, method, key, got, value)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// The credentials of eth-url are only sent to the node, not to the other
+// endpoints on the same host
+func TestNodeCredentialsStayWithNode(t *testing.T) {
+	stub := stubNode(map[string]interface{}{
+		"eth_getBlockByNumber": clienttest.Block(100, time.Now()),
+		"net_peerCount":        clienttest.PeerCount(10),
+		"eth_syncing":          false,
+	})
+	var mu sync.Mutex
+	auth := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth[r.URL.Path] = r.Header.Get("Authorization")
+		mu.Unlock()
+		stub.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	useSettings(t, map[string]interface{}{"graphql-url": server.URL + "/graphql"})
+	previous := rpcOptions
+	t.Cleanup(func() { rpcOptions = previous })
+	configureRPC()
+
+	node := newNodeClient("", strings.Replace(server.URL, "://", "://medic:secret@", 1))
+	measure(node.context(context.Background()), node)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := auth["/"], clients.BasicAuth("medic", "secret"); got != want {
+		t.Errorf("node Authorization = %q, want %q", got, want)
+	}
+	got, ok := auth["/graphql"]
+	if !ok {
+		t.Fatal("graphql-url not called")
+	}
+	if got != "" {
+		t.Errorf("graphql-url Authorization = %q, want none", got)
+	}
+}
+
+// forwardingProxy is a plain HTTP proxy that records the requests it forwards
+type forwardingProxy struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newForwardingProxy() *forwardingProxy {
+	p := &forwardingProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.requests = append(p.requests, r.Clone(context.Background()))
+		p.mu.Unlock()
+
+		r.RequestURI = ""
+		r.Header.Del("Proxy-Authorization")
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for key, values := range resp.Header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	return p
+}
+
+// forwarded returns how many requests to host went through the proxy
+func (p *forwardingProxy) forwarded(host string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	count := 0
+	for _, r := range p.requests {
+		if r.URL.Host == host {
+			count++
+		}
+	}
+	return count
+}
+
+// authorizations returns the distinct Proxy-Authorization headers received
+func (p *forwardingProxy) authorizations() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	seen := map[string]bool{}
+	var values []string
+	for _, r := range p.requests {
+		if value := r.Header.Get("Proxy-Authorization"); !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// The startup wait, the clients package and the ethclient connection all
+// send their requests through rpc-proxy-url with its credentials
+func TestRPCProxy(t *testing.T) {
+	node := httptest.NewServer(stubNode(gethStub))
+	defer node.Close()
+	proxy := newForwardingProxy()
+	defer proxy.Close()
+
+	retryMax := viper.GetInt("retry-max")
+	viper.Set("retry-max", 0)
+	t.Cleanup(func() { viper.Set("retry-max", retryMax) })
+
+	v := viper.New()
+	v.Set("rpc-proxy-url", strings.Replace(proxy.URL, "://", "://medic:secret@", 1))
+	proxyFunc, err := rpcProxy(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retryClient := newRetryClient(nil, nil, proxyFunc)
+	previous := rpcOptions
+	rpcOptions = &clients.Options{HTTPClient: retryClient.StandardClient(), Proxy: proxyFunc}
+	t.Cleanup(func() { rpcOptions = previous })
+
+	ctx, cancel := context.WithTimeout(rpcContext(context.Background()), 5*time.Second)
+	defer cancel()
+	if err := waitForNode(ctx, retryClient, node.URL); err != nil {
+		t.Fatalf("waitForNode() error = %v", err)
+	}
+	if _, err := clients.ClientVersion(ctx, node.URL); err != nil {
+		t.Fatalf("ClientVersion() error = %v", err)
+	}
+	client, err := newNodeClient("", node.URL).get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ChainID(ctx); err != nil {
+		t.Fatalf("ChainID() error = %v", err)
+	}
+
+	host := strings.TrimPrefix(node.URL, "http://")
+	if forwarded := proxy.forwarded(host); forwarded != 3 {
+		t.Errorf("%d requests went through the proxy, want 3", forwarded)
+	}
+	if auth := proxy.authorizations(); len(auth) != 1 || auth[0] != "Basic "+basicCredentials("medic", "secret") {
+		t.Errorf("Proxy-Authorization = %q, want the credentials of the proxy url", auth)
+	}
+
+	// Without the proxy the node cannot be reached, so no request bypasses it
+	proxy.Close()
+	if _, err := clients.ClientVersion(ctx, node.URL); err == nil {
+		t.Error("node reached without the proxy")
+	}
+}
+
+// basicCredentials encodes user and password like a basic auth header
+func basicCredentials(user, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// loadConfigFile reads the file given by the config flag, if any. Flags and
+// environment variables take precedence over the file, which takes precedence
+// over the flag defaults. Unknown keys are logged and values that do not
+// parse as the flag's type are rejected.
+func loadConfigFile() error {
+	path := settings().GetString("config")
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	file, err := parseConfigFile(path, data)
+	if err != nil {
+		return err
+	}
+
+	return settings().MergeConfigMap(file.AllSettings())
+}
+
+// parseConfigFile parses the contents of the config file on their own, so
+// only the keys set in the file are checked
+func parseConfigFile(path string, data []byte) (*viper.Viper, error) {
+	file := viper.New()
+	file.SetConfigType(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err := file.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	for _, key := range file.AllKeys() {
+		flag := pflag.Lookup(key)
+		if flag == nil {
+			log.Warn().Str("key", key).Str("config", path).Msg("Ignoring unknown key in the config file")
+			continue
+		}
+		if err := checkConfigValue(flag.Value.Type(), file.Get(key)); err != nil {
+			return nil, fmt.Errorf("config file %s: invalid %s: %w", path, key, err)
+		}
+	}
+
+	return file, nil
+}
+
+// checkConfigValue verifies that a value from the config file parses as the
+// given pflag type
+func checkConfigValue(typeName string, value interface{}) error {
+	switch typeName {
+	case "stringSlice", "stringArray":
+		switch value.(type) {
+		case string, []interface{}:
+			return nil
+		}
+		return fmt.Errorf("expected a list or a comma-separated string, got %v", value)
+	}
+
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return fmt.Errorf("expected a single %s value, got %v", typeName, value)
+	}
+
+	raw := fmt.Sprint(value)
+	var err error
+	switch typeName {
+	case "bool":
+		_, err = strconv.ParseBool(raw)
+	case "int":
+		_, err = strconv.Atoi(raw)
+	case "uint64":
+		_, err = strconv.ParseUint(raw, 10, 64)
+	case "float64":
+		_, err = strconv.ParseFloat(raw, 64)
+	case "duration":
+		_, err = time.ParseDuration(raw)
+	}
+	return err
+}
+
+// secretKeys are the configuration keys whose values are never shown
+var secretKeys = map[string]bool{
+	"rpc-header":       true,
+	"rpc-basic-auth":   true,
+	"rpc-bearer-token": true,
+	"admin-token":      true,
+	"status-token":     true,
+}
+
+// effectiveConfig returns every setting with its value in effect, with
+// secrets and the credential-bearing parts of URLs redacted
+func effectiveConfig() map[string]interface{} {
+	v := settings()
+	effective := map[string]interface{}{}
+	pflag.VisitAll(func(flag *pflag.Flag) {
+		effective[flag.Name] = redactSetting(flag.Name, v.Get(flag.Name))
+	})
+	return effective
+}
+
+// redactSetting hides secret values and the user info, path and query of
+// URLs, which often carry API keys
+func redactSetting(key string, value interface{}) interface{} {
+	if secretKeys[key] {
+		if fmt.Sprint(value) == "" || fmt.Sprint(value) == "[]" {
+			return value
+		}
+		return "REDACTED"
+	}
+
+	switch v := value.(type) {
+	case string:
+		return redactURL(v)
+	case []string:
+		redacted := make([]string, len(v))
+		for i, item := range v {
+			redacted[i] = redactURL(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]string, len(v))
+		for i, item := range v {
+			redacted[i] = redactURL(fmt.Sprint(item))
+		}
+		return redacted
+	}
+	return value
+}
+
+// redactURL redacts the credential-bearing parts of value when it is a URL,
+// including one embedded in a name=url target
+func redactURL(value string) string {
+	prefix := ""
+	if name, rest, ok := strings.Cut(value, "="); ok && strings.Contains(rest, "://") {
+		prefix, value = name+"=", rest
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return prefix + value
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	if u.Path != "" && u.Path != "/" {
+		u.Path = "/REDACTED"
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "REDACTED"
+	}
+	return prefix + u.String()
+}
+
+// logEffectiveConfig logs the redacted configuration at startup
+func logEffectiveConfig() {
+	settings := effectiveConfig()
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	event := log.Info()
+	for _, key := range keys {
+		event = event.Interface(key, settings[key])
+	}
+	event.Msg("Effective configuration")
+}
+
+// requireAdminToken only lets requests through that carry the admin token as
+// a bearer token. Without a configured token every request is rejected.
+func requireAdminToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Fail closed should a handler be registered without a token
+		token := settings().GetString("admin-token")
+		if token == "" {
+			http.Error(w, "admin endpoints are disabled without admin-token", http.StatusForbidden)
+			return
+		}
+
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// configHandler serves the redacted effective configuration
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, effectiveConfig())
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+
+	"github.com/rs/zerolog/log"
+)
+
+// measureConsensus collects the raw measurements from the beacon node at url
+func measureConsensus(ctx context.Context, url string) *health.Consensus {
+	m := &health.Consensus{Client: beaconClient.clientInfo(), Errors: map[string]error{}}
+	var err error
+
+	start := time.Now()
+	m.HealthStatus, err = clients.BeaconHealth(ctx, url)
+	observeRPC(ctx, "beacon_node_health", start)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve the beacon node health")
+		m.Errors["cl_health"] = err
+		// Re-detect the client in case the beacon node was replaced
+		beaconClient.requestDetection()
+	}
+
+	start = time.Now()
+	m.Syncing, err = clients.BeaconSyncStatus(ctx, url)
+	observeRPC(ctx, "beacon_node_syncing", start)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve the beacon node sync status")
+		m.Errors["cl_syncing"] = err
+	} else {
+		checkBeaconClock(ctx, url, m.Syncing.HeadSlot)
+		logMissingFlag("is_optimistic", m.Syncing.IsOptimistic)
+		logMissingFlag("el_offline", m.Syncing.ELOffline)
+	}
+
+	start = time.Now()
+	m.Peers, err = clients.BeaconPeers(ctx, url)
+	observeRPC(ctx, "beacon_node_peer_count", start)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve the beacon node peer count")
+	}
+
+	if m.Finality, err = measureFinality(ctx, url); err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve the beacon node finality checkpoint")
+		m.Errors["cl_finality"] = err
+	}
+
+	return m
+}
+
+// measureFinality dates the finalized checkpoint with the slot timing of the
+// chain, so that chains with other slot times are handled
+func measureFinality(ctx context.Context, url string) (*health.Finality, error) {
+	start := time.Now()
+	epoch, err := clients.BeaconFinalizedEpoch(ctx, url)
+	observeRPC(ctx, "beacon_finality_checkpoints", start)
+	if err != nil {
+		return nil, err
+	}
+
+	clock, err := beaconClockInfo(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the slot timing: %w", err)
+	}
+	return &health.Finality{Epoch: epoch, Age: max(time.Since(clock.EpochStart(epoch)), 0)}, nil
+}
+
+// logMissingFlag notes a flag of /eth/v1/node/syncing the beacon node does not
+// report, which the consensus checks treat as false
+func logMissingFlag(name string, value *bool) {
+	if value == nil {
+		log.Debug().Str("field", name).Msg("Beacon node does not report the field in its sync status, assuming false")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronMatches(t *testing.T) {
+	// 2026-10-16 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.October, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		time time.Time
+		want bool
+	}{
+		{name: "every minute", spec: "* * * * *", time: at(16, 13, 37), want: true},
+		{name: "exact time", spec: "30 2 * * *", time: at(16, 2, 30), want: true},
+		{name: "other minute", spec: "30 2 * * *", time: at(16, 2, 31)},
+		{name: "weekday range", spec: "0 2 * * MON-FRI", time: at(16, 2, 0), want: true},
+		{name: "weekday range on a weekend", spec: "0 2 * * MON-FRI", time: at(17, 2, 0)},
+		{name: "weekend range on Saturday", spec: "0 2 * * SAT-SUN", time: at(17, 2, 0), want: true},
+		{name: "weekend range on Sunday", spec: "0 2 * * SAT-SUN", time: at(18, 2, 0), want: true},
+		{name: "weekend range on Monday", spec: "0 2 * * SAT-SUN", time: at(19, 2, 0)},
+		{name: "7 is Sunday", spec: "0 2 * * 7", time: at(18, 2, 0), want: true},
+		{name: "0 is Sunday", spec: "0 2 * * 0", time: at(18, 2, 0), want: true},
+		{name: "range ending on 7", spec: "0 2 * * 5-7", time: at(18, 2, 0), want: true},
+		{name: "lowercase names", spec: "0 2 * oct fri", time: at(16, 2, 0), want: true},
+		{name: "month name", spec: "0 2 * NOV *", time: at(16, 2, 0)},
+		{name: "list", spec: "0,20,40 * * * *", time: at(16, 5, 40), want: true},
+		{name: "list miss", spec: "0,20,40 * * * *", time: at(16, 5, 30)},
+		{name: "step", spec: "*/15 * * * *", time: at(16, 5, 45), want: true},
+		{name: "step miss", spec: "*/15 * * * *", time: at(16, 5, 50)},
+		{name: "range step", spec: "1-10/2 * * * *", time: at(16, 5, 9), want: true},
+		{name: "range step between", spec: "1-10/2 * * * *", time: at(16, 5, 4)},
+		{name: "range step past the end", spec: "1-10/2 * * * *", time: at(16, 5, 11)},
+		{name: "value step runs to the end", spec: "50/5 * * * *", time: at(16, 5, 55), want: true},
+		{name: "weekday step includes Sunday as 7", spec: "0 2 * * 1-7/2", time: at(18, 2, 0), want: true},
+		// Like cron, a day matches when either day field does unless one
+		// of them is *
+		{name: "day of month or weekday on the day", spec: "0 0 13 * FRI", time: at(13, 0, 0), want: true},
+		{name: "day of month or weekday on the weekday", spec: "0 0 13 * FRI", time: at(16, 0, 0), want: true},
+		{name: "day of month or weekday on neither", spec: "0 0 13 * FRI", time: at(1, 0, 0)},
+		{name: "any day of month and a weekday", spec: "0 0 * * FRI", time: at(13, 0, 0)},
+		{name: "day of month and any weekday", spec: "0 0 13 * *", time: at(16, 0, 0)},
+		{name: "stepped day of month counts as *", spec: "0 0 */2 * FRI", time: at(15, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCron(%q) error = %v", tt.spec, err)
+			}
+			if got := schedule.matches(tt.time); got != tt.want {
+				t.Errorf("%q matches %s = %v, want %v", tt.spec, tt.time.Format(time.RFC1123), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"10-5 * * * *",
+		"1-x * * * *",
+		"MON * * * *",
+		"* * * * FOO",
+		"1,,2 * * * *",
+	} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) accepted an invalid expression", spec)
+		}
+	}
+}
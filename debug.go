@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/rs/zerolog/log"
+)
+
+// probeMux serves the probe endpoints. The default mux is left unused since
+// net/http/pprof and expvar register on it when imported.
+var probeMux = http.NewServeMux()
+
+var (
+	checksRunVar     = expvar.NewInt("checks_run")
+	checkFailuresVar = expvar.NewMap("check_failures")
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+}
+
+// recordCheckVars counts a health evaluation and its failure reasons
+func recordCheckVars(result HealthResult) {
+	checksRunVar.Add(1)
+	for _, reason := range result.Reasons {
+		checkFailuresVar.Add(reason, 1)
+	}
+}
+
+// startDebugServer serves pprof and expvar on debug-addr when enable-pprof is
+// set, keeping them off the probe port. It returns nil when disabled.
+func startDebugServer() *http.Server {
+	if !settings().GetBool("enable-pprof") {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	listener := listen(settings().GetString("debug-addr"))
+	server := &http.Server{Handler: mux}
+	go func() {
+		log.Info().Str("debug_addr", listener.Addr().String()).Msg("Debug server listening")
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Debug server failed")
+		}
+	}()
+	return server
+}
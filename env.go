@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// envPrefix is prepended to the environment variable of every flag so medic
+// does not pick up variables meant for other containers in the pod
+const envPrefix = "MEDIC"
+
+// legacyEnvNames are the unprefixed environment variables medic read before
+// the prefix was introduced, by flag. Only the flags that existed then have
+// one, and they keep working with a warning at startup.
+// TODO: drop the legacy names in the next release
+var legacyEnvNames = map[string]string{
+	"eth-url":            "ETH_URL",
+	"log-level":          "LOG_LEVEL",
+	"max-seconds-behind": "MAX_SECONDS_BEHIND",
+	"min-peers":          "MIN_PEERS",
+}
+
+// envName returns the environment variable for a flag, e.g.
+// MEDIC_MAX_SECONDS_BEHIND for max-seconds-behind
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// configureEnv makes v read the prefixed environment variables, falling back
+// to the legacy unprefixed ones
+func configureEnv(v *viper.Viper) {
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	// BindEnv checks the names in order, so the prefixed variable wins
+	pflag.VisitAll(func(flag *pflag.Flag) {
+		if legacy, ok := legacyEnvNames[flag.Name]; ok {
+			v.BindEnv(flag.Name, envName(flag.Name), legacy)
+			return
+		}
+		v.BindEnv(flag.Name, envName(flag.Name))
+	})
+}
+
+// warnLegacyEnv logs every legacy environment variable that is set
+func warnLegacyEnv() {
+	pflag.VisitAll(func(flag *pflag.Flag) {
+		legacy, ok := legacyEnvNames[flag.Name]
+		if !ok {
+			return
+		}
+		if _, ok := os.LookupEnv(legacy); !ok {
+			return
+		}
+		if _, ok := os.LookupEnv(envName(flag.Name)); ok {
+			log.Warn().Str("env", legacy).Str("replacement", envName(flag.Name)).Msg("Ignoring deprecated environment variable, the prefixed variable is set")
+			return
+		}
+		log.Warn().Str("env", legacy).Str("replacement", envName(flag.Name)).Msg("Environment variable is deprecated, use the prefixed variable")
+	})
+}
+
+// documentEnv appends the environment variable of every flag to its usage so
+// --help shows the mapping
+func documentEnv(flags *pflag.FlagSet) {
+	flags.VisitAll(func(flag *pflag.Flag) {
+		flag.Usage += " (env " + envName(flag.Name) + ")"
+	})
+}
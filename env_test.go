@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestSettingPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		legacy   string
+		prefixed string
+		flag     string
+		want     int
+	}{
+		{name: "default", want: 3},
+		{name: "config file", config: "min-peers: 4", want: 4},
+		{name: "legacy env over config file", config: "min-peers: 4", legacy: "5", want: 5},
+		{name: "prefixed env over legacy env", config: "min-peers: 4", legacy: "5", prefixed: "6", want: 6},
+		{name: "prefixed env alone", prefixed: "6", want: 6},
+		{name: "flag over env", config: "min-peers: 4", legacy: "5", prefixed: "6", flag: "7", want: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.legacy != "" {
+				t.Setenv("MIN_PEERS", tt.legacy)
+			}
+			if tt.prefixed != "" {
+				t.Setenv("MEDIC_MIN_PEERS", tt.prefixed)
+			}
+
+			flags := pflag.NewFlagSet("medic", pflag.ContinueOnError)
+			flags.Int("min-peers", 3, "")
+			var args []string
+			if tt.flag != "" {
+				args = append(args, "--min-peers="+tt.flag)
+			}
+			if err := flags.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+
+			v := viper.New()
+			if err := v.BindPFlags(flags); err != nil {
+				t.Fatal(err)
+			}
+			configureEnv(v)
+			if tt.config != "" {
+				v.SetConfigType("yaml")
+				if err := v.ReadConfig(strings.NewReader(tt.config)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := v.GetInt("min-peers"); got != tt.want {
+				t.Errorf("min-peers = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := envName("max-seconds-behind"); got != "MEDIC_MAX_SECONDS_BEHIND" {
+		t.Errorf("envName = %q", got)
+	}
+}
+
+// Only the flags medic had before the prefix fall back to an unprefixed
+// variable, so variables meant for other containers are not picked up
+func TestLegacyEnvNames(t *testing.T) {
+	t.Setenv("ETH_URL", "http://legacy:8545")
+	t.Setenv("MAX_SECONDS_BEHIND", "60")
+	t.Setenv("CONFIG", "/etc/other/config.yaml")
+	t.Setenv("CHECK_TIMEOUT", "1m")
+	t.Setenv("TARGET", "other=http://other:8545")
+
+	v := viper.New()
+	configureEnv(v)
+	if got := v.GetString("eth-url"); got != "http://legacy:8545" {
+		t.Errorf("eth-url = %q, want the legacy ETH_URL", got)
+	}
+	if got := v.GetInt("max-seconds-behind"); got != 60 {
+		t.Errorf("max-seconds-behind = %d, want the legacy MAX_SECONDS_BEHIND", got)
+	}
+	for _, key := range []string{"config", "check-timeout", "target"} {
+		if v.IsSet(key) {
+			t.Errorf("%s = %v, read from an unprefixed variable", key, v.Get(key))
+		}
+	}
+}
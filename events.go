@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+)
+
+// Event is a debounced health transition of a node
+type Event struct {
+	Time time.Time `json:"time"`
+	// Target is the name of the target, empty for the node of eth-url
+	Target string `json:"target,omitempty"`
+	// Direction is healthy or unhealthy, the state the node transitioned to
+	Direction   string                   `json:"direction"`
+	Reasons     []string                 `json:"reasons,omitempty"`
+	ClientType  string                   `json:"client_type,omitempty"`
+	BlockNumber uint64                   `json:"block_number,omitempty"`
+	BlockDelta  int                      `json:"block_delta"`
+	PeerCount   int                      `json:"peer_count"`
+	Checks      map[string]health.Result `json:"checks,omitempty"`
+}
+
+// eventLog keeps the most recent transitions of every node in memory and
+// appends them to a JSON Lines file when one is configured
+type eventLog struct {
+	mu     sync.RWMutex
+	events []Event
+	size   int
+	file   *os.File
+}
+
+// events is the transition log shared by all nodes
+var events = newEventLog(500)
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{size: size}
+}
+
+// openEventLog returns a log of up to size events. With a path the events
+// recorded there by earlier runs are loaded and new ones are appended.
+func openEventLog(path string, size int) (*eventLog, error) {
+	l := newEventLog(size)
+	if path == "" {
+		return l, nil
+	}
+
+	existing, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		scanner := bufio.NewScanner(existing)
+		for scanner.Scan() {
+			var event Event
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				// A line torn by a crash must not prevent startup
+				log.Warn().Err(err).Str("event_log_file", path).Msg("Skipping an unreadable event")
+				continue
+			}
+			l.append(event)
+		}
+		err := scanner.Err()
+		existing.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if l.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// append adds event, dropping the oldest once the log is full
+func (l *eventLog) append(event Event) {
+	if len(l.events) == l.size {
+		copy(l.events, l.events[1:])
+		l.events = l.events[:len(l.events)-1]
+	}
+	l.events = append(l.events, event)
+}
+
+// newEvent describes the transition of node to result
+func newEvent(node *nodeClient, result HealthResult) Event {
+	event := Event{
+		Time:        time.Now().UTC(),
+		Target:      node.name,
+		Direction:   "unhealthy",
+		Reasons:     result.Reasons,
+		ClientType:  result.ClientType,
+		BlockNumber: result.BlockNumber,
+		BlockDelta:  result.intValue("block_delta"),
+		PeerCount:   result.intValue("peers"),
+		Checks:      result.Checks,
+	}
+	if result.Healthy {
+		event.Direction = "healthy"
+	}
+	return event
+}
+
+// record adds event to the log. Write failures are logged and otherwise
+// ignored so health checking continues.
+func (l *eventLog) record(event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.append(event)
+
+	if l.file == nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err == nil {
+		_, err = l.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		log.Error().Err(err).Str("event_log_file", l.file.Name()).Msg("Failed to write the event log file")
+	}
+}
+
+// since returns the events recorded after since, oldest first
+func (l *eventLog) since(since time.Time) []Event {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	matching := []Event{}
+	for _, event := range l.events {
+		if event.Time.After(since) {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
+
+// EventsResponse is the response of /events
+type EventsResponse struct {
+	Events []Event `json:"events"`
+}
+
+// eventsHandler serves the recorded transitions, optionally only those after
+// the RFC 3339 timestamp in the since query parameter
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, raw); err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, EventsResponse{Events: events.since(since)})
+}
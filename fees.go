@@ -0,0 +1,20 @@
+package main
+
+import (
+	"math/big"
+)
+
+// feeHistoryBlocks is the number of blocks requested from eth_feeHistory
+const feeHistoryBlocks = 5
+
+var weiPerGwei = big.NewFloat(1e9)
+
+// gweiToWei converts a gwei amount from a flag to wei, returning nil for 0 so
+// that unset bounds are skipped
+func gweiToWei(gwei float64) *big.Int {
+	if gwei <= 0 {
+		return nil
+	}
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), weiPerGwei).Int(nil)
+	return wei
+}
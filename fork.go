@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// forkPins are the blocks parsed from expected-genesis-hash and verify-block
+var forkPins []health.PinnedBlock
+
+// parseHash parses a 32-byte hex hash, rejecting anything shorter or longer
+func parseHash(value string) (common.Hash, error) {
+	bytes, err := hexutil.Decode(value)
+	if err != nil || len(bytes) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid block hash %q", value)
+	}
+	return common.BytesToHash(bytes), nil
+}
+
+// pinnedBlocks parses the expected-genesis-hash and verify-block flags
+func pinnedBlocks(v *viper.Viper) ([]health.PinnedBlock, error) {
+	var pins []health.PinnedBlock
+
+	if genesis := v.GetString("expected-genesis-hash"); genesis != "" {
+		hash, err := parseHash(genesis)
+		if err != nil {
+			return nil, fmt.Errorf("expected-genesis-hash: %w", err)
+		}
+		pins = append(pins, health.PinnedBlock{Number: 0, Hash: hash})
+	}
+
+	for _, value := range v.GetStringSlice("verify-block") {
+		height, hashValue, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid verify-block %q, expected HEIGHT:HASH", value)
+		}
+		number, err := strconv.ParseUint(height, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid verify-block height %q", height)
+		}
+		hash, err := parseHash(hashValue)
+		if err != nil {
+			return nil, fmt.Errorf("verify-block %d: %w", number, err)
+		}
+		pins = append(pins, health.PinnedBlock{Number: number, Hash: hash})
+	}
+
+	return pins, nil
+}
+
+// verifyFork fetches the pinned blocks and compares their hashes, querying
+// the node only until a verification succeeds since the last reconnect
+func (n *nodeClient) verifyFork(ctx context.Context, pins []health.PinnedBlock) (*health.ForkVerification, error) {
+	n.mu.Lock()
+	verified := n.forkVerified
+	n.mu.Unlock()
+	if verified != nil {
+		return verified, nil
+	}
+
+	verification := &health.ForkVerification{}
+	for _, pin := range pins {
+		start := time.Now()
+		header, err := clients.BlockByTag(ctx, n.url, hexutil.EncodeUint64(pin.Number))
+		observeRPC(ctx, "eth_getBlockByNumber", start)
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Hash != pin.Hash {
+			pin := pin
+			verification = &health.ForkVerification{Mismatch: &pin, Actual: header.Hash}
+			log.Error().
+				Uint64("block", pin.Number).
+				Str("expected_hash", pin.Hash.Hex()).
+				Str("actual_hash", header.Hash.Hex()).
+				Msg("Node is on a different fork, block hash does not match")
+			break
+		}
+	}
+
+	n.mu.Lock()
+	n.forkVerified = verification
+	n.mu.Unlock()
+	return verification, nil
+}
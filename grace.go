@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/health"
+)
+
+// graceTracker follows the initial grace period of a node, during which too
+// few peers only warn since a restarted node needs time to find them again
+type graceTracker struct {
+	mu          sync.Mutex
+	reachableAt time.Time
+}
+
+// GracePeriod is the initial grace period reported by /status while it lasts
+type GracePeriod struct {
+	// EndsAt is unset while the period waits for the node to become
+	// reachable
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Remaining float64    `json:"remaining_seconds"`
+}
+
+// observe records when the node was first reachable
+func (g *graceTracker) observe(m *health.Client) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.reachableAt.IsZero() && m.Errors["connection"] == nil && m.Errors["block_delta"] == nil {
+		g.reachableAt = time.Now()
+	}
+}
+
+// status returns the grace period, or nil once it is over or when
+// initial-grace-period is unset
+func (g *graceTracker) status() *GracePeriod {
+	period := settings().GetDuration("initial-grace-period")
+	if period <= 0 {
+		return nil
+	}
+
+	start := startTime
+	if settings().GetString("initial-grace-from") == "reachable" {
+		g.mu.Lock()
+		start = g.reachableAt
+		g.mu.Unlock()
+		if start.IsZero() {
+			return &GracePeriod{Remaining: period.Seconds()}
+		}
+	}
+
+	end := start.Add(period)
+	remaining := time.Until(end)
+	if remaining <= 0 {
+		return nil
+	}
+	return &GracePeriod{EndsAt: &end, Remaining: remaining.Seconds()}
+}
+
+// apply downgrades a peer count below min-peers to a warning while the grace
+// period lasts. RPC failures and syncing still fail readiness.
+func (g *graceTracker) apply(result *HealthResult) {
+	check, ok := result.Checks["peers"]
+	if !ok || check.OK || check.Reason != "min_peers_not_met" || g.status() == nil {
+		return
+	}
+	check.OK, check.Warning = true, true
+	result.Checks["peers"] = check
+	result.Summarize()
+}
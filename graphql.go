@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// graphQLServed is set once the endpoint answered anything but 404, after
+	// which a 404 fails the check instead of skipping it
+	graphQLServed atomic.Bool
+	// graphQLSkipLogged limits the log line about a disabled endpoint to one
+	graphQLSkipLogged atomic.Bool
+)
+
+// measureGraphQL queries the head from the GraphQL endpoint at url
+func measureGraphQL(ctx context.Context, url string) *health.GraphQL {
+	m := &health.GraphQL{}
+
+	start := time.Now()
+	m.BlockNumber, m.Err = clients.GraphQLBlockNumber(ctx, url)
+	observeRPC(ctx, "graphql_block", start)
+
+	var statusErr *clients.HTTPStatusError
+	if errors.As(m.Err, &statusErr) && statusErr.StatusCode == http.StatusNotFound && !graphQLServed.Load() {
+		m.NotEnabled = true
+		if !graphQLSkipLogged.Swap(true) {
+			log.Warn().Msg("GraphQL endpoint answers 404, skipping the graphql check until it is enabled on the node")
+		}
+		return m
+	}
+	graphQLServed.Store(true)
+	if m.Err != nil {
+		log.Error().Err(clients.WithoutURL(m.Err)).Msg("Failed to query the GraphQL endpoint")
+	}
+	return m
+}
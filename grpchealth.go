@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// grpcHealthInterval is how often the gRPC serving status is refreshed when
+// there is no background poller to follow
+const grpcHealthInterval = 5 * time.Second
+
+// grpcHealthServer serves the grpc.health.v1 protocol on grpc-addr. The empty
+// service is the readiness of /ready and every target is a service of its own.
+type grpcHealthServer struct {
+	server *grpc.Server
+	health *health.Server
+	cancel context.CancelFunc
+}
+
+// startGRPCHealth starts the gRPC health server when grpc-addr is set,
+// returning nil otherwise
+func startGRPCHealth() *grpcHealthServer {
+	addr := settings().GetString("grpc-addr")
+	if addr == "" {
+		return nil
+	}
+
+	listener := listen(addr)
+	s := &grpcHealthServer{server: grpc.NewServer(), health: health.NewServer()}
+	healthpb.RegisterHealthServer(s.server, s.health)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.refresh(ctx)
+	go s.follow(ctx)
+
+	go func() {
+		log.Info().Str("grpc_addr", listener.Addr().String()).Msg("gRPC health server listening")
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Fatal().Err(err).Msg("Failed to start the gRPC health server")
+		}
+	}()
+	return s
+}
+
+// follow refreshes the serving status every poll interval, which pushes the
+// transitions to Watch streams
+func (s *grpcHealthServer) follow(ctx context.Context) {
+	interval := settings().GetDuration("poll-interval")
+	if interval <= 0 {
+		interval = grpcHealthInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+// refresh sets the serving status of every service from the readiness of the
+// node or targets
+func (s *grpcHealthServer) refresh(ctx context.Context) {
+	ready, targets := currentReadiness(ctx)
+	s.set("", ready)
+	for name, ready := range targets {
+		s.set(name, ready)
+	}
+}
+
+func (s *grpcHealthServer) set(service string, serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	s.health.SetServingStatus(service, status)
+}
+
+// drain reports every service as not serving, as /ready does while draining
+func (s *grpcHealthServer) drain() {
+	s.cancel()
+	s.health.Shutdown()
+}
+
+// stop waits for open calls until ctx expires, then closes them
+func (s *grpcHealthServer) stop(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Watch streams stay open until the client leaves
+		s.server.Stop()
+	}
+}
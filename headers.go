@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxHeaderValue bounds the length of the X-Medic headers, since proxies
+// reject responses with oversized headers
+const maxHeaderValue = 256
+
+// setHealthHeaders summarizes result in X-Medic headers, for probe logs that
+// record headers but not the body
+func setHealthHeaders(w http.ResponseWriter, result HealthResult) {
+	header := w.Header()
+	header.Set("X-Medic-Healthy", strconv.FormatBool(result.Healthy))
+	if reasons := headerList(result.Reasons); reasons != "" {
+		header.Set("X-Medic-Reasons", reasons)
+	}
+	if _, ok := result.Checks["block_delta"]; ok {
+		header.Set("X-Medic-Block-Delta", strconv.Itoa(result.intValue("block_delta")))
+	}
+}
+
+// setTargetsHeaders summarizes the aggregate result of the targets in X-Medic
+// headers
+func setTargetsHeaders(w http.ResponseWriter, result TargetsResult) {
+	header := w.Header()
+	header.Set("X-Medic-Healthy", strconv.FormatBool(result.Healthy))
+	header.Set("X-Medic-Healthy-Targets", strconv.Itoa(result.HealthyTargets)+"/"+strconv.Itoa(len(result.Targets)))
+
+	var unhealthy []string
+	for name, target := range result.Targets {
+		if !target.Healthy {
+			unhealthy = append(unhealthy, name)
+		}
+	}
+	sort.Strings(unhealthy)
+	if names := headerList(unhealthy); names != "" {
+		header.Set("X-Medic-Unhealthy-Targets", names)
+	}
+}
+
+// headerList joins values with commas after dropping the characters that are
+// not safe in a header, leaving out the values that do not fit in
+// maxHeaderValue
+func headerList(values []string) string {
+	var list strings.Builder
+	for _, value := range values {
+		value = strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.', r == ':':
+				return r
+			}
+			return -1
+		}, value)
+		if value == "" {
+			continue
+		}
+		if list.Len() != 0 {
+			if list.Len()+1+len(value) > maxHeaderValue {
+				break
+			}
+			list.WriteByte(',')
+		} else if len(value) > maxHeaderValue {
+			value = value[:maxHeaderValue]
+		}
+		list.WriteString(value)
+	}
+	return list.String()
+}
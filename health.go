@@ -0,0 +1,628 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/sync/errgroup"
+)
+
+// HealthResult is the structured outcome of a node health evaluation: the
+// report of the health package and the state medic tracks across
+// evaluations
+type HealthResult struct {
+	health.Report
+	Syncing  *SyncProgress `json:"syncing,omitempty"`
+	CacheAge float64       `json:"cache_age_seconds,omitempty"`
+
+	// ForcedReady is set when readiness passed only because an operator
+	// overrode the health checks
+	ForcedReady bool `json:"forced_ready,omitempty"`
+
+	FailureStreak int `json:"failure_streak"`
+	SuccessStreak int `json:"success_streak"`
+}
+
+// intValue returns the integer value of the named check, or 0 when the check
+// is missing or has no integer value
+func (r HealthResult) intValue(name string) int {
+	if value, ok := r.Checks[name].Value.(int); ok {
+		return value
+	}
+	return 0
+}
+
+// errorSet collects the errors of measurements that run concurrently
+type errorSet struct {
+	mu     sync.Mutex
+	errors map[string]error
+}
+
+func (s *errorSet) add(name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[name] = err
+}
+
+// has reports whether any of names failed
+func (s *errorSet) has(names ...string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range names {
+		if s.errors[name] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// thresholdsFromConfig returns the thresholds of the current settings
+func thresholdsFromConfig() health.Thresholds {
+	// Read one snapshot so a concurrent reload cannot mix two configurations
+	v := settings()
+	gate := minBlock.current()
+	var gateErr error
+	if gate.Error != "" {
+		gateErr = errors.New(gate.Error)
+	}
+	return health.Thresholds{
+		MaxBlockAge:        maxBlockAge().Value,
+		MaxHeadStall:       maxHeadStall(v),
+		MinPeers:           v.GetInt("min-peers"),
+		PeersRequired:      v.GetBool("peer-check-required"),
+		ExpectedChainID:    v.GetUint64("expected-chain-id"),
+		BesuOnly:           v.GetBool("besu-health-only"),
+		MaxFinalizedLag:    v.GetDuration("max-finalized-lag"),
+		MaxSafeLag:         v.GetDuration("max-safe-lag"),
+		MaxFinalityAge:     v.GetDuration("max-finality-age"),
+		MinCLPeers:         v.GetUint64("cl-min-peers"),
+		BuilderRequired:    v.GetBool("mev-boost-required"),
+		MaxStageDistance:   v.GetUint64("max-stage-distance"),
+		MaxReorgDepth:      v.GetUint64("max-reorg-depth"),
+		MaxRestartRollback: v.GetUint64("max-restart-rollback"),
+		GetLogsMaxLatency:  v.GetDuration("getlogs-max-latency"),
+		MaxTxPoolPending:   v.GetUint64("max-txpool-pending"),
+		MinGasPrice:        gweiToWei(v.GetFloat64("min-gas-price")),
+		MaxGasPrice:        gweiToWei(v.GetFloat64("max-gas-price")),
+
+		MaxBlocksBehindReference: v.GetUint64("max-blocks-behind-reference"),
+
+		MaxGraphQLDistance:   v.GetUint64("graphql-max-block-distance"),
+		MaxRPCLatency:        v.GetDuration("max-rpc-latency"),
+		RPCLatencyPercentile: v.GetFloat64("rpc-latency-percentile"),
+
+		MaxRollupUnsafeAge:    v.GetDuration("rollup-max-unsafe-age"),
+		MaxRollupSafeAge:      v.GetDuration("rollup-max-safe-age"),
+		MaxRollupFinalizedAge: v.GetDuration("rollup-max-finalized-age"),
+		MaxL1OriginLag:        v.GetUint64("rollup-max-l1-origin-lag"),
+
+		MaxNitroMsgLag: v.GetUint64("max-nitro-msg-lag"),
+
+		CLOptimisticGraceUntil: startTime.Add(v.GetDuration("cl-optimistic-grace-period")),
+
+		MinBlock:    gate.MinBlockNumber,
+		MinBlockErr: gateErr,
+	}
+}
+
+// failedResult builds an unhealthy result from a single synthetic check
+func failedResult(name string, check health.Result) HealthResult {
+	return HealthResult{Report: health.Report{
+		Reasons: []string{check.Reason},
+		Checks:  map[string]health.Result{name: check},
+	}}
+}
+
+// blockDelta returns the time between the latest block and the local clock,
+// less the clock skew tolerance, along with the latest block number and hash. Only the header is fetched,
+// since the full block with its transactions is not needed for the timestamp.
+func blockDelta(ctx context.Context, client *ethclient.Client) (time.Duration, uint64, common.Hash, error) {
+	// Get the latest block header
+	start := time.Now()
+	header, err := client.HeaderByNumber(ctx, nil)
+	observeRPC(ctx, "eth_getBlockByNumber", start)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve the latest block")
+		return 0, 0, common.Hash{}, err
+	}
+
+	return headDelta(header.Time), header.Number.Uint64(), header.Hash(), nil
+}
+
+func checkNodePeers(ctx context.Context, client *ethclient.Client) (int, error) {
+	// Get the number of peers
+	start := time.Now()
+	peerCount, err := client.PeerCount(ctx)
+	observeRPC(ctx, "net_peerCount", start)
+	if err != nil {
+		logPeerError(err)
+		return 0, err
+	}
+
+	return int(peerCount), nil
+}
+
+// measureAdminPeers replaces the peer count with the number of useful peers
+// from admin_peers, keeping the net_peerCount value when the call fails
+func measureAdminPeers(ctx context.Context, node *nodeClient, m *health.Client) {
+	// admin_peers can be slow with many peers, so it has its own timeout
+	ctx, cancel := context.WithTimeout(ctx, settings().GetDuration("admin-peers-timeout"))
+	defer cancel()
+
+	start := time.Now()
+	peers, err := clients.AdminPeers(ctx, node.url)
+	observeRPC(ctx, "admin_peers", start)
+	if clients.IsMethodNotFound(err) {
+		log.Debug().Err(err).Msg("Node does not serve admin_peers, using net_peerCount")
+		node.adminUnavailable.Store(true)
+		return
+	}
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to call admin_peers, using net_peerCount")
+		return
+	}
+
+	m.AdminPeers = peers
+	m.PeerCount = peers.Useful
+	m.PeersUnavailable = nil
+}
+
+// logPeerError logs a failed net_peerCount call, as a warning when the node
+// does not serve the method since that need not fail readiness
+func logPeerError(err error) {
+	if clients.IsMethodNotFound(err) && !settings().GetBool("peer-check-required") {
+		log.Warn().Err(err).Msg("Node does not serve net_peerCount, skipping the peer check")
+		return
+	}
+	log.Error().Err(err).Msg("Failed to retrieve the number of peers")
+}
+
+// measureBatch fills in the execution client measurements selected by query
+// from a single JSON-RPC batch. It returns false when the node rejects
+// batches, in which case the caller falls back to individual calls.
+func measureBatch(ctx context.Context, node *nodeClient, m *health.Client, query clients.ExecutionQuery) bool {
+	start := time.Now()
+	status, err := clients.FetchExecutionStatus(ctx, node.url, query)
+	observeRPC(ctx, "rpc_batch", start)
+	// The head arrives with the batch, so the batch latency is the latency
+	// the node serves the head with
+	if query.Block {
+		trackLatency(ctx, health.LatencyMethod, time.Since(start))
+	}
+	if errors.Is(err, clients.ErrBatchUnsupported) {
+		log.Warn().Err(err).Msg("Node rejected the JSON-RPC batch, falling back to individual calls")
+		node.batchRejected.Store(true)
+		return false
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query the Ethereum client")
+		m.Errors["connection"] = err
+		return true
+	}
+
+	if err := status.Errors["eth_getBlockByNumber"]; err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve the latest block")
+		m.Errors["block_delta"] = err
+	} else if query.Block {
+		m.BlockNumber = status.BlockNumber
+		m.BlockHash = status.BlockHash
+		m.BlockDelta = headDelta(status.BlockTime)
+	}
+
+	if err := status.Errors["net_peerCount"]; err != nil {
+		logPeerError(err)
+		m.Errors["peers"] = err
+	} else if query.Peers {
+		m.PeerCount = int(status.PeerCount)
+	}
+
+	if err := status.Errors["eth_syncing"]; err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve the sync status")
+		m.Errors["syncing"] = err
+	} else if query.Syncing {
+		m.SyncStatus = status.SyncStatus
+	}
+
+	return true
+}
+
+// measureIndividually fills in the execution client measurements selected by
+// query with one request per call
+func measureIndividually(ctx context.Context, node *nodeClient, m *health.Client, query clients.ExecutionQuery) {
+	// Connect to the Ethereum client
+	client, err := node.get()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to the Ethereum client")
+		m.Errors["connection"] = err
+		return
+	}
+
+	// Get the block timestamp delta
+	if query.Block {
+		if m.BlockDelta, m.BlockNumber, m.BlockHash, err = blockDelta(ctx, client); err != nil {
+			m.Errors["block_delta"] = err
+		}
+	}
+
+	// Get the number of peers
+	if query.Peers {
+		if m.PeerCount, err = checkNodePeers(ctx, client); err != nil {
+			m.Errors["peers"] = err
+		}
+	}
+
+	// Get the sync status
+	if query.Syncing {
+		start := time.Now()
+		m.SyncStatus, err = clients.CheckSyncStatus(ctx, node.url)
+		observeRPC(ctx, "eth_syncing", start)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to retrieve the sync status")
+			m.Errors["syncing"] = err
+		}
+	}
+}
+
+// measureTaggedBlock fetches the block with the given tag. Errors other than
+// an unknown tag are recorded in errs under the tag name.
+func measureTaggedBlock(ctx context.Context, url string, tag string, errs *errorSet) *health.BlockTag {
+	start := time.Now()
+	header, err := clients.BlockByTag(ctx, url, tag)
+	observeRPC(ctx, "eth_getBlockByNumber_"+tag, start)
+	if errors.Is(err, clients.ErrUnknownBlock) {
+		log.Info().Err(err).Msgf("Node does not know the %s block, skipping its lag check", tag)
+		return &health.BlockTag{Unavailable: err}
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to retrieve the %s block", tag)
+		errs.add(tag, err)
+		return nil
+	}
+
+	return &health.BlockTag{
+		Number: header.Number,
+		Age:    time.Since(time.Unix(int64(header.Timestamp), 0)),
+	}
+}
+
+// clientHealthURL returns the base URL of a client-specific health endpoint
+// and the context to request it with, taken from flag or derived from the
+// node url and ctx. It is empty for IPC endpoints unless the flag is set,
+// since those endpoints are only served over HTTP.
+func clientHealthURL(ctx context.Context, flag, url string) (context.Context, string) {
+	if healthURL := settings().GetString(flag); healthURL != "" {
+		return endpointContext(ctx, healthURL)
+	}
+	if clients.IsIPC(url) {
+		return ctx, ""
+	}
+	return ctx, clients.HTTPURL(url)
+}
+
+// endpointContext returns the context for an endpoint configured by its own
+// URL and that URL without its credentials, which replace those of the node
+func endpointContext(ctx context.Context, rawURL string) (context.Context, string) {
+	endpointURL, user := clients.StripCredentials(rawURL)
+	return clients.WithOptions(ctx, rpcOptions.WithCredentials(user)), endpointURL
+}
+
+// measure collects the raw measurements from the node without judging them
+func measure(ctx context.Context, node *nodeClient) *health.Client {
+	m := &health.Client{Errors: map[string]error{}}
+	url := node.url
+
+	// Query the reference endpoints while the node is measured
+	references := startReferences(ctx)
+
+	// The other configured endpoints do not get the credentials of the node
+	endpoints := rpcContext(ctx)
+
+	// Take the head from the newHeads subscription while it is delivering
+	head, subscribed := node.stream.latest(settings().GetDuration("subscription-timeout"))
+	if subscribed {
+		m.BlockNumber = head.Number
+		m.BlockHash = head.Hash
+		m.BlockDelta = headDelta(head.Time)
+	}
+
+	// Query the execution client for what the enabled checks need, in a
+	// single round trip when possible
+	query := clients.ExecutionQuery{
+		Block:   !subscribed && (checkEnabled("block-delta") || checkEnabled("head-progress")),
+		Peers:   checkEnabled("peers") && settings().GetInt("min-peers") > 0,
+		Syncing: checkEnabled("syncing") || checkEnabled("nitro") && node.clientInfo().Type == "Nitro",
+	}
+	if query != (clients.ExecutionQuery{}) {
+		if !settings().GetBool("rpc-batch") || node.batchRejected.Load() || !measureBatch(ctx, node, m, query) {
+			measureIndividually(ctx, node, m, query)
+		}
+	}
+	if m.Errors["connection"] != nil {
+		return m
+	}
+
+	// Hosted providers often do not serve net_peerCount, which is no reason
+	// to reconnect
+	if err := m.Errors["peers"]; clients.IsMethodNotFound(err) {
+		delete(m.Errors, "peers")
+		m.PeersUnavailable = err
+	}
+
+	// Use the client type detected in the background
+	info := node.clientInfo()
+	m.Type, m.Version = info.Type, info.Raw
+	if m.Type == "Nitro" && m.SyncStatus != nil && checkEnabled("nitro") {
+		m.NitroProgress = m.SyncStatus.Nitro
+	}
+
+	// The remaining measurements are independent of each other, so they run
+	// concurrently and each records its own failure instead of cancelling the
+	// others, which keeps every reason in the result
+	var group errgroup.Group
+	errs := &errorSet{errors: map[string]error{}}
+
+	// Verify the chain ID when an expected value is configured
+	expected := settings().GetUint64("expected-chain-id")
+	if expected != 0 && checkEnabled("chain-id") || persistedHeads != nil {
+		group.Go(func() error {
+			chainID, err := node.chainID(ctx, expected)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to retrieve the chain ID")
+				errs.add("chain_id", err)
+			}
+			m.ChainID = chainID
+			return nil
+		})
+	}
+
+	// Fetch the finalized and safe blocks when their lag is checked
+	if settings().GetDuration("max-finalized-lag") > 0 && checkEnabled("finalized-lag") {
+		group.Go(func() error {
+			m.Finalized = measureTaggedBlock(ctx, url, "finalized", errs)
+			return nil
+		})
+	}
+	if settings().GetDuration("max-safe-lag") > 0 && checkEnabled("safe-lag") {
+		group.Go(func() error {
+			m.Safe = measureTaggedBlock(ctx, url, "safe", errs)
+			return nil
+		})
+	}
+
+	// Count only the peers on the same network when the admin namespace is
+	// served
+	if query.Peers && m.Errors["peers"] == nil && settings().GetBool("admin-peers") && !node.adminUnavailable.Load() {
+		group.Go(func() error {
+			measureAdminPeers(ctx, node, m)
+			return nil
+		})
+	}
+
+	// Query the health endpoints of clients that provide them
+	group.Go(func() error {
+		measureClientHealth(ctx, url, m, errs)
+		return nil
+	})
+
+	// Compare the pinned block hashes against the expected fork
+	if len(forkPins) != 0 && checkEnabled("fork") {
+		group.Go(func() error {
+			if m.Fork, m.ForkErr = node.verifyFork(ctx, forkPins); m.ForkErr != nil {
+				log.Error().Err(m.ForkErr).Msg("Failed to verify the pinned block hashes")
+			}
+			return nil
+		})
+	}
+
+	// Run the optional probes for RPC-serving nodes
+	group.Go(func() error {
+		measureProbes(ctx, url, m)
+		return nil
+	})
+
+	// Check the consensus client when one is configured
+	if clURL := settings().GetString("cl-url"); clURL != "" && checkEnabled("consensus") {
+		group.Go(func() error {
+			m.Consensus = measureConsensus(endpoints, clURL)
+			return nil
+		})
+	}
+
+	// Check the GraphQL endpoint when one is configured
+	if graphQLURL := settings().GetString("graphql-url"); graphQLURL != "" && checkEnabled("graphql") {
+		group.Go(func() error {
+			m.GraphQL = measureGraphQL(endpoints, graphQLURL)
+			return nil
+		})
+	}
+
+	// Check the op-node when one is configured
+	if rollupURL := settings().GetString("rollup-url"); rollupURL != "" && checkEnabled("rollup") {
+		group.Go(func() error {
+			m.Rollup = measureRollup(endpoints, rollupURL)
+			return nil
+		})
+	}
+
+	// Check mev-boost when one is configured
+	if builderURL := settings().GetString("mev-boost-url"); builderURL != "" && checkEnabled("builder") {
+		group.Go(func() error {
+			m.Builder = measureBuilder(endpoints, builderURL)
+			return nil
+		})
+	}
+
+	// Check the WebSocket endpoint when it is not the one measured
+	if wsURL := settings().GetString("ws-url"); wsURL != "" && checkEnabled("ws") {
+		group.Go(func() error {
+			m.WS = measureWS(endpoints, wsURL)
+			return nil
+		})
+	}
+
+	group.Wait()
+	if references != nil {
+		m.References = <-references
+	}
+
+	// Reconnect on the next cycle if the RPC calls failed, and re-detect the
+	// client in case the node was restarted or replaced. Failing client
+	// health endpoints are no reason to.
+	if len(m.Errors) != 0 || errs.has("chain_id", "finalized", "safe") {
+		node.reset()
+		node.requestClientDetection()
+	}
+	maps.Copy(m.Errors, errs.errors)
+
+	return m
+}
+
+// measureClientHealth queries the health endpoint of the detected client,
+// for the clients that provide one
+func measureClientHealth(ctx context.Context, url string, m *health.Client, errs *errorSet) {
+	var err error
+
+	switch m.Type {
+	case "Nethermind":
+		healthCtx, healthURL := clientHealthURL(ctx, "nethermind-health-url", url)
+		if healthURL == "" || !checkEnabled("nethermind-health") {
+			return
+		}
+		start := time.Now()
+		m.Nethermind, err = clients.NethermindHealthCheck(healthCtx, healthURL)
+		observeRPC(ctx, "nethermind_health", start)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to retrieve the Nethermind health")
+			errs.add("nethermind_health", err)
+		} else if failing := m.Nethermind.FailingEntries(); len(failing) != 0 {
+			log.Error().
+				Int("status_code", m.Nethermind.StatusCode).
+				Str("status", m.Nethermind.Status).
+				Strs("entries", failing).
+				Msg("Nethermind reports failing health entries")
+		} else if data := m.Nethermind.NodeHealth(); data != nil && len(data.Errors) != 0 {
+			log.Error().
+				Int("status_code", m.Nethermind.StatusCode).
+				Str("status", m.Nethermind.Status).
+				Strs("errors", data.Errors).
+				Msg("Nethermind reports node health errors")
+		}
+	case "Reth":
+		metricsURL := settings().GetString("reth-metrics-url")
+		if metricsURL == "" || !checkEnabled("reth-stages") {
+			return
+		}
+		start := time.Now()
+		m.RethStages, err = clients.RethStages(rpcContext(ctx), metricsURL)
+		observeRPC(ctx, "reth_metrics", start)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to retrieve the Reth stage checkpoints")
+			errs.add("reth_stages", err)
+		}
+	case "Besu":
+		healthCtx, healthURL := clientHealthURL(ctx, "besu-health-url", url)
+		if healthURL == "" || !checkEnabled("besu-readiness") {
+			return
+		}
+		start := time.Now()
+		m.Besu, err = clients.BesuReadiness(healthCtx, healthURL, settings().GetInt("min-peers"), settings().GetInt("besu-max-blocks-behind"))
+		observeRPC(ctx, "besu_readiness", start)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to retrieve the Besu readiness")
+			errs.add("besu_readiness", err)
+		} else if !m.Besu.Up() {
+			log.Error().
+				Int("status_code", m.Besu.StatusCode).
+				Str("status", m.Besu.Status).
+				Msg("Besu reports the node as not ready")
+		}
+	case "Nitro":
+		// Nitro serves no health endpoint of its own, so only check one that
+		// is configured
+		healthURL := settings().GetString("nitro-health-url")
+		if healthURL == "" || !checkEnabled("nitro") {
+			return
+		}
+		healthCtx, healthURL := endpointContext(ctx, healthURL)
+		measureNitroHealth(healthCtx, healthURL, m, errs)
+	}
+}
+
+// evaluate runs the enabled checks against the measurements and records the
+// gauges of the endpoints they judged
+func evaluate(ctx context.Context, m *health.Client, t health.Thresholds) HealthResult {
+	result := HealthResult{Report: activeChecks().Evaluate(ctx, m, t)}
+
+	if check, ok := result.Checks["ws"]; ok {
+		wsHealthyGauge.Set(boolToFloat(check.OK))
+	}
+	if result.Builder != nil {
+		builderHealthyGauge.Set(boolToFloat(result.Builder.Healthy))
+	}
+	return result
+}
+
+func nodeHealth(ctx context.Context, node *nodeClient) HealthResult {
+	ctx, cancel := context.WithTimeout(node.context(ctx), settings().GetDuration("check-timeout"))
+	defer cancel()
+	ctx, span := startEvaluation(ctx, node)
+	defer span.End()
+	ctx = withLatencyTracker(ctx, node.latency)
+
+	m := measure(ctx, node)
+	m.RPCLatency, m.RPCLatencySamples = node.latency.percentile(health.LatencyMethod, settings().GetFloat64("rpc-latency-percentile"))
+	if m.Errors["connection"] == nil && m.Errors["block_delta"] == nil && m.BlockNumber != 0 {
+		m.Head = node.heads.observe(node.url, m.ChainID, m.BlockNumber, m.BlockHash, settings().GetUint64("max-reorg-depth"))
+
+		if persistedHeads != nil && m.ChainID != 0 {
+			m.PersistedHighest = persistedHeads.get(m.ChainID)
+			persistedHeads.record(m.ChainID, m.BlockNumber)
+		}
+	}
+	node.startup.observe(m)
+	node.grace.observe(m)
+	result := evaluate(ctx, m, thresholdsFromConfig())
+	node.grace.apply(&result)
+	result.Syncing = node.sync.observe(m)
+
+	if check, ok := result.Checks["restart_rollback"]; ok && !check.OK {
+		log.Error().
+			Uint64("block_number", m.BlockNumber).
+			Uint64("recorded_block_number", m.PersistedHighest).
+			Str("state_file", persistedHeads.path).
+			Msg("Node head is far below the block recorded before the restart, staying not ready until it catches up or the state is cleared")
+	}
+
+	span.SetAttributes(
+		attribute.Bool("medic.healthy", result.Healthy),
+		attribute.StringSlice("medic.reasons", result.Reasons),
+		attribute.Int64("medic.block_number", int64(m.BlockNumber)),
+	)
+	if !result.Healthy {
+		span.SetStatus(codes.Error, strings.Join(result.Reasons, ", "))
+	}
+
+	node.logger().Info().
+		Bool("is_node_healthy", result.Healthy).
+		Strs("reasons", result.Reasons).
+		Strs("warnings", result.Warnings).
+		Int("peer_count", m.PeerCount).
+		Int("block_delta", int(m.BlockDelta.Seconds())).
+		Uint64("block_number", m.BlockNumber).
+		Str("client_type", m.Type).
+		Msg("Node health check")
+
+	return result
+}
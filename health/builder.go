@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+// Builder is what the status endpoint of mev-boost returned
+type Builder struct {
+	Err error
+}
+
+// BuilderStatus is the mev-boost health reported in the report
+type BuilderStatus struct {
+	Healthy bool `json:"healthy"`
+	// Reason tells a timeout, a refused connection and an unexpected status
+	// code apart
+	Reason     string `json:"reason,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Required is set when the builder gates readiness
+	Required bool `json:"required"`
+}
+
+// builderFailure returns the reason and status code for a failed call to the
+// mev-boost status endpoint
+func builderFailure(err error) (string, int) {
+	var statusErr *clients.HTTPStatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		return "builder_bad_status", statusErr.StatusCode
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "builder_timeout", 0
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "builder_connection_refused", 0
+	}
+	return "builder_unhealthy", 0
+}
+
+// builderCheck judges mev-boost when it was measured. It only warns unless
+// BuilderRequired is set, since a node without a builder still proposes from
+// its local block.
+type builderCheck struct{}
+
+func (builderCheck) Name() string {
+	return "builder"
+}
+
+func (builderCheck) Evaluate(ctx context.Context, c *Client, t Thresholds, r *Report) {
+	if c.Builder == nil {
+		return
+	}
+
+	status := &BuilderStatus{Healthy: c.Builder.Err == nil, Required: t.BuilderRequired}
+	result := Result{Name: "builder", OK: true}
+	if err := c.Builder.Err; err != nil {
+		status.Reason, status.StatusCode = builderFailure(err)
+		status.Error = clients.WithoutURL(err).Error()
+		result.OK, result.Warning = !t.BuilderRequired, !t.BuilderRequired
+		result.Reason, result.Err = status.Reason, clients.WithoutURL(err)
+	}
+	r.Add(result)
+	r.Builder = status
+}
@@ -0,0 +1,395 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+// executionCheck is a Check on the execution client. It is skipped when the
+// client could not be reached, since the connection check already fails.
+type executionCheck struct {
+	name     string
+	evaluate func(c *Client, t Thresholds, r *Report)
+}
+
+func (c executionCheck) Name() string {
+	return c.name
+}
+
+func (c executionCheck) Evaluate(ctx context.Context, client *Client, t Thresholds, r *Report) {
+	if client.Errors["connection"] != nil {
+		return
+	}
+	c.evaluate(client, t, r)
+}
+
+// besuOverrides reports whether Besu's readiness endpoint replaces the
+// generic block, peer and sync checks, since it covers the same ground
+func besuOverrides(c *Client, t Thresholds) bool {
+	return t.BesuOnly && c.Besu != nil
+}
+
+func evaluateBlockDelta(c *Client, t Thresholds, r *Report) {
+	if besuOverrides(c, t) {
+		return
+	}
+	if err := c.Errors["block_delta"]; err != nil {
+		r.Add(errorResult("block_delta", err))
+		return
+	}
+
+	result := Result{
+		Name:      "block_delta",
+		OK:        c.BlockDelta <= t.MaxBlockAge,
+		Value:     int(c.BlockDelta.Seconds()),
+		Threshold: int(t.MaxBlockAge.Seconds()),
+	}
+	if !result.OK {
+		result.Reason = "block_delta_exceeded"
+	}
+	r.Add(result)
+}
+
+func evaluateHeadProgress(c *Client, t Thresholds, r *Report) {
+	if besuOverrides(c, t) || t.MaxHeadStall <= 0 || c.Errors["block_delta"] != nil {
+		return
+	}
+
+	result := Result{
+		Name:      "head_progress",
+		OK:        c.Head.Stalled <= t.MaxHeadStall,
+		Value:     int(c.Head.Stalled.Seconds()),
+		Threshold: int(t.MaxHeadStall.Seconds()),
+	}
+	if !result.OK {
+		result.Reason = "head_stalled"
+		result.Err = fmt.Errorf("head stuck at block %d", c.BlockNumber)
+	}
+	r.Add(result)
+}
+
+func evaluateReorg(c *Client, t Thresholds, r *Report) {
+	if c.Errors["block_delta"] != nil || c.Head.Highest == 0 {
+		return
+	}
+
+	depth := c.Head.Highest - c.BlockNumber
+	result := Result{Name: "reorg", OK: true, Value: depth, Threshold: t.MaxReorgDepth}
+	switch {
+	case depth > t.MaxReorgDepth:
+		result.OK = false
+		result.Err = fmt.Errorf("head rolled back from %d to %d", c.Head.Highest, c.BlockNumber)
+	case c.Head.HashChanges >= MaxHashChanges:
+		result.OK = false
+		result.Err = fmt.Errorf("block %d changed hash %d times in a row", c.BlockNumber, c.Head.HashChanges)
+	}
+	if !result.OK {
+		result.Reason = "head_rolled_back"
+	}
+	r.Add(result)
+}
+
+func evaluateRestartRollback(c *Client, t Thresholds, r *Report) {
+	if c.PersistedHighest == 0 || c.Errors["block_delta"] != nil {
+		return
+	}
+
+	var depth uint64
+	if c.PersistedHighest > c.BlockNumber {
+		depth = c.PersistedHighest - c.BlockNumber
+	}
+	result := Result{Name: "restart_rollback", OK: depth <= t.MaxRestartRollback, Value: depth, Threshold: t.MaxRestartRollback}
+	if !result.OK {
+		result.Reason = "restart_rollback"
+		result.Err = fmt.Errorf("head %d is below the recorded highest block %d", c.BlockNumber, c.PersistedHighest)
+	}
+	r.Add(result)
+}
+
+// evaluatePeers fails when the node has fewer than MinPeers peers. Nodes that
+// do not serve their peer count, such as hosted providers, skip the check
+// unless PeersRequired.
+func evaluatePeers(c *Client, t Thresholds, r *Report) {
+	if besuOverrides(c, t) {
+		return
+	}
+	// The peer count is not requested at all when no peers are required
+	if t.MinPeers == 0 {
+		r.Add(Result{Name: "peers", OK: true, Skipped: true, Reason: "min_peers_zero"})
+		return
+	}
+	if err := c.PeersUnavailable; err != nil {
+		if t.PeersRequired {
+			r.Add(errorResult("peers", err))
+			return
+		}
+		r.Add(Result{Name: "peers", OK: true, Skipped: true, Reason: "peer_count_unavailable", Err: err})
+		return
+	}
+	if err := c.Errors["peers"]; err != nil {
+		r.Add(errorResult("peers", err))
+		return
+	}
+
+	result := Result{
+		Name:      "peers",
+		OK:        c.PeerCount >= t.MinPeers,
+		Value:     c.PeerCount,
+		Threshold: t.MinPeers,
+	}
+	if !result.OK {
+		result.Reason = "min_peers_not_met"
+	}
+	r.Add(result)
+	r.Peers = c.AdminPeers
+}
+
+// evaluateSyncing fails while the node is syncing. Erigon and Reth keep
+// executing later stages after the headers catch up, so they fail while a
+// stage trails the highest block by more than MaxStageDistance instead.
+func evaluateSyncing(c *Client, t Thresholds, r *Report) {
+	if besuOverrides(c, t) || nitroOverrides(c) {
+		return
+	}
+	if err := c.Errors["syncing"]; err != nil {
+		r.Add(errorResult("syncing", err))
+		return
+	}
+	if c.SyncStatus == nil {
+		r.Add(Result{Name: "syncing", OK: true, Skipped: true, Reason: "sync_status_unavailable"})
+		return
+	}
+
+	status, syncing := c.SyncStatus, c.SyncStatus.Syncing
+	if staged := stagedSyncStatus(c); staged != nil {
+		status = staged
+		r.SyncStage = staged.LaggingStage(t.MaxStageDistance)
+		syncing = r.SyncStage != nil
+	}
+	result := Result{Name: "syncing", OK: !syncing, Value: status}
+	if !result.OK {
+		result.Reason = "node_syncing"
+	}
+	r.Add(result)
+}
+
+// stagedSyncStatus returns the sync status with the stage checkpoints of
+// Erigon or Reth, or nil for other clients and when no stages were reported.
+// Reth checkpoints scraped from its metrics take precedence over eth_syncing.
+func stagedSyncStatus(c *Client) *clients.SyncStatus {
+	if c.Type != "Erigon" && c.Type != "Reth" {
+		return nil
+	}
+
+	status := *c.SyncStatus
+	if len(c.RethStages) != 0 {
+		status.Stages = c.RethStages
+	}
+	if len(status.Stages) == 0 {
+		return nil
+	}
+
+	// eth_syncing reports no highest block once it returns false, so compare
+	// the stages against the furthest known block instead
+	if status.HighestBlock == 0 {
+		status.HighestBlock = c.BlockNumber
+		for _, stage := range status.Stages {
+			status.HighestBlock = max(status.HighestBlock, stage.BlockNumber)
+		}
+	}
+
+	return &status
+}
+
+func evaluateChainID(c *Client, t Thresholds, r *Report) {
+	if err := c.Errors["chain_id"]; err != nil {
+		r.Add(errorResult("chain_id", err))
+		return
+	}
+	if t.ExpectedChainID == 0 {
+		return
+	}
+	result := Result{
+		Name:      "chain_id",
+		OK:        c.ChainID == t.ExpectedChainID,
+		Value:     c.ChainID,
+		Threshold: t.ExpectedChainID,
+	}
+	if !result.OK {
+		result.Reason = "chain_id_mismatch"
+	}
+	r.Add(result)
+}
+
+func evaluateFinalizedLag(c *Client, t Thresholds, r *Report) {
+	r.Finalized = evaluateTaggedBlock("finalized", c.Finalized, c.Errors["finalized"], t.MaxFinalizedLag, r)
+}
+
+func evaluateSafeLag(c *Client, t Thresholds, r *Report) {
+	r.Safe = evaluateTaggedBlock("safe", c.Safe, c.Errors["safe"], t.MaxSafeLag, r)
+}
+
+// evaluateTaggedBlock adds the <tag>_lag check comparing the age of a block
+// tag against maxLag, and returns the block for the report. Tags the node
+// does not know, e.g. on pre-merge chains, skip the check.
+func evaluateTaggedBlock(tag string, block *BlockTag, err error, maxLag time.Duration, r *Report) *TaggedBlock {
+	name := tag + "_lag"
+	switch {
+	case maxLag <= 0:
+		return nil
+	case err != nil:
+		r.Add(errorResult(name, err))
+		return nil
+	case block == nil:
+		return nil
+	case block.Unavailable != nil:
+		r.Add(Result{Name: name, OK: true, Skipped: true, Reason: tag + "_unavailable", Err: block.Unavailable})
+		return nil
+	}
+
+	result := Result{
+		Name:      name,
+		OK:        block.Age <= maxLag,
+		Value:     int(block.Age.Seconds()),
+		Threshold: int(maxLag.Seconds()),
+	}
+	if !result.OK {
+		result.Reason = name + "_exceeded"
+		result.Err = fmt.Errorf("%s block %d is %s old", tag, block.Number, block.Age.Round(time.Second))
+	}
+	r.Add(result)
+
+	return &TaggedBlock{Number: block.Number, Age: block.Age.Seconds()}
+}
+
+// evaluateRPCLatency fails when the latency percentile of LatencyMethod
+// exceeds MaxRPCLatency
+func evaluateRPCLatency(c *Client, t Thresholds, r *Report) {
+	if t.MaxRPCLatency <= 0 || c.RPCLatencySamples == 0 {
+		return
+	}
+
+	result := Result{
+		Name:      "rpc_latency",
+		OK:        c.RPCLatency <= t.MaxRPCLatency,
+		Value:     int(c.RPCLatency.Milliseconds()),
+		Threshold: int(t.MaxRPCLatency.Milliseconds()),
+	}
+	if !result.OK {
+		result.Reason = "rpc_latency_high"
+		result.Err = fmt.Errorf("p%g of %s over the last %d calls is %dms", t.RPCLatencyPercentile, LatencyMethod, c.RPCLatencySamples, c.RPCLatency.Milliseconds())
+	}
+	r.Add(result)
+}
+
+func evaluateRethStages(c *Client, t Thresholds, r *Report) {
+	if err := c.Errors["reth_stages"]; err != nil {
+		r.Add(errorResult("reth_stages", err))
+	}
+}
+
+func evaluateBesuReadiness(c *Client, t Thresholds, r *Report) {
+	if err := c.Errors["besu_readiness"]; err != nil {
+		r.Add(errorResult("besu_readiness", err))
+		return
+	}
+	if c.Besu == nil {
+		return
+	}
+	result := Result{Name: "besu_readiness", OK: c.Besu.Up(), Value: c.Besu.Status}
+	if !result.OK {
+		result.Reason = "besu_not_ready"
+	}
+	r.Add(result)
+}
+
+// evaluateNethermindHealth judges the response of the Nethermind health
+// endpoint. The node-health entry, or the overall status without one, is
+// reported as nethermind_health and also fails on the errors and sync flag in
+// its data. Every other entry is reported as a detail.
+func evaluateNethermindHealth(c *Client, t Thresholds, r *Report) {
+	if err := c.Errors["nethermind_health"]; err != nil {
+		r.Add(errorResult("nethermind_health", err))
+		return
+	}
+	health := c.Nethermind
+	if health == nil {
+		return
+	}
+
+	// Responses without a node-health entry are judged by the overall status
+	entry, ok := health.Entries["node-health"]
+	result := Result{Name: "nethermind_health", OK: entry.Healthy()}
+	if !ok {
+		entry = clients.HealthEntry{Status: health.Status}
+		result.OK, result.Value = entry.Healthy(), health.Status
+	}
+	if data := health.NodeHealth(); data != nil {
+		result.OK = result.OK && len(data.Errors) == 0 && !data.IsSyncing
+		if len(data.Errors) != 0 {
+			result.Value = data.Errors
+		}
+	}
+	if !result.OK {
+		result.Reason = "nethermind_unhealthy"
+		result.Err = entryError(entry)
+	}
+
+	names := make([]string, 0, len(health.Entries))
+	for name := range health.Entries {
+		if name != "node-health" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		entry := health.Entries[name]
+		detail := Result{
+			Name:  "nethermind_" + strings.ReplaceAll(name, "-", "_"),
+			OK:    entry.Healthy(),
+			Value: entry.Status,
+		}
+		if !detail.OK {
+			detail.Reason = detail.Name + "_unhealthy"
+			detail.Err = entryError(entry)
+		}
+		result.Details = append(result.Details, detail)
+	}
+	r.Add(result)
+}
+
+// entryError returns the description of a failed entry as an error
+func entryError(entry clients.HealthEntry) error {
+	if entry.Description == "" {
+		return nil
+	}
+	return errors.New(entry.Description)
+}
+
+// evaluateMinBlock fails while the head is below MinBlock
+func evaluateMinBlock(c *Client, t Thresholds, r *Report) {
+	if c.Errors["connection"] != nil || c.Errors["block_delta"] != nil {
+		return
+	}
+	if t.MinBlockErr != nil {
+		r.Add(Result{Name: "min_block", OK: false, Err: t.MinBlockErr, Reason: "min_block_unreadable"})
+		return
+	}
+	if t.MinBlock == 0 {
+		return
+	}
+
+	result := Result{Name: "min_block", OK: c.BlockNumber >= t.MinBlock, Value: c.BlockNumber, Threshold: t.MinBlock}
+	if !result.OK {
+		result.Reason = "below_min_block"
+		result.Err = fmt.Errorf("head %d is below the minimum block %d", c.BlockNumber, t.MinBlock)
+	}
+	r.Add(result)
+}
@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+func TestEvaluateSyncingWithoutSyncStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientType string
+		err        error
+		ok         bool
+		skipped    bool
+		reason     string
+	}{
+		{name: "erigon rpc error", clientType: "Erigon", err: errors.New("eth_syncing: internal error"), reason: "rpc_error"},
+		{name: "reth timeout", clientType: "Reth", err: context.DeadlineExceeded, reason: "rpc_timeout"},
+		{name: "erigon not measured", clientType: "Erigon", ok: true, skipped: true, reason: "sync_status_unavailable"},
+		{name: "geth rpc error", clientType: "Geth", err: errors.New("eth_syncing: internal error"), reason: "rpc_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Type: tt.clientType, Errors: map[string]error{}}
+			if tt.err != nil {
+				c.Errors["syncing"] = tt.err
+			}
+			var r Report
+
+			evaluateSyncing(c, Thresholds{MaxStageDistance: 32}, &r)
+
+			result, ok := r.Checks["syncing"]
+			if !ok {
+				t.Fatal("syncing check missing from the report")
+			}
+			if result.OK != tt.ok || result.Skipped != tt.skipped || result.Reason != tt.reason {
+				t.Errorf("syncing = %+v, want ok %v, skipped %v, reason %q", result, tt.ok, tt.skipped, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEvaluateNethermindHealth(t *testing.T) {
+	tests := []struct {
+		fixture string
+		status  int
+		want    map[string]string
+	}{
+		{fixture: "healthy.json", status: http.StatusOK, want: map[string]string{"nethermind_health": ""}},
+		{
+			fixture: "syncing.json",
+			status:  http.StatusServiceUnavailable,
+			want:    map[string]string{"nethermind_health": "The node is still syncing, CurrentBlock: 19412030, HighestBlock: 19412251. Peers: 54."},
+		},
+		{
+			fixture: "no-cl-messages.json",
+			status:  http.StatusServiceUnavailable,
+			want:    map[string]string{"nethermind_health": "The node is now fully synced with a network. Peers: 61. No messages from CL since 300 seconds."},
+		},
+		{
+			fixture: "low-disk.json",
+			status:  http.StatusServiceUnavailable,
+			want:    map[string]string{"nethermind_health": "", "nethermind_db_size": "Low free disk space: 1.4% left.", "nethermind_process": ""},
+		},
+		{fixture: "legacy.json", status: http.StatusOK, want: map[string]string{"nethermind_health": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("..", "clients", "testdata", "nethermind", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			health := &clients.NethermindHealth{StatusCode: tt.status}
+			if err := json.Unmarshal(body, health); err != nil {
+				t.Fatal(err)
+			}
+			var r Report
+
+			evaluateNethermindHealth(&Client{Type: "Nethermind", Nethermind: health}, Thresholds{}, &r)
+
+			if len(r.Checks) != len(tt.want) {
+				t.Errorf("checks = %v, want %d", r.Checks, len(tt.want))
+			}
+			for name, description := range tt.want {
+				result, ok := r.Checks[name]
+				switch {
+				case !ok:
+					t.Errorf("%s missing from the report", name)
+				case description == "" && (!result.OK || result.Err != nil):
+					t.Errorf("%s = %+v, want a passed check", name, result)
+				case description != "" && (result.OK || result.Err == nil || result.Err.Error() != description):
+					t.Errorf("%s = %+v, want a failed check with error %q", name, result, description)
+				}
+			}
+		})
+	}
+}
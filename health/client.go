@@ -0,0 +1,97 @@
+package health
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rarecrumb/medic/clients"
+)
+
+// Client is the state of a node in one evaluation, as measured by the caller.
+// Measuring up front lets the checks share RPC calls, e.g. in a batch, and
+// lets them be run against any state in tests. Fields of measurements that
+// were not taken stay unset, which skips the checks that need them.
+type Client struct {
+	// Type is the detected client type, e.g. Geth or Nethermind, and Version
+	// the raw web3_clientVersion
+	Type    string
+	Version string
+
+	// BlockDelta is the age of the latest block
+	BlockDelta  time.Duration
+	BlockNumber uint64
+	BlockHash   common.Hash
+	// Head is what following the head across evaluations found
+	Head HeadObservation
+	// PersistedHighest is the highest head recorded before the last restart
+	PersistedHighest uint64
+	ChainID          uint64
+
+	PeerCount int
+	// PeersUnavailable is set when the node does not serve its peer count
+	PeersUnavailable error
+	// AdminPeers breaks the peer count down when admin_peers is served
+	AdminPeers *clients.PeerBreakdown
+
+	// SyncStatus holds the stage checkpoints of Erigon and Reth, which judge
+	// the sync instead of the syncing flag when set. RethStages are the
+	// checkpoints scraped from the Reth metrics and take precedence.
+	SyncStatus *clients.SyncStatus
+	RethStages []clients.SyncStage
+
+	// Nethermind and Besu are the responses of their health endpoints
+	Nethermind *clients.NethermindHealth
+	Besu       *clients.BesuHealth
+	// NitroProgress is the Nitro part of the sync status and NitroHealth the
+	// status code of the health endpoint in front of Nitro
+	NitroProgress *clients.NitroProgress
+	NitroHealth   int
+
+	Finalized *BlockTag
+	Safe      *BlockTag
+	Fork      *ForkVerification
+	ForkErr   error
+
+	// Probes are the optional probes that ran, by check name
+	Probes   map[string]*Probe
+	TxPool   *clients.TxPoolStatus
+	GasPrice *big.Int
+	BaseFee  *big.Int
+	// RPCLatency is the RPCLatencyPercentile of the latency of LatencyMethod
+	// over RPCLatencySamples calls
+	RPCLatency        time.Duration
+	RPCLatencySamples int
+
+	References []ReferenceHeight
+	Consensus  *Consensus
+	WS         *WebSocket
+	GraphQL    *GraphQL
+	Builder    *Builder
+	Rollup     *Rollup
+
+	// Errors is keyed by check name and records the measurements that
+	// failed. A connection error means the node could not be reached at all
+	// and skips every check on the execution client.
+	Errors map[string]error
+}
+
+// HeadObservation is what following the head of a node across evaluations
+// found
+type HeadObservation struct {
+	// Stalled is how long the head has been stuck at its current number
+	Stalled time.Duration
+	// Highest is the highest head seen for the endpoint and chain
+	Highest uint64
+	// HashChanges counts consecutive polls that returned a different hash
+	// at the same height
+	HashChanges int
+}
+
+// BlockTag is the measured number and age of a block tag such as finalized.
+// Unavailable is set when the node does not know the tag.
+type BlockTag struct {
+	Number      uint64
+	Age         time.Duration
+	Unavailable error
+}
@@ -0,0 +1,134 @@
+package health
+
+import (
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout bounds a Check of a Monitor without a Timeout
+const DefaultTimeout = 5 * time.Second
+
+// LatencyMethod is the RPC method whose latency MaxRPCLatency bounds
+const LatencyMethod = "eth_getBlockByNumber"
+
+// MaxHashChanges is the number of consecutive polls returning a different
+// hash at the same height after which the head is considered unstable
+const MaxHashChanges = 3
+
+// Config configures a Monitor and holds the thresholds of the checks
+type Config struct {
+	// URL is the http or https JSON-RPC endpoint of the node
+	URL string
+	// HTTPClient sends the requests to the node, a client with Timeout by
+	// default. Set it for custom TLS, proxies or authentication.
+	HTTPClient *http.Client
+	// Timeout bounds a single Check, DefaultTimeout when zero
+	Timeout time.Duration
+	// Checks replaces the checks run by a Monitor, DefaultChecks when nil
+	Checks []Check
+
+	Thresholds
+}
+
+// Thresholds are the limits the checks judge a node against. Optional checks
+// are disabled while their limit is zero.
+type Thresholds struct {
+	// MaxBlockAge is the maximum age of the latest block
+	MaxBlockAge time.Duration
+	// MaxHeadStall is how long the head may stay at the same number
+	MaxHeadStall time.Duration
+	// MinPeers is the minimum number of peers, 0 skips the peer check
+	MinPeers int
+	// PeersRequired fails the peer check when the node does not serve its
+	// peer count instead of skipping it
+	PeersRequired   bool
+	ExpectedChainID uint64
+	// BesuOnly lets the readiness endpoint of Besu replace the block, peer
+	// and sync checks
+	BesuOnly        bool
+	MaxFinalizedLag time.Duration
+	MaxSafeLag      time.Duration
+	MaxFinalityAge  time.Duration
+	// CLOptimisticGraceUntil is the time until which an optimistically
+	// synced beacon node only warns, since its execution client may still be
+	// catching up after a start
+	CLOptimisticGraceUntil time.Time
+	MinCLPeers             uint64
+	// BuilderRequired fails instead of warns when mev-boost is unhealthy
+	BuilderRequired bool
+	// MaxStageDistance is the number of blocks an Erigon or Reth sync stage
+	// may trail the highest block
+	MaxStageDistance   uint64
+	MaxReorgDepth      uint64
+	MaxRestartRollback uint64
+	GetLogsMaxLatency  time.Duration
+	MaxTxPoolPending   uint64
+	// MinGasPrice and MaxGasPrice bound the base fee in wei
+	MinGasPrice *big.Int
+	MaxGasPrice *big.Int
+
+	MaxBlocksBehindReference uint64
+
+	MaxGraphQLDistance   uint64
+	MaxRPCLatency        time.Duration
+	RPCLatencyPercentile float64
+
+	MaxRollupUnsafeAge    time.Duration
+	MaxRollupSafeAge      time.Duration
+	MaxRollupFinalizedAge time.Duration
+	MaxL1OriginLag        uint64
+
+	MaxNitroMsgLag uint64
+
+	// MinBlock is the height the head must reach, with MinBlockErr set when
+	// the gate could not be read
+	MinBlock    uint64
+	MinBlockErr error
+}
+
+// DefaultConfig returns the thresholds medic uses by default for the node at
+// url
+func DefaultConfig(url string) Config {
+	return Config{
+		URL: url,
+		Thresholds: Thresholds{
+			MaxBlockAge:      30 * time.Second,
+			MinPeers:         3,
+			MaxStageDistance: 32,
+		},
+	}
+}
+
+// DefaultChecks returns every check of this package in evaluation order
+func DefaultChecks() []Check {
+	return []Check{
+		executionCheck{"block-delta", evaluateBlockDelta},
+		executionCheck{"head-progress", evaluateHeadProgress},
+		executionCheck{"reorg", evaluateReorg},
+		executionCheck{"restart-rollback", evaluateRestartRollback},
+		executionCheck{"peers", evaluatePeers},
+		executionCheck{"syncing", evaluateSyncing},
+		executionCheck{"chain-id", evaluateChainID},
+		executionCheck{"fork", evaluateFork},
+		executionCheck{"finalized-lag", evaluateFinalizedLag},
+		executionCheck{"safe-lag", evaluateSafeLag},
+		executionCheck{"getlogs", evaluateGetLogs},
+		executionCheck{"rpc-latency", evaluateRPCLatency},
+		executionCheck{"state", evaluateState},
+		executionCheck{"archive", evaluateArchive},
+		executionCheck{"trace", evaluateTrace},
+		executionCheck{"graphql", evaluateGraphQL},
+		executionCheck{"txpool", evaluateTxPool},
+		executionCheck{"gas-price", evaluateGasPrice},
+		executionCheck{"reference", evaluateReference},
+		executionCheck{"nethermind-health", evaluateNethermindHealth},
+		executionCheck{"besu-readiness", evaluateBesuReadiness},
+		executionCheck{"reth-stages", evaluateRethStages},
+		executionCheck{"nitro", evaluateNitro},
+		consensusCheck{},
+		wsCheck{},
+		builderCheck{},
+		rollupCheck{},
+	}
+}
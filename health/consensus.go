@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+// Consensus holds the raw values collected from the beacon node. Errors is
+// keyed by check name like Client.Errors.
+type Consensus struct {
+	Client       clients.ClientInfo
+	HealthStatus int
+	Syncing      *clients.BeaconSyncing
+	Peers        *clients.BeaconPeerCount
+	// Finality is nil when the finalized checkpoint could not be dated
+	Finality *Finality
+	Errors   map[string]error
+}
+
+// Finality is the finalized checkpoint of the beacon head state
+type Finality struct {
+	Epoch uint64
+	// Age is the time since the finalized epoch started
+	Age time.Duration
+}
+
+// ConsensusStatus summarizes the consensus client in the report
+type ConsensusStatus struct {
+	HeadSlot     uint64 `json:"head_slot"`
+	SyncDistance uint64 `json:"sync_distance"`
+	PeerCount    uint64 `json:"peer_count"`
+	IsSyncing    bool   `json:"is_syncing"`
+	IsOptimistic bool   `json:"is_optimistic"`
+	ELOffline    bool   `json:"el_offline"`
+
+	Client   clients.ClientInfo `json:"client"`
+	Finality *ConsensusFinality `json:"finality,omitempty"`
+}
+
+// ConsensusFinality is the finalized checkpoint of the beacon node
+type ConsensusFinality struct {
+	FinalizedEpoch uint64  `json:"finalized_epoch"`
+	Age            float64 `json:"age_seconds"`
+}
+
+// consensusCheck judges the consensus client when it was measured
+type consensusCheck struct{}
+
+func (consensusCheck) Name() string {
+	return "consensus"
+}
+
+func (consensusCheck) Evaluate(ctx context.Context, c *Client, t Thresholds, r *Report) {
+	if c.Consensus != nil {
+		evaluateConsensus(c.Consensus, t, r)
+	}
+}
+
+// evaluateConsensus adds the consensus client checks and summary to r
+func evaluateConsensus(m *Consensus, t Thresholds, r *Report) {
+	status := &ConsensusStatus{Client: m.Client}
+
+	if err := m.Errors["cl_health"]; err != nil {
+		r.Add(errorResult("cl_health", err))
+	} else {
+		result := Result{Name: "cl_health", OK: m.HealthStatus == http.StatusOK, Value: m.HealthStatus}
+		if !result.OK {
+			result.Reason = "cl_unhealthy"
+		}
+		r.Add(result)
+	}
+
+	if err := m.Errors["cl_syncing"]; err != nil {
+		r.Add(errorResult("cl_syncing", err))
+	} else {
+		status.HeadSlot = m.Syncing.HeadSlot
+		status.SyncDistance = m.Syncing.SyncDistance
+		status.IsSyncing = m.Syncing.IsSyncing
+		// Beacon nodes that do not report a flag are treated as reporting false
+		status.IsOptimistic = m.Syncing.IsOptimistic != nil && *m.Syncing.IsOptimistic
+		status.ELOffline = m.Syncing.ELOffline != nil && *m.Syncing.ELOffline
+
+		result := Result{Name: "cl_syncing", OK: !m.Syncing.IsSyncing, Value: m.Syncing.SyncDistance}
+		if !result.OK {
+			result.Reason = "cl_syncing"
+		}
+		r.Add(result)
+
+		evaluateOptimistic(status.IsOptimistic, t, r)
+
+		result = Result{Name: "cl_el_offline", OK: !status.ELOffline}
+		if !result.OK {
+			result.Reason = "cl_el_offline"
+			result.Err = errors.New("beacon node reports its execution client offline")
+		}
+		r.Add(result)
+	}
+
+	if m.Peers != nil {
+		status.PeerCount = m.Peers.Connected
+	}
+	if t.MinCLPeers > 0 && m.Peers != nil {
+		result := Result{Name: "cl_peers", OK: m.Peers.Connected >= t.MinCLPeers, Value: m.Peers.Connected, Threshold: t.MinCLPeers}
+		if !result.OK {
+			result.Reason = "cl_peers_low"
+		}
+		r.Add(result)
+	}
+
+	if m.Finality != nil {
+		status.Finality = &ConsensusFinality{FinalizedEpoch: m.Finality.Epoch, Age: m.Finality.Age.Seconds()}
+	}
+	evaluateFinalityAge(m, t, r)
+
+	r.Consensus = status
+}
+
+// evaluateOptimistic fails while the beacon node follows the head
+// optimistically, since it cannot attest correctly until its execution
+// client has verified the payloads. Until CLOptimisticGraceUntil it only
+// warns, as the execution client may still be catching up.
+func evaluateOptimistic(optimistic bool, t Thresholds, r *Report) {
+	result := Result{Name: "cl_optimistic", OK: !optimistic}
+	if optimistic {
+		result.Reason = "cl_optimistic"
+		result.Err = errors.New("beacon node is optimistically synced")
+		if time.Now().Before(t.CLOptimisticGraceUntil) {
+			result.OK, result.Warning = true, true
+		}
+	}
+	r.Add(result)
+}
+
+// evaluateFinalityAge fails when the finalized checkpoint is older than
+// MaxFinalityAge, since loss of finality precedes trouble on the execution
+// client
+func evaluateFinalityAge(m *Consensus, t Thresholds, r *Report) {
+	if t.MaxFinalityAge <= 0 {
+		return
+	}
+	if err := m.Errors["cl_finality"]; err != nil {
+		r.Add(errorResult("cl_finality", err))
+		return
+	}
+	if m.Finality == nil {
+		return
+	}
+
+	result := Result{
+		Name:      "cl_finality",
+		OK:        m.Finality.Age <= t.MaxFinalityAge,
+		Value:     int(m.Finality.Age.Seconds()),
+		Threshold: int(t.MaxFinalityAge.Seconds()),
+	}
+	if !result.OK {
+		result.Reason = "finality_age_exceeded"
+		result.Err = fmt.Errorf("finalized epoch %d is %s old", m.Finality.Epoch, m.Finality.Age.Round(time.Second))
+	}
+	r.Add(result)
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+// WebSocket is what a separate WebSocket endpoint of the node returned
+type WebSocket struct {
+	ChainID uint64
+	Err     error
+	// HeadsErr is set when its newHeads subscription stopped delivering
+	// headers
+	HeadsErr error
+}
+
+// GraphQL is what the GraphQL endpoint of the node returned
+type GraphQL struct {
+	BlockNumber uint64
+	Err         error
+	// NotEnabled is set while the endpoint answers 404 and never answered
+	// otherwise, meaning GraphQL is not enabled on the node
+	NotEnabled bool
+}
+
+// wsCheck judges a separate WebSocket endpoint when it was measured. Block
+// delta and peers still come from the measured JSON-RPC endpoint.
+type wsCheck struct{}
+
+func (wsCheck) Name() string {
+	return "ws"
+}
+
+func (wsCheck) Evaluate(ctx context.Context, c *Client, t Thresholds, r *Report) {
+	if c.WS == nil {
+		return
+	}
+
+	result := Result{Name: "ws", OK: true, Value: c.WS.ChainID}
+	switch {
+	case c.WS.Err != nil:
+		result.OK, result.Err = false, clients.WithoutURL(c.WS.Err)
+	case c.ChainID != 0 && c.WS.ChainID != c.ChainID:
+		result.OK, result.Err = false, fmt.Errorf("WebSocket endpoint serves chain %d, eth-url serves chain %d", c.WS.ChainID, c.ChainID)
+	case c.WS.HeadsErr != nil:
+		result.OK, result.Err = false, c.WS.HeadsErr
+	}
+	if !result.OK {
+		result.Reason = "ws_unhealthy"
+	}
+	r.Add(result)
+}
+
+// evaluateGraphQL fails when the GraphQL endpoint errors or serves a head
+// more than MaxGraphQLDistance away from the JSON-RPC head
+func evaluateGraphQL(c *Client, t Thresholds, r *Report) {
+	if c.GraphQL == nil {
+		return
+	}
+	if c.GraphQL.NotEnabled {
+		r.Add(Result{Name: "graphql", OK: true, Skipped: true, Reason: "graphql_not_enabled"})
+		return
+	}
+
+	result := Result{Name: "graphql", OK: true, Value: c.GraphQL.BlockNumber, Threshold: t.MaxGraphQLDistance}
+	switch {
+	case c.GraphQL.Err != nil:
+		result.OK, result.Value, result.Err = false, nil, clients.WithoutURL(c.GraphQL.Err)
+	case c.Errors["block_delta"] == nil && c.BlockNumber != 0:
+		var distance uint64
+		if c.GraphQL.BlockNumber > c.BlockNumber {
+			distance = c.GraphQL.BlockNumber - c.BlockNumber
+		} else {
+			distance = c.BlockNumber - c.GraphQL.BlockNumber
+		}
+		if distance > t.MaxGraphQLDistance {
+			result.OK = false
+			result.Err = fmt.Errorf("graphql head %d is %d blocks from the json-rpc head %d", c.GraphQL.BlockNumber, distance, c.BlockNumber)
+		}
+	}
+	if !result.OK {
+		result.Reason = "graphql_unhealthy"
+	}
+	r.Add(result)
+}
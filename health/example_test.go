@@ -0,0 +1,87 @@
+package health_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"time"
+
+	"github.com/rarecrumb/medic/clients/clienttest"
+	"github.com/rarecrumb/medic/health"
+)
+
+func ExampleNew() {
+	node := clienttest.NewServer()
+	defer node.Close()
+
+	config := health.DefaultConfig(node.URL)
+	config.MinPeers = 5
+	config.Timeout = 2 * time.Second
+	// A client of your own carries custom TLS, proxies or authentication
+	config.HTTPClient = &http.Client{Timeout: config.Timeout}
+
+	monitor, err := health.New(config)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(monitor.Check(context.Background()).Healthy)
+	// Output: true
+}
+
+func ExampleMonitor_Check() {
+	node := clienttest.NewServer()
+	defer node.Close()
+	node.Handle("net_peerCount", clienttest.PeerCount(1))
+
+	monitor, err := health.New(health.DefaultConfig(node.URL))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	report := monitor.Check(context.Background())
+	fmt.Println("healthy:", report.Healthy)
+	fmt.Println("reasons:", report.Reasons)
+
+	names := make([]string, 0, len(report.Checks))
+	for name := range report.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s ok=%v\n", name, report.Checks[name].OK)
+	}
+	// Output:
+	// healthy: false
+	// reasons: [min_peers_not_met]
+	// block_delta ok=true
+	// peers ok=false
+	// syncing ok=true
+}
+
+func ExampleMonitor_Handler() {
+	node := clienttest.NewServer()
+	defer node.Close()
+	node.Handle("eth_getBlockByNumber", clienttest.Block(1000, time.Now().Add(-time.Hour)))
+
+	monitor, err := health.New(health.DefaultConfig(node.URL))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	server := httptest.NewServer(monitor.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	resp.Body.Close()
+	fmt.Println(resp.StatusCode)
+	// Output: 503
+}
@@ -0,0 +1,41 @@
+package health
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PinnedBlock is a block whose hash must match for the node to be on the
+// expected fork
+type PinnedBlock struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+// ForkVerification is the outcome of checking every pinned block. Mismatch
+// is the first block whose hash differs, with Actual the hash the node
+// returned for it.
+type ForkVerification struct {
+	Mismatch *PinnedBlock
+	Actual   common.Hash
+}
+
+func evaluateFork(c *Client, t Thresholds, r *Report) {
+	switch {
+	case c.ForkErr != nil:
+		r.Add(errorResult("fork", c.ForkErr))
+		return
+	case c.Fork == nil:
+		return
+	}
+
+	result := Result{Name: "fork", OK: c.Fork.Mismatch == nil}
+	if mismatch := c.Fork.Mismatch; mismatch != nil {
+		result.Value = c.Fork.Actual.Hex()
+		result.Threshold = mismatch.Hash.Hex()
+		result.Reason = "fork_mismatch"
+		result.Err = fmt.Errorf("block %d has hash %s, expected %s", mismatch.Number, c.Fork.Actual.Hex(), mismatch.Hash.Hex())
+	}
+	r.Add(result)
+}
@@ -0,0 +1,188 @@
+// Package health judges the health of an Ethereum node. The caller measures
+// the node into a Client, and a Check judges that state against Thresholds
+// and adds its outcome to a Report. A Registry runs a set of checks in order.
+//
+// A Monitor measures a node over JSON-RPC and runs the checks against it, so
+// the checks medic runs can be embedded in other programs:
+//
+//	monitor, err := health.New(health.DefaultConfig("http://localhost:8545"))
+//	if err != nil {
+//		return err
+//	}
+//	report := monitor.Check(ctx)
+//	if !report.Healthy {
+//		log.Printf("node is unhealthy: %v", report.Reasons)
+//	}
+//
+// Monitor.Handler serves the same report over HTTP:
+//
+//	http.Handle("/health", monitor.Handler())
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Check is a named health check
+type Check interface {
+	// Name is the name used to select the check, e.g. block-delta
+	Name() string
+	// Evaluate judges the node measured in c against t and adds the outcome
+	// to r. Checks whose measurement is missing add nothing.
+	Evaluate(ctx context.Context, c *Client, t Thresholds, r *Report)
+}
+
+// Result is the outcome of a check
+type Result struct {
+	// Name is the name the outcome is reported under, e.g. block_delta
+	Name      string
+	OK        bool
+	Value     interface{}
+	Threshold interface{}
+	Err       error
+
+	// Reason is a machine-readable reason for a failed, skipped or warning
+	// check
+	Reason string
+
+	// Skipped is set for checks that passed without being evaluated, with
+	// Reason explaining why
+	Skipped bool
+
+	// Warning is set for checks that failed but were let pass, with Reason
+	// naming the failure
+	Warning bool
+
+	// Details are further outcomes of a check that reports several, such as
+	// the entries of the Nethermind health endpoint. Report.Add records them
+	// next to the result.
+	Details []Result
+}
+
+// resultJSON is the encoding of a Result in Report.Checks, which is keyed by
+// the name
+type resultJSON struct {
+	OK        bool        `json:"ok"`
+	Value     interface{} `json:"value,omitempty"`
+	Threshold interface{} `json:"threshold,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	Skipped   bool        `json:"skipped,omitempty"`
+	Warning   bool        `json:"warning,omitempty"`
+}
+
+// MarshalJSON encodes Err as its message
+func (r Result) MarshalJSON() ([]byte, error) {
+	encoded := resultJSON{
+		OK:        r.OK,
+		Value:     r.Value,
+		Threshold: r.Threshold,
+		Reason:    r.Reason,
+		Skipped:   r.Skipped,
+		Warning:   r.Warning,
+	}
+	if r.Err != nil {
+		encoded.Error = r.Err.Error()
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes the message of Err, e.g. of results read back from
+// an event log
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var decoded resultJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*r = Result{
+		OK:        decoded.OK,
+		Value:     decoded.Value,
+		Threshold: decoded.Threshold,
+		Reason:    decoded.Reason,
+		Skipped:   decoded.Skipped,
+		Warning:   decoded.Warning,
+	}
+	if decoded.Error != "" {
+		r.Err = errors.New(decoded.Error)
+	}
+	return nil
+}
+
+// errorResult builds the failed outcome of a check whose measurement failed,
+// tagging deadline errors with the rpc_timeout reason so slowness can be told
+// apart from bad data
+func errorResult(name string, err error) Result {
+	result := Result{Name: name, Reason: "rpc_error", Err: err}
+	if errors.Is(err, context.DeadlineExceeded) {
+		result.Reason = "rpc_timeout"
+	}
+	return result
+}
+
+// Registry is an ordered set of checks with unique names
+type Registry struct {
+	checks []Check
+}
+
+// NewRegistry returns a registry of checks, which must have unique names
+func NewRegistry(checks ...Check) (*Registry, error) {
+	r := &Registry{}
+	for _, check := range checks {
+		if err := r.Register(check); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds check after the checks registered so far
+func (r *Registry) Register(check Check) error {
+	if _, ok := r.Lookup(check.Name()); ok {
+		return fmt.Errorf("check %q is already registered", check.Name())
+	}
+	r.checks = append(r.checks, check)
+	return nil
+}
+
+// Lookup returns the check with the given name
+func (r *Registry) Lookup(name string) (Check, bool) {
+	for _, check := range r.checks {
+		if check.Name() == name {
+			return check, true
+		}
+	}
+	return nil, false
+}
+
+// Checks returns the registered checks in order
+func (r *Registry) Checks() []Check {
+	return append([]Check(nil), r.checks...)
+}
+
+// Evaluate runs every check against c and summarizes the outcome. A failed
+// connection and the min block gate are judged whatever checks are
+// registered, since no other check catches a node that imported an old
+// snapshot and misreports its sync status.
+func (r *Registry) Evaluate(ctx context.Context, c *Client, t Thresholds) Report {
+	report := Report{
+		ClientType:    c.Type,
+		ClientVersion: c.Version,
+		BlockNumber:   c.BlockNumber,
+		ChainID:       c.ChainID,
+		Checks:        map[string]Result{},
+	}
+
+	if err := c.Errors["connection"]; err != nil {
+		report.Add(errorResult("connection", err))
+	}
+	for _, check := range r.checks {
+		check.Evaluate(ctx, c, t, &report)
+	}
+	evaluateMinBlock(c, t, &report)
+
+	report.Summarize()
+	return report
+}
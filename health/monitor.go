@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+// Monitor checks the health of one node. It holds no state between checks
+// and is safe for concurrent use.
+type Monitor struct {
+	config   Config
+	options  *clients.Options
+	registry *Registry
+}
+
+// New returns a monitor for the node at config.URL
+func New(config Config) (*Monitor, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("health: URL must be an http or https URL")
+	}
+	if config.MaxBlockAge <= 0 {
+		return nil, errors.New("health: MaxBlockAge must be positive")
+	}
+	if config.MinPeers < 0 {
+		return nil, errors.New("health: MinPeers must not be negative")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	checks := config.Checks
+	if checks == nil {
+		checks = DefaultChecks()
+	}
+	registry, err := NewRegistry(checks...)
+	if err != nil {
+		return nil, fmt.Errorf("health: %w", err)
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+	options := &clients.Options{HTTPClient: client}
+	return &Monitor{config: config, options: options, registry: registry}, nil
+}
+
+// Check measures the node and runs every check against it
+func (m *Monitor) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+	defer cancel()
+
+	client := m.measure(clients.WithOptions(ctx, m.options))
+	return m.registry.Evaluate(ctx, client, m.config.Thresholds)
+}
+
+// Handler serves the report of every request as JSON, with status 200 while
+// the node is healthy and 503 otherwise
+func (m *Monitor) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := m.Check(r.Context())
+		status := http.StatusOK
+		if !report.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// measure collects the state the checks judge. The client type and the
+// execution state are fetched concurrently, the latter in one batch when the
+// node accepts batches.
+func (m *Monitor) measure(ctx context.Context) *Client {
+	client := &Client{Errors: map[string]error{}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if info, err := clients.DetectClientType(ctx, m.config.URL); err == nil {
+			client.Type, client.Version = info.Type, info.Raw
+		}
+	}()
+
+	query := clients.ExecutionQuery{Block: true, Peers: m.config.MinPeers > 0, Syncing: true}
+	status, err := clients.FetchExecutionStatus(ctx, m.config.URL, query)
+	if errors.Is(err, clients.ErrBatchUnsupported) {
+		status, err = m.fetchIndividually(ctx, query)
+	}
+	wg.Wait()
+
+	if err != nil {
+		client.Errors["connection"] = err
+		return client
+	}
+
+	if err := status.Errors["eth_getBlockByNumber"]; err != nil {
+		client.Errors["block_delta"] = err
+	} else {
+		client.BlockNumber, client.BlockHash = status.BlockNumber, status.BlockHash
+		client.BlockDelta = time.Since(time.Unix(int64(status.BlockTime), 0))
+	}
+	switch err := status.Errors["net_peerCount"]; {
+	case clients.IsMethodNotFound(err):
+		client.PeersUnavailable = err
+	case err != nil:
+		client.Errors["peers"] = err
+	default:
+		client.PeerCount = int(status.PeerCount)
+	}
+	if err := status.Errors["eth_syncing"]; err != nil {
+		client.Errors["syncing"] = err
+	} else {
+		client.SyncStatus = status.SyncStatus
+	}
+
+	if client.Type == "Nethermind" {
+		if client.Nethermind, err = clients.NethermindHealthCheck(ctx, m.config.URL); err != nil {
+			client.Errors["nethermind_health"] = err
+		}
+	}
+	return client
+}
+
+// fetchIndividually fetches what query selects with one request per call,
+// for nodes that reject batches
+func (m *Monitor) fetchIndividually(ctx context.Context, query clients.ExecutionQuery) (*clients.ExecutionStatus, error) {
+	status := &clients.ExecutionStatus{Errors: map[string]error{}}
+
+	header, err := clients.BlockByTag(ctx, m.config.URL, "latest")
+	if err != nil {
+		status.Errors["eth_getBlockByNumber"] = err
+	} else {
+		status.BlockNumber, status.BlockHash, status.BlockTime = header.Number, header.Hash, header.Timestamp
+	}
+	if query.Peers {
+		if status.PeerCount, err = clients.PeerCount(ctx, m.config.URL); err != nil {
+			status.Errors["net_peerCount"] = err
+		}
+	}
+	if status.SyncStatus, err = clients.CheckSyncStatus(ctx, m.config.URL); err != nil {
+		status.Errors["eth_syncing"] = err
+	}
+	return status, nil
+}
@@ -0,0 +1,348 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/clients/clienttest"
+)
+
+// check runs a monitor with config against node
+func check(t *testing.T, node *clienttest.Server, config Config) Report {
+	t.Helper()
+	config.URL = node.URL
+	monitor, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return monitor.Check(context.Background())
+}
+
+func TestMonitorBlockDelta(t *testing.T) {
+	tests := []struct {
+		name   string
+		age    time.Duration
+		ok     bool
+		reason string
+	}{
+		{name: "new block", age: 0, ok: true},
+		{name: "within max age", age: 20 * time.Second, ok: true},
+		{name: "beyond max age", age: 45 * time.Second, reason: "block_delta_exceeded"},
+		{name: "stalled node", age: time.Hour, reason: "block_delta_exceeded"},
+	}
+	for _, batches := range []bool{true, false} {
+		for _, tt := range tests {
+			name := tt.name
+			if !batches {
+				name += " without batches"
+			}
+			t.Run(name, func(t *testing.T) {
+				node := clienttest.NewServer()
+				defer node.Close()
+				node.RejectBatches(!batches)
+				node.Handle("eth_getBlockByNumber", clienttest.Block(1000, time.Now().Add(-tt.age)))
+
+				report := check(t, node, DefaultConfig(""))
+
+				result, ok := report.Checks["block_delta"]
+				if !ok {
+					t.Fatalf("block_delta missing from the report, checks %v", report.Checks)
+				}
+				if result.OK != tt.ok || result.Reason != tt.reason {
+					t.Errorf("block_delta = %+v, want ok %v, reason %q", result, tt.ok, tt.reason)
+				}
+				if report.Healthy != tt.ok {
+					t.Errorf("healthy = %v, want %v", report.Healthy, tt.ok)
+				}
+				if report.BlockNumber != 1000 {
+					t.Errorf("block number = %d, want 1000", report.BlockNumber)
+				}
+			})
+		}
+	}
+}
+
+func TestMonitorBlockDeltaErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		batches bool
+		setup   func(node *clienttest.Server)
+		check   string
+		reason  string
+	}{
+		{
+			name:    "rpc error",
+			batches: true,
+			setup:   func(node *clienttest.Server) { node.HandleError("eth_getBlockByNumber", -32000, "header not found") },
+			check:   "block_delta",
+			reason:  "rpc_error",
+		},
+		{
+			name:   "rpc error without batches",
+			setup:  func(node *clienttest.Server) { node.HandleError("eth_getBlockByNumber", -32000, "header not found") },
+			check:  "block_delta",
+			reason: "rpc_error",
+		},
+		{
+			// A slow call delays the whole batch, so the node counts as
+			// unreachable
+			name:    "timeout",
+			batches: true,
+			setup:   func(node *clienttest.Server) { node.SetMethodLatency("eth_getBlockByNumber", time.Second) },
+			check:   "connection",
+			reason:  "rpc_timeout",
+		},
+		{
+			name:   "timeout without batches",
+			setup:  func(node *clienttest.Server) { node.SetMethodLatency("eth_getBlockByNumber", time.Second) },
+			check:  "block_delta",
+			reason: "rpc_timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			node.RejectBatches(!tt.batches)
+			tt.setup(node)
+
+			config := DefaultConfig("")
+			config.Timeout = 200 * time.Millisecond
+			report := check(t, node, config)
+
+			result, ok := report.Checks[tt.check]
+			if !ok {
+				t.Fatalf("%s missing from the report, checks %v", tt.check, report.Checks)
+			}
+			if result.OK || result.Reason != tt.reason || result.Err == nil {
+				t.Errorf("%s = %+v, want a failed check with reason %s", tt.check, result, tt.reason)
+			}
+			if report.Healthy {
+				t.Error("node without a block reported healthy")
+			}
+		})
+	}
+}
+
+func TestMonitorPeers(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(node *clienttest.Server)
+		minPeers int
+		required bool
+		ok       bool
+		skipped  bool
+		reason   string
+	}{
+		{name: "no peers", setup: peers(0), minPeers: 3, reason: "min_peers_not_met"},
+		{name: "below minimum", setup: peers(2), minPeers: 3, reason: "min_peers_not_met"},
+		{name: "at minimum", setup: peers(3), minPeers: 3, ok: true},
+		{name: "above minimum", setup: peers(25), minPeers: 3, ok: true},
+		{name: "minimum of zero", setup: peers(0), minPeers: 0, ok: true, skipped: true, reason: "min_peers_zero"},
+		{
+			name:     "not served",
+			setup:    func(node *clienttest.Server) { node.Unhandle("net_peerCount") },
+			minPeers: 3,
+			ok:       true,
+			skipped:  true,
+			reason:   "peer_count_unavailable",
+		},
+		{
+			name:     "not served but required",
+			setup:    func(node *clienttest.Server) { node.Unhandle("net_peerCount") },
+			minPeers: 3,
+			required: true,
+			reason:   "rpc_error",
+		},
+		{
+			name:     "rpc error",
+			setup:    func(node *clienttest.Server) { node.HandleError("net_peerCount", -32000, "p2p server not running") },
+			minPeers: 3,
+			reason:   "rpc_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			tt.setup(node)
+
+			config := DefaultConfig("")
+			config.MinPeers, config.PeersRequired = tt.minPeers, tt.required
+			report := check(t, node, config)
+
+			result := report.Checks["peers"]
+			if result.OK != tt.ok || result.Skipped != tt.skipped || result.Reason != tt.reason {
+				t.Errorf("peers = %+v, want ok %v, skipped %v, reason %q", result, tt.ok, tt.skipped, tt.reason)
+			}
+		})
+	}
+}
+
+// peers programs net_peerCount to return count
+func peers(count uint64) func(node *clienttest.Server) {
+	return func(node *clienttest.Server) {
+		node.Handle("net_peerCount", clienttest.PeerCount(count))
+	}
+}
+
+func TestMonitorClientDetection(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(node *clienttest.Server)
+		clientType string
+	}{
+		{name: "geth", setup: version("Geth/v1.14.0-stable/linux-amd64/go1.22.0"), clientType: "Geth"},
+		{
+			name: "nethermind",
+			setup: func(node *clienttest.Server) {
+				version("Nethermind/v1.25.4+20b10b35/linux-x64/dotnet8.0.2")(node)
+				node.SetHealth(http.StatusOK, clienttest.NethermindHealthy)
+			},
+			clientType: "Nethermind",
+		},
+		{name: "unknown client", setup: version("FooClient/v0.1.0"), clientType: "Unknown"},
+		{
+			name:  "not served",
+			setup: func(node *clienttest.Server) { node.Unhandle("web3_clientVersion") },
+		},
+		{
+			name:  "malformed response",
+			setup: func(node *clienttest.Server) { node.HandleRaw("web3_clientVersion", `{"jsonrpc":`) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			tt.setup(node)
+
+			report := check(t, node, DefaultConfig(""))
+
+			if report.ClientType != tt.clientType {
+				t.Errorf("client type = %q, want %q", report.ClientType, tt.clientType)
+			}
+			// Detection is best effort and never fails the node
+			if !report.Healthy {
+				t.Errorf("node reported unhealthy: %v", report.Reasons)
+			}
+		})
+	}
+}
+
+// version programs web3_clientVersion to return raw
+func version(raw string) func(node *clienttest.Server) {
+	return func(node *clienttest.Server) {
+		node.Handle("web3_clientVersion", raw)
+	}
+}
+
+func TestMonitorNethermind(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(node *clienttest.Server)
+		checks  map[string]bool
+		reason  string
+		healthy bool
+	}{
+		{
+			name:    "healthy",
+			setup:   func(node *clienttest.Server) { node.SetHealth(http.StatusOK, clienttest.NethermindHealthy) },
+			checks:  map[string]bool{"nethermind_health": true, "nethermind_process": true},
+			healthy: true,
+		},
+		{
+			name: "syncing",
+			setup: func(node *clienttest.Server) {
+				node.SetHealth(http.StatusServiceUnavailable, clienttest.NethermindSyncing)
+			},
+			checks: map[string]bool{"nethermind_health": false, "nethermind_process": true},
+			reason: "nethermind_unhealthy",
+		},
+		{
+			name: "failing entry",
+			setup: func(node *clienttest.Server) {
+				node.SetHealth(http.StatusServiceUnavailable, clienttest.NethermindDiskFull)
+			},
+			checks: map[string]bool{"nethermind_health": true, "nethermind_db_size": false},
+			reason: "nethermind_db_size_unhealthy",
+		},
+		{
+			name:   "not enabled",
+			setup:  func(node *clienttest.Server) {},
+			checks: map[string]bool{"nethermind_health": false},
+			reason: "rpc_error",
+		},
+		{
+			name:   "malformed body",
+			setup:  func(node *clienttest.Server) { node.SetHealth(http.StatusOK, `{"status":`) },
+			checks: map[string]bool{"nethermind_health": false},
+			reason: "rpc_error",
+		},
+		{
+			name: "timeout",
+			setup: func(node *clienttest.Server) {
+				node.SetHealth(http.StatusOK, clienttest.NethermindHealthy)
+				node.SetHealthLatency(time.Second)
+			},
+			checks: map[string]bool{"nethermind_health": false},
+			reason: "rpc_timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			node.Handle("web3_clientVersion", "Nethermind/v1.25.4+20b10b35/linux-x64/dotnet8.0.2")
+			tt.setup(node)
+
+			config := DefaultConfig("")
+			config.Timeout = 200 * time.Millisecond
+			report := check(t, node, config)
+
+			for name, ok := range tt.checks {
+				result, found := report.Checks[name]
+				if !found {
+					t.Errorf("%s missing from the report", name)
+					continue
+				}
+				if result.OK != ok {
+					t.Errorf("%s = %+v, want ok %v", name, result, ok)
+				}
+			}
+			if report.Healthy != tt.healthy {
+				t.Errorf("healthy = %v, want %v", report.Healthy, tt.healthy)
+			}
+			if tt.reason != "" && !contains(report.Reasons, tt.reason) {
+				t.Errorf("reasons = %v, want %s", report.Reasons, tt.reason)
+			}
+		})
+	}
+}
+
+// The health endpoint is only requested from Nethermind nodes
+func TestMonitorNethermindOnly(t *testing.T) {
+	node := clienttest.NewServer()
+	defer node.Close()
+	node.SetHealth(http.StatusServiceUnavailable, clienttest.NethermindSyncing)
+
+	report := check(t, node, DefaultConfig(""))
+
+	if calls := node.HealthCalls(); calls != 0 {
+		t.Errorf("health endpoint of a Geth node requested %d times", calls)
+	}
+	if _, ok := report.Checks["nethermind_health"]; ok {
+		t.Error("nethermind_health reported for a Geth node")
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
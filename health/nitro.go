@@ -0,0 +1,80 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// NitroStatus summarizes the Arbitrum Nitro progress in the report. The
+// message fields are only reported by Nitro while it is behind.
+type NitroStatus struct {
+	Syncing bool `json:"syncing"`
+	// MessageLag is the number of messages seen from the sequencer feed or L1
+	// that the node has not processed yet
+	MessageLag  *uint64 `json:"message_lag,omitempty"`
+	MsgCount    *uint64 `json:"msg_count,omitempty"`
+	LastL1Block *uint64 `json:"last_l1_block,omitempty"`
+	// HealthStatusCode is the status code of the health endpoint in front of
+	// Nitro
+	HealthStatusCode int `json:"health_status_code,omitempty"`
+}
+
+// nitroOverrides reports whether the Nitro message lag replaces the generic
+// sync check. Nitro reports itself as syncing as soon as it trails the feed by
+// a message, which MaxNitroMsgLag tolerates.
+func nitroOverrides(c *Client) bool {
+	if c.NitroProgress == nil {
+		return false
+	}
+	_, ok := c.NitroProgress.MessageLag()
+	return ok
+}
+
+func evaluateNitro(c *Client, t Thresholds, r *Report) {
+	if c.Type != "Nitro" {
+		return
+	}
+	status := &NitroStatus{HealthStatusCode: c.NitroHealth}
+	r.Nitro = status
+
+	if err := c.Errors["nitro_health"]; err != nil {
+		r.Add(errorResult("nitro_health", err))
+	} else if c.NitroHealth != 0 {
+		result := Result{Name: "nitro_health", OK: c.NitroHealth == http.StatusOK, Value: c.NitroHealth}
+		if !result.OK {
+			result.Reason = "nitro_unhealthy"
+			result.Err = fmt.Errorf("health endpoint returned status %d", c.NitroHealth)
+		}
+		r.Add(result)
+	}
+
+	if c.SyncStatus == nil {
+		return
+	}
+	status.Syncing = c.SyncStatus.Syncing
+	if !c.SyncStatus.Syncing {
+		status.MessageLag = new(uint64)
+		r.Add(Result{Name: "nitro_feed", OK: true, Value: uint64(0), Threshold: t.MaxNitroMsgLag})
+		return
+	}
+
+	// Older versions report no message counts, leaving the node to the
+	// generic sync check
+	progress := c.NitroProgress
+	lag, ok := uint64(0), false
+	if progress != nil {
+		lag, ok = progress.MessageLag()
+	}
+	if !ok {
+		r.Add(Result{Name: "nitro_feed", OK: true, Skipped: true, Reason: "nitro_progress_unavailable"})
+		return
+	}
+	status.MessageLag, status.MsgCount, status.LastL1Block = &lag, progress.MsgCount, progress.LastL1BlockNum
+
+	result := Result{Name: "nitro_feed", OK: lag <= t.MaxNitroMsgLag, Value: lag, Threshold: t.MaxNitroMsgLag}
+	if !result.OK {
+		result.Reason = "nitro_feed_lag"
+		result.Err = fmt.Errorf("%d messages behind the sequencer feed at message count %d", lag, *progress.MsgCount)
+	}
+	r.Add(result)
+}
@@ -0,0 +1,163 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+// Probe is the outcome of an optional RPC probe that exercises what an
+// RPC-serving node must be able to answer, such as eth_getLogs
+type Probe struct {
+	Latency time.Duration
+	Err     error
+}
+
+// Fees are the gas price and base fee reported by the node, in gwei
+type Fees struct {
+	GasPrice float64 `json:"gas_price_gwei"`
+	BaseFee  float64 `json:"base_fee_gwei"`
+}
+
+func evaluateGetLogs(c *Client, t Thresholds, r *Report) {
+	evaluateProbe("getlogs", c.Probes["getlogs"], t.GetLogsMaxLatency, nil, r)
+}
+
+func evaluateState(c *Client, t Thresholds, r *Report) {
+	evaluateProbe("state", c.Probes["state"], 0, missingState("state_unavailable"), r)
+}
+
+func evaluateArchive(c *Client, t Thresholds, r *Report) {
+	evaluateProbe("archive", c.Probes["archive"], 0, missingState("archive_state_missing"), r)
+}
+
+func evaluateTrace(c *Client, t Thresholds, r *Report) {
+	evaluateProbe("trace", c.Probes["trace"], 0, traceFailure, r)
+}
+
+// evaluateProbe adds the check for a probe, failing it on errors and when the
+// probe took longer than maxLatency, if set. Errors for which classify returns
+// a reason are reported with it instead of as a generic RPC error.
+func evaluateProbe(name string, probe *Probe, maxLatency time.Duration, classify func(error) string, r *Report) {
+	if probe == nil {
+		return
+	}
+	if probe.Err != nil {
+		result := errorResult(name, probe.Err)
+		if classify != nil {
+			if reason := classify(probe.Err); reason != "" {
+				result.Reason = reason
+			}
+		}
+		r.Add(result)
+		return
+	}
+
+	result := Result{Name: name, OK: true, Value: probe.Latency.Milliseconds()}
+	if maxLatency == 0 {
+		r.Add(result)
+		return
+	}
+
+	result.OK = probe.Latency <= maxLatency
+	result.Threshold = maxLatency.Milliseconds()
+	if !result.OK {
+		result.Reason = name + "_slow"
+		result.Err = fmt.Errorf("%s took %s", name, probe.Latency.Round(time.Millisecond))
+	}
+	r.Add(result)
+}
+
+// missingState classifies errors meaning that the node cannot read the
+// requested state as reason
+func missingState(reason string) func(error) string {
+	return func(err error) string {
+		if clients.IsStateUnavailable(err) {
+			return reason
+		}
+		return ""
+	}
+}
+
+// traceFailure classifies every trace error other than a timeout as the node
+// being unable to serve traces
+func traceFailure(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ""
+	}
+	return "trace_unavailable"
+}
+
+func evaluateTxPool(c *Client, t Thresholds, r *Report) {
+	probe := c.Probes["txpool"]
+	switch {
+	case probe == nil:
+		return
+	case clients.IsMethodNotFound(probe.Err):
+		r.Add(Result{Name: "txpool", OK: true, Skipped: true, Reason: "txpool_unavailable", Err: probe.Err})
+		return
+	case probe.Err != nil:
+		r.Add(errorResult("txpool", probe.Err))
+		return
+	}
+
+	result := Result{
+		Name:      "txpool",
+		OK:        c.TxPool.Pending <= t.MaxTxPoolPending,
+		Value:     c.TxPool.Pending,
+		Threshold: t.MaxTxPoolPending,
+	}
+	if !result.OK {
+		result.Reason = "txpool_backlog"
+		result.Err = fmt.Errorf("%d pending transactions", c.TxPool.Pending)
+	}
+	r.Add(result)
+	r.TxPool = c.TxPool
+}
+
+var weiPerGwei = big.NewFloat(1e9)
+
+// weiToGwei converts a wei amount to gwei for display, losing precision only
+// beyond what a float64 holds
+func weiToGwei(wei *big.Int) float64 {
+	gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), weiPerGwei).Float64()
+	return gwei
+}
+
+func evaluateGasPrice(c *Client, t Thresholds, r *Report) {
+	probe := c.Probes["gas_price"]
+	switch {
+	case probe == nil:
+		return
+	case probe.Err != nil:
+		r.Add(errorResult("gas_price", probe.Err))
+		return
+	}
+
+	fees := &Fees{GasPrice: weiToGwei(c.GasPrice), BaseFee: weiToGwei(c.BaseFee)}
+	r.Fees = fees
+
+	result := Result{Name: "gas_price", OK: true, Value: fees.BaseFee}
+	switch {
+	case c.GasPrice.Sign() == 0:
+		result.OK = false
+		result.Value = fees.GasPrice
+		result.Reason = "gas_price_zero"
+		result.Err = errors.New("eth_gasPrice returned 0")
+	case t.MinGasPrice != nil && c.BaseFee.Cmp(t.MinGasPrice) < 0:
+		result.OK = false
+		result.Threshold = weiToGwei(t.MinGasPrice)
+		result.Reason = "base_fee_out_of_bounds"
+		result.Err = fmt.Errorf("base fee %g gwei is below the minimum", fees.BaseFee)
+	case t.MaxGasPrice != nil && c.BaseFee.Cmp(t.MaxGasPrice) > 0:
+		result.OK = false
+		result.Threshold = weiToGwei(t.MaxGasPrice)
+		result.Reason = "base_fee_out_of_bounds"
+		result.Err = fmt.Errorf("base fee %g gwei is above the maximum", fees.BaseFee)
+	}
+	r.Add(result)
+}
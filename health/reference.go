@@ -0,0 +1,63 @@
+package health
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ReferenceHeight is the head reported by one reference endpoint. Endpoint
+// only holds the host since reference URLs often embed API keys.
+type ReferenceHeight struct {
+	Endpoint    string  `json:"endpoint"`
+	BlockNumber uint64  `json:"block_number,omitempty"`
+	Latency     float64 `json:"latency_ms"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// medianHeight returns the median head of the references that answered,
+// taking the lower of the two middle values for an even count
+func medianHeight(heights []ReferenceHeight) (uint64, bool) {
+	var numbers []uint64
+	for _, height := range heights {
+		if height.Error == "" {
+			numbers = append(numbers, height.BlockNumber)
+		}
+	}
+	if len(numbers) == 0 {
+		return 0, false
+	}
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers[(len(numbers)-1)/2], true
+}
+
+func evaluateReference(c *Client, t Thresholds, r *Report) {
+	if c.References == nil {
+		return
+	}
+	r.References = c.References
+
+	// Only a successful comparison may fail the check, so an outage of the
+	// references never marks the node unhealthy
+	median, ok := medianHeight(c.References)
+	if !ok || c.BlockNumber == 0 {
+		r.Add(Result{Name: "reference", OK: true, Skipped: true, Reason: "references_unavailable"})
+		return
+	}
+
+	var behind uint64
+	if median > c.BlockNumber {
+		behind = median - c.BlockNumber
+	}
+	result := Result{
+		Name:      "reference",
+		OK:        behind <= t.MaxBlocksBehindReference,
+		Value:     behind,
+		Threshold: t.MaxBlocksBehindReference,
+	}
+	if !result.OK {
+		result.Reason = "behind_reference"
+		result.Err = fmt.Errorf("head %d is %d blocks behind the reference median %d", c.BlockNumber, behind, median)
+	}
+	r.Add(result)
+}
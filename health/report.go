@@ -0,0 +1,72 @@
+package health
+
+import (
+	"sort"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+// Report is the outcome of checking a node. Besides the checks it holds
+// summaries of what some checks looked at, such as the consensus client.
+type Report struct {
+	Healthy       bool                   `json:"healthy"`
+	ClientType    string                 `json:"client_type,omitempty"`
+	ClientVersion string                 `json:"client_version,omitempty"`
+	BlockNumber   uint64                 `json:"block_number,omitempty"`
+	ChainID       uint64                 `json:"chain_id,omitempty"`
+	Reasons       []string               `json:"reasons,omitempty"`
+	Warnings      []string               `json:"warnings,omitempty"`
+	Checks        map[string]Result      `json:"checks"`
+	Consensus     *ConsensusStatus       `json:"consensus,omitempty"`
+	Builder       *BuilderStatus         `json:"builder,omitempty"`
+	Rollup        *RollupStatus          `json:"rollup,omitempty"`
+	Nitro         *NitroStatus           `json:"nitro,omitempty"`
+	Finalized     *TaggedBlock           `json:"finalized,omitempty"`
+	Safe          *TaggedBlock           `json:"safe,omitempty"`
+	SyncStage     *clients.StageProgress `json:"sync_stage,omitempty"`
+	TxPool        *clients.TxPoolStatus  `json:"txpool,omitempty"`
+	Fees          *Fees                  `json:"fees,omitempty"`
+	References    []ReferenceHeight      `json:"references,omitempty"`
+	Peers         *clients.PeerBreakdown `json:"peers,omitempty"`
+}
+
+// TaggedBlock reports the number and age of a block tag in the report
+type TaggedBlock struct {
+	Number uint64  `json:"number"`
+	Age    float64 `json:"age_seconds"`
+}
+
+// Add records result under its name, and its details under theirs
+func (r *Report) Add(result Result) {
+	if r.Checks == nil {
+		r.Checks = map[string]Result{}
+	}
+	details := result.Details
+	result.Details = nil
+	r.Checks[result.Name] = result
+
+	for _, detail := range details {
+		r.Add(detail)
+	}
+}
+
+// Summarize derives the health, reasons and warnings from the checks, e.g.
+// after a check was changed
+func (r *Report) Summarize() {
+	names := make([]string, 0, len(r.Checks))
+	for name := range r.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r.Healthy, r.Reasons, r.Warnings = true, nil, nil
+	for _, name := range names {
+		switch check := r.Checks[name]; {
+		case !check.OK:
+			r.Healthy = false
+			r.Reasons = append(r.Reasons, check.Reason)
+		case check.Warning:
+			r.Warnings = append(r.Warnings, check.Reason)
+		}
+	}
+}
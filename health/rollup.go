@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+// Rollup is what the op-node of an OP Stack chain returned
+type Rollup struct {
+	Status *clients.OPSyncStatus
+	// Config is nil while the rollup config could not be retrieved, which
+	// skips the sequencer window check
+	Config *clients.RollupConfig
+	Err    error
+}
+
+// RollupStatus summarizes the op-node in the report
+type RollupStatus struct {
+	UnsafeL2    RollupBlock `json:"unsafe_l2"`
+	SafeL2      RollupBlock `json:"safe_l2"`
+	FinalizedL2 RollupBlock `json:"finalized_l2"`
+	HeadL1      uint64      `json:"head_l1"`
+	// L1OriginLag is the number of L1 blocks the L1 origin of the unsafe
+	// head trails the L1 head by
+	L1OriginLag uint64 `json:"l1_origin_lag"`
+	// SafeL1OriginLag is the same for the safe head, which the sequencer
+	// window bounds
+	SafeL1OriginLag uint64 `json:"safe_l1_origin_lag"`
+	SeqWindowSize   uint64 `json:"seq_window_size,omitempty"`
+}
+
+// RollupBlock is an L2 head of the op-node and its age
+type RollupBlock struct {
+	Number uint64  `json:"number"`
+	Age    float64 `json:"age_seconds"`
+}
+
+// blocksBehind returns how many blocks from trails to, or 0 when it does not
+func blocksBehind(from, to uint64) uint64 {
+	if to > from {
+		return to - from
+	}
+	return 0
+}
+
+// rollupCheck judges the op-node when it was measured
+type rollupCheck struct{}
+
+func (rollupCheck) Name() string {
+	return "rollup"
+}
+
+func (rollupCheck) Evaluate(ctx context.Context, c *Client, t Thresholds, r *Report) {
+	if c.Rollup == nil {
+		return
+	}
+	if c.Rollup.Err != nil {
+		r.Add(errorResult("rollup_sync", c.Rollup.Err))
+		return
+	}
+
+	status := c.Rollup.Status
+	summary := &RollupStatus{
+		UnsafeL2:        rollupBlock(status.UnsafeL2),
+		SafeL2:          rollupBlock(status.SafeL2),
+		FinalizedL2:     rollupBlock(status.FinalizedL2),
+		HeadL1:          status.HeadL1.Number,
+		L1OriginLag:     blocksBehind(status.UnsafeL2.L1Origin.Number, status.HeadL1.Number),
+		SafeL1OriginLag: blocksBehind(status.SafeL2.L1Origin.Number, status.HeadL1.Number),
+	}
+	r.Rollup = summary
+
+	evaluateRollupAge("unsafe", status.UnsafeL2, t.MaxRollupUnsafeAge, "rollup_unsafe_stalled", r)
+	evaluateRollupAge("safe", status.SafeL2, t.MaxRollupSafeAge, "rollup_safe_lag_exceeded", r)
+	evaluateRollupAge("finalized", status.FinalizedL2, t.MaxRollupFinalizedAge, "rollup_finalized_lag_exceeded", r)
+
+	if t.MaxL1OriginLag > 0 {
+		result := Result{Name: "rollup_l1_origin", OK: summary.L1OriginLag <= t.MaxL1OriginLag, Value: summary.L1OriginLag, Threshold: t.MaxL1OriginLag}
+		if !result.OK {
+			result.Reason = "rollup_l1_origin_behind"
+			result.Err = fmt.Errorf("L1 origin %d of the unsafe head is %d blocks behind the L1 head %d", status.UnsafeL2.L1Origin.Number, summary.L1OriginLag, status.HeadL1.Number)
+		}
+		r.Add(result)
+	}
+
+	// Once the safe head's L1 origin trails the L1 head by more than the
+	// sequencer window, the sequencer's blocks past it are replaced by
+	// deposit-only blocks
+	if config := c.Rollup.Config; config != nil && config.SeqWindowSize > 0 {
+		summary.SeqWindowSize = config.SeqWindowSize
+		result := Result{Name: "rollup_sequencer_window", OK: summary.SafeL1OriginLag <= config.SeqWindowSize, Value: summary.SafeL1OriginLag, Threshold: config.SeqWindowSize}
+		if !result.OK {
+			result.Reason = "rollup_sequencer_window_expired"
+			result.Err = fmt.Errorf("L1 origin %d of the safe head is %d blocks behind the L1 head, beyond the sequencer window of %d", status.SafeL2.L1Origin.Number, summary.SafeL1OriginLag, config.SeqWindowSize)
+		}
+		r.Add(result)
+	}
+}
+
+// rollupBlock dates an L2 head of the op-node
+func rollupBlock(block clients.L2BlockRef) RollupBlock {
+	return RollupBlock{Number: block.Number, Age: max(time.Since(block.Time()), 0).Seconds()}
+}
+
+// evaluateRollupAge adds the rollup_<head> check comparing the age of an L2
+// head against maxAge, which disables the check when 0
+func evaluateRollupAge(head string, block clients.L2BlockRef, maxAge time.Duration, reason string, r *Report) {
+	if maxAge <= 0 {
+		return
+	}
+
+	age := max(time.Since(block.Time()), 0)
+	result := Result{Name: "rollup_" + head, OK: age <= maxAge, Value: int(age.Seconds()), Threshold: int(maxAge.Seconds())}
+	if !result.OK {
+		result.Reason = reason
+		result.Err = fmt.Errorf("%s L2 head %d is %s old", head, block.Number, age.Round(time.Second))
+	}
+	r.Add(result)
+}
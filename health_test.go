@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/clients/clienttest"
+	"github.com/rarecrumb/medic/health"
+)
+
+func TestMeasureBlockDelta(t *testing.T) {
+	tests := []struct {
+		name    string
+		batches bool
+		age     time.Duration
+		ok      bool
+	}{
+		{name: "batch", batches: true, age: 5 * time.Second, ok: true},
+		{name: "batch stale", batches: true, age: time.Minute},
+		// Nodes that reject batches are measured through ethclient, which
+		// needs the complete header
+		{name: "individual", age: 5 * time.Second, ok: true},
+		{name: "individual stale", age: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			node.RejectBatches(!tt.batches)
+			node.Handle("eth_getBlockByNumber", clienttest.Block(1234, time.Now().Add(-tt.age)))
+
+			client := newNodeClient("", node.URL)
+			client.forceClientType("Geth")
+
+			ctx := context.Background()
+			m := measure(ctx, client)
+			if err := m.Errors["block_delta"]; err != nil {
+				t.Fatalf("block_delta error: %v", err)
+			}
+			if m.BlockNumber != 1234 {
+				t.Errorf("block number = %d, want 1234", m.BlockNumber)
+			}
+			// Only the header is needed, so transactions must not be requested
+			var params bytes.Buffer
+			if err := json.Compact(&params, node.Params("eth_getBlockByNumber")); err != nil || params.String() != `["latest",false]` {
+				t.Errorf("eth_getBlockByNumber params = %s, want [\"latest\",false]", node.Params("eth_getBlockByNumber"))
+			}
+
+			result := evaluate(ctx, m, health.Thresholds{MaxBlockAge: 30 * time.Second})
+			if check := result.Checks["block_delta"]; check.OK != tt.ok {
+				t.Errorf("block_delta = %+v, want ok %v", check, tt.ok)
+			}
+		})
+	}
+}
+
+// A node that stops answering fails the evaluation with rpc_timeout once
+// check-timeout passes instead of hanging the probe handler
+func TestNodeHealthCheckTimeout(t *testing.T) {
+	useSettings(t, map[string]interface{}{"check-timeout": "200ms"})
+
+	node := clienttest.NewServer()
+	defer node.Close()
+	node.SetLatency(5 * time.Second)
+
+	for _, batches := range []bool{true, false} {
+		node.RejectBatches(!batches)
+		client := newNodeClient("", node.URL)
+		client.forceClientType("Geth")
+
+		start := time.Now()
+		result := nodeHealth(context.Background(), client)
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("batches %v: evaluation took %s with a check-timeout of 200ms", batches, elapsed)
+		}
+		if result.Healthy {
+			t.Errorf("batches %v: node past the deadline reported healthy", batches)
+		}
+		if !slices.Contains(result.Reasons, "rpc_timeout") {
+			t.Errorf("batches %v: reasons = %v, want rpc_timeout", batches, result.Reasons)
+		}
+	}
+}
+
+// Against a node that takes 20ms per request, a batched measurement takes
+// about one round trip while the individual fallback pays one per call
+func BenchmarkMeasure(b *testing.B) {
+	for _, batches := range []bool{true, false} {
+		name := "batched"
+		if !batches {
+			name = "individual"
+		}
+		b.Run(name, func(b *testing.B) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			node.RejectBatches(!batches)
+			node.SetLatency(20 * time.Millisecond)
+
+			client := newNodeClient("", node.URL)
+			client.forceClientType("Geth")
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if m := measure(ctx, client); m.Errors["block_delta"] != nil {
+					b.Fatal(m.Errors["block_delta"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HealthFile is the content of state-output-file, documented by
+// testdata/health-file.schema.json
+type HealthFile struct {
+	// UpdatedAt is when the evaluation finished
+	UpdatedAt time.Time `json:"updated_at"`
+	// Ready is whether /ready passes, including the operator overrides
+	Ready bool `json:"ready"`
+	// Health is the result /ready reports
+	Health HealthResult `json:"health"`
+}
+
+// healthFiles mirrors the health of the node into state-output-file and
+// healthy-touch-file, for consumers without HTTP such as systemd units
+type healthFiles struct {
+	output string
+	touch  string
+
+	mu sync.Mutex
+}
+
+// nodeFiles is set when state-output-file or healthy-touch-file is
+var nodeFiles *healthFiles
+
+func newHealthFiles() *healthFiles {
+	output, touch := settings().GetString("state-output-file"), settings().GetString("healthy-touch-file")
+	if output == "" && touch == "" {
+		return nil
+	}
+	return &healthFiles{output: output, touch: touch}
+}
+
+// write records result in the files. Failures are logged and otherwise
+// ignored so health checking continues.
+func (f *healthFiles) write(result HealthResult) {
+	ready, _ := readiness(result)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.output != "" {
+		data, err := json.Marshal(HealthFile{UpdatedAt: time.Now().UTC(), Ready: ready, Health: result})
+		if err == nil {
+			err = writeFileAtomic(f.output, append(data, '\n'))
+		}
+		if err != nil {
+			log.Error().Err(err).Str("state_output_file", f.output).Msg("Failed to write the state output file")
+		}
+	}
+
+	if f.touch == "" {
+		return
+	}
+	if !ready {
+		f.removeTouch()
+		return
+	}
+	if _, err := os.Stat(f.touch); errors.Is(err, fs.ErrNotExist) {
+		if err := writeFileAtomic(f.touch, nil); err != nil {
+			log.Error().Err(err).Str("healthy_touch_file", f.touch).Msg("Failed to create the healthy touch file")
+		}
+	}
+}
+
+func (f *healthFiles) removeTouch() {
+	if err := os.Remove(f.touch); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error().Err(err).Str("healthy_touch_file", f.touch).Msg("Failed to remove the healthy touch file")
+	}
+}
+
+// remove deletes the files on shutdown, so consumers do not act on the
+// health of a node medic no longer watches
+func (f *healthFiles) remove() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.output != "" {
+		if err := os.Remove(f.output); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Error().Err(err).Str("state_output_file", f.output).Msg("Failed to remove the state output file")
+		}
+	}
+	if f.touch != "" {
+		f.removeTouch()
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/clients/clienttest"
+	"github.com/rarecrumb/medic/health"
+)
+
+// schemaPath documents the state output file
+const schemaPath = "testdata/health-file.schema.json"
+
+// loadSchema reads the documented schema of the state output file
+func loadSchema(t *testing.T) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	return schema
+}
+
+// The state output file is written, checked against the documented schema
+// and read back without losing anything
+func TestHealthFileSchema(t *testing.T) {
+	node := clienttest.NewServer()
+	defer node.Close()
+	node.Handle("net_peerCount", clienttest.PeerCount(1))
+
+	client := newNodeClient("", node.URL)
+	client.forceClientType("Geth")
+	ctx := context.Background()
+	result := HealthResult{
+		Report:        evaluate(ctx, measure(ctx, client), health.Thresholds{MaxBlockAge: 30 * time.Second, MinPeers: 3}).Report,
+		Syncing:       &SyncProgress{Current: 990, Highest: 1000, Rate: 2.5, ETA: "4s"},
+		CacheAge:      1.5,
+		FailureStreak: 2,
+	}
+	result.Finalized = &health.TaggedBlock{Number: 936, Age: 768}
+	result.Warnings = []string{"finalized_lag_exceeded"}
+	if len(result.Reasons) == 0 {
+		t.Fatalf("expected a failing peer check, checks %v", result.Checks)
+	}
+
+	path := filepath.Join(t.TempDir(), "health.json")
+	(&healthFiles{output: path}).write(result)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+	schema := loadSchema(t)
+	for _, err := range validateSchema(schema, schema, document, "") {
+		t.Error(err)
+	}
+
+	var file HealthFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Ready || file.UpdatedAt.IsZero() {
+		t.Errorf("ready = %v, updated at %v, want not ready with a time", file.Ready, file.UpdatedAt)
+	}
+	encoded, _ := json.Marshal(result)
+	decoded, _ := json.Marshal(file.Health)
+	if !bytes.Equal(encoded, decoded) {
+		t.Errorf("round trip changed the result\n got %s\nwant %s", decoded, encoded)
+	}
+}
+
+// Every field of the state output file is documented in the schema
+func TestHealthFileSchemaComplete(t *testing.T) {
+	schema := loadSchema(t)
+	documented := func(schema map[string]interface{}) map[string]bool {
+		names := map[string]bool{}
+		for name := range schema["properties"].(map[string]interface{}) {
+			names[name] = true
+		}
+		return names
+	}
+	properties := schema["properties"].(map[string]interface{})
+
+	for _, tt := range []struct {
+		typ    reflect.Type
+		schema map[string]interface{}
+	}{
+		{reflect.TypeOf(HealthFile{}), schema},
+		{reflect.TypeOf(HealthResult{}), properties["health"].(map[string]interface{})},
+	} {
+		names := documented(tt.schema)
+		for _, field := range jsonFields(tt.typ) {
+			if !names[field] {
+				t.Errorf("%s field %s is missing from %s", tt.typ.Name(), field, schemaPath)
+			}
+		}
+	}
+}
+
+// jsonFields returns the JSON names of the fields of typ, including those of
+// embedded structs
+func jsonFields(typ reflect.Type) []string {
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			names = append(names, jsonFields(field.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// validateSchema checks value against the subset of JSON schema the state
+// output schema uses: $ref, type, format, required, properties,
+// additionalProperties and items
+func validateSchema(root, schema map[string]interface{}, value interface{}, path string) []error {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved := root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			resolved = resolved[part].(map[string]interface{})
+		}
+		return validateSchema(root, resolved, value, path)
+	}
+
+	if typ, ok := schema["type"].(string); ok && !hasType(value, typ) {
+		return []error{fmt.Errorf("%s: %v is not of type %s", path, value, typ)}
+	}
+	if schema["format"] == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, value.(string)); err != nil {
+			return []error{fmt.Errorf("%s: %w", path, err)}
+		}
+	}
+
+	var errs []error
+	switch value := value.(type) {
+	case map[string]interface{}:
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := value[name.(string)]; !ok {
+				errs = append(errs, fmt.Errorf("%s: missing required %s", path, name))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				errs = append(errs, validateSchema(root, property, value[name], path+"/"+name)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, fmt.Errorf("%s: undocumented property %s", path, name))
+				}
+			case map[string]interface{}:
+				errs = append(errs, validateSchema(root, additional, value[name], path+"/"+name)...)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				errs = append(errs, validateSchema(root, items, item, fmt.Sprintf("%s/%d", path, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+// hasType reports whether a value decoded with UseNumber has the JSON schema
+// type typ
+func hasType(value interface{}, typ string) bool {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return typ == "object"
+	case []interface{}:
+		return typ == "array"
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case json.Number:
+		if typ == "number" {
+			return true
+		}
+		return typ == "integer" && !strings.ContainsAny(value.String(), ".eE")
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+)
+
+// heartbeatRetries is how often a failed ping is retried before it counts as
+// failed, backing off between one and ten seconds
+const heartbeatRetries = 3
+
+// startHeartbeat pings every heartbeat-url each heartbeat-interval while
+// health reports a healthy node and pings <url>/fail with the reasons once
+// when it becomes unhealthy. A monitor such as healthchecks.io alerts when
+// the pings stop. Pings run on their own goroutine so a slow monitor never
+// delays the health checks.
+func startHeartbeat(health func(ctx context.Context) (bool, []string)) {
+	urls := settings().GetStringSlice("heartbeat-url")
+	if len(urls) == 0 {
+		return
+	}
+
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	client.RetryMax = heartbeatRetries
+	client.RetryWaitMin = time.Second
+	client.RetryWaitMax = 10 * time.Second
+	client.HTTPClient.Timeout = 10 * time.Second
+
+	interval := settings().GetDuration("heartbeat-interval")
+	log.Info().Int("urls", len(urls)).Dur("heartbeat_interval", interval).Msg("Starting heartbeat pings")
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// Wait one interval first so the poller has a result to report
+		failed := false
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			healthy, reasons := health(ctx)
+			cancel()
+
+			switch {
+			case healthy:
+				failed = false
+				pingAll(client, urls, "", nil)
+			case !failed:
+				failed = true
+				pingAll(client, urls, "/fail", []byte(strings.Join(reasons, "\n")))
+			}
+		}
+	}()
+}
+
+// pingAll pings every url concurrently, with body POSTed when set
+func pingAll(client *retryablehttp.Client, urls []string, suffix string, body []byte) {
+	for _, url := range urls {
+		go func(url string) {
+			if err := ping(client, strings.TrimSuffix(url, "/")+suffix, body); err != nil {
+				endpoint := referenceEndpoint(url)
+				log.Warn().Err(clients.WithoutURL(err)).Str("endpoint", endpoint).Msg("Failed to ping the heartbeat url")
+				heartbeatFailuresCounter.WithLabelValues(endpoint).Inc()
+			}
+		}(url)
+	}
+}
+
+// ping sends a GET, or a POST when body is set, to url
+func ping(client *retryablehttp.Client, url string, body []byte) error {
+	method := http.MethodGet
+	if body != nil {
+		method = http.MethodPost
+	}
+	req, err := retryablehttp.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// nodeHeartbeat reports the health of the node configured by eth-url
+func nodeHeartbeat(ctx context.Context) (bool, []string) {
+	result := nodeResult(ctx, ethNode)
+	return result.Healthy, result.Reasons
+}
+
+// targetsHeartbeat reports whether the target quorum is met, with the reasons
+// of every unhealthy target
+func targetsHeartbeat(ctx context.Context) (bool, []string) {
+	aggregate := targetsHealth(ctx)
+
+	var reasons []string
+	for name, result := range aggregate.Targets {
+		if !result.Healthy {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", name, strings.Join(result.Reasons, ", ")))
+		}
+	}
+	sort.Strings(reasons)
+	return aggregate.Healthy, reasons
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// hookQueueSize is the number of transitions that may wait for a running
+// hook before further ones are dropped
+const hookQueueSize = 16
+
+// maxHookOutput bounds the output of a hook that is logged
+const maxHookOutput = 4096
+
+// hookRun is a transition waiting for its hook
+type hookRun struct {
+	target string
+	result HealthResult
+}
+
+// hookRunner runs on-healthy-cmd and on-unhealthy-cmd on transitions, one at
+// a time and without ever delaying the health checks
+type hookRunner struct {
+	healthy   string
+	unhealthy string
+	shell     bool
+	queue     chan hookRun
+}
+
+// transitionHooks is set when a hook command is configured
+var transitionHooks *hookRunner
+
+// startHooks starts running the hook commands when one is set, returning nil
+// otherwise
+func startHooks() *hookRunner {
+	h := &hookRunner{
+		healthy:   settings().GetString("on-healthy-cmd"),
+		unhealthy: settings().GetString("on-unhealthy-cmd"),
+		shell:     settings().GetBool("hook-shell"),
+		queue:     make(chan hookRun, hookQueueSize),
+	}
+	if h.healthy == "" && h.unhealthy == "" {
+		return nil
+	}
+	go func() {
+		for run := range h.queue {
+			h.run(run)
+		}
+	}()
+	return h
+}
+
+// notify queues the hook of the transition of node to result
+func (h *hookRunner) notify(node *nodeClient, result HealthResult) {
+	select {
+	case h.queue <- hookRun{target: node.name, result: result}:
+	default:
+		node.logger().Warn().Bool("healthy", result.Healthy).Msg("Dropping a transition hook, too many are waiting")
+	}
+}
+
+// run executes the hook of one transition with the result as JSON on stdin
+func (h *hookRunner) run(run hookRun) {
+	command := h.unhealthy
+	if run.result.Healthy {
+		command = h.healthy
+	}
+	if command == "" {
+		return
+	}
+
+	var args []string
+	if h.shell {
+		args = []string{"/bin/sh", "-c", command}
+	} else {
+		args = strings.Fields(command)
+	}
+	// validateConfig rejects blank commands, which have nothing to run
+	if len(args) == 0 {
+		return
+	}
+
+	stdin, err := json.Marshal(run.result)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode the health result for the hook")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings().GetDuration("hook-timeout"))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Env = append(os.Environ(), hookEnv(run.target, run.result)...)
+	// Hooks that leave children holding the output open would otherwise
+	// block past the timeout
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	if len(output) > maxHookOutput {
+		output = output[:maxHookOutput]
+	}
+
+	logger := targetLogger(run.target)
+	event := logger.Info()
+	exitCode := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+		event = logger.Error()
+	case err != nil:
+		exitCode = -1
+		event = logger.Error().Err(err)
+	}
+	if ctx.Err() != nil {
+		event = event.Bool("timed_out", true)
+	}
+	event.
+		Bool("healthy", run.result.Healthy).
+		Strs("command", args).
+		Int("exit_code", exitCode).
+		Dur("duration", time.Since(start)).
+		Str("output", string(output)).
+		Msg("Ran the transition hook")
+}
+
+// hookEnv describes the transition to the hook in environment variables
+func hookEnv(target string, result HealthResult) []string {
+	return []string{
+		"MEDIC_TARGET=" + target,
+		"MEDIC_HEALTHY=" + strconv.FormatBool(result.Healthy),
+		"MEDIC_REASONS=" + strings.Join(result.Reasons, ","),
+		"MEDIC_BLOCK_DELTA=" + strconv.Itoa(result.intValue("block_delta")),
+		"MEDIC_BLOCK_NUMBER=" + strconv.FormatUint(result.BlockNumber, 10),
+		"MEDIC_CLIENT_TYPE=" + result.ClientType,
+	}
+}
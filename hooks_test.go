@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestBlankHookCommand(t *testing.T) {
+	for _, key := range []string{"on-healthy-cmd", "on-unhealthy-cmd"} {
+		v := newSettings()
+		v.Set(key, " \t ")
+		if err := validateConfig(v); err == nil {
+			t.Errorf("validateConfig() accepted a blank %s", key)
+		}
+	}
+
+	// A blank command that got past validation runs nothing instead of
+	// crashing the hook goroutine
+	useSettings(t, map[string]interface{}{})
+	h := &hookRunner{healthy: " ", unhealthy: "\t"}
+	var result HealthResult
+	h.run(hookRun{result: result})
+	result.Healthy = true
+	h.run(hookRun{result: result})
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into every pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// podUpdater reflects the health of the node on the pod medic runs in, as a
+// label or a pod condition. It talks to the API server with plain HTTP
+// requests, so medic does not depend on client-go.
+type podUpdater struct {
+	mode      string
+	label     string
+	condition string
+	pod       string
+	namespace string
+	api       string
+	token     string
+	client    *http.Client
+
+	mu      sync.Mutex
+	desired *bool
+	wake    chan struct{}
+}
+
+// podUpdates is set when k8s-update is
+var podUpdates *podUpdater
+
+// startPodUpdater starts reflecting the health on the pod when k8s-update is
+// set, returning nil otherwise
+func startPodUpdater() (*podUpdater, error) {
+	mode := settings().GetString("k8s-update")
+	if mode == "" {
+		return nil, nil
+	}
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("k8s-update requires running in a Kubernetes pod")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates in the service account CA")
+	}
+
+	// The downward API usually exposes the pod as POD_NAME and POD_NAMESPACE
+	namespace := settings().GetString("k8s-pod-namespace")
+	if namespace == "" {
+		namespace = os.Getenv("POD_NAMESPACE")
+	}
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, err
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	pod := settings().GetString("k8s-pod-name")
+	if pod == "" {
+		pod = os.Getenv("POD_NAME")
+	}
+	if pod == "" {
+		// The hostname of a pod is its name unless the spec overrides it
+		if pod, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+
+	u := &podUpdater{
+		mode:      mode,
+		label:     settings().GetString("k8s-label"),
+		condition: settings().GetString("k8s-condition-type"),
+		pod:       pod,
+		namespace: namespace,
+		api:       "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+		},
+		wake: make(chan struct{}, 1),
+	}
+	log.Info().Str("mode", mode).Str("pod", pod).Str("namespace", namespace).Msg("Reflecting the node health on the pod")
+
+	// Start from not ready until the first transition
+	u.set(false)
+	go u.run()
+	return u, nil
+}
+
+// set requests the pod to reflect ready. Only the latest request is applied,
+// so flapping does not flood the API server.
+func (u *podUpdater) set(ready bool) {
+	u.mu.Lock()
+	u.desired = &ready
+	u.mu.Unlock()
+
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run applies the requested state, at most once per k8s-min-interval
+func (u *podUpdater) run() {
+	var applied *bool
+	for range u.wake {
+		u.mu.Lock()
+		desired := *u.desired
+		u.mu.Unlock()
+		if applied != nil && *applied == desired {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), u.client.Timeout)
+		err := u.patch(ctx, &desired)
+		cancel()
+		if err != nil {
+			// Missing RBAC permissions must not stop health checking
+			log.Error().Err(err).Bool("ready", desired).Msg("Failed to update the pod")
+		} else {
+			applied = &desired
+			log.Info().Bool("ready", desired).Str("mode", u.mode).Msg("Updated the pod")
+		}
+
+		time.Sleep(settings().GetDuration("k8s-min-interval"))
+	}
+}
+
+// patch sets the label or condition to ready, or removes the label and marks
+// the condition unknown when ready is nil
+func (u *podUpdater) patch(ctx context.Context, ready *bool) error {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", u.namespace, u.pod)
+	var body interface{}
+	contentType := "application/merge-patch+json"
+
+	switch u.mode {
+	case "pod-label":
+		var value interface{}
+		if ready != nil {
+			value = fmt.Sprint(*ready)
+		}
+		body = map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{u.label: value}}}
+	case "pod-condition":
+		status, reason := "Unknown", "MedicStopped"
+		if ready != nil && *ready {
+			status, reason = "True", "NodeHealthy"
+		} else if ready != nil {
+			status, reason = "False", "NodeUnhealthy"
+		}
+		// A strategic merge patch merges conditions by type, leaving the
+		// ones kubelet manages untouched
+		path += "/status"
+		contentType = "application/strategic-merge-patch+json"
+		body = map[string]interface{}{"status": map[string]interface{}{"conditions": []map[string]interface{}{{
+			"type":               u.condition,
+			"status":             status,
+			"reason":             reason,
+			"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+		}}}}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, u.api+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+u.token)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("api server answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// cleanup removes the label or marks the condition unknown on shutdown, so
+// the pod does not advertise the health of a node medic no longer watches
+func (u *podUpdater) cleanup(ctx context.Context) {
+	if err := u.patch(ctx, nil); err != nil {
+		log.Error().Err(err).Msg("Failed to clean up the pod update")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyTracker keeps the latency of the last rpc-latency-window calls of
+// each RPC method to a node
+type latencyTracker struct {
+	mu      sync.Mutex
+	windows map[string]*latencyWindow
+}
+
+// latencyWindow is a ring buffer of call latencies
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+// LatencySummary is the latency percentile of an RPC method reported by
+// /status
+type LatencySummary struct {
+	Percentile float64 `json:"percentile"`
+	Seconds    float64 `json:"seconds"`
+	Samples    int     `json:"samples"`
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{windows: map[string]*latencyWindow{}}
+}
+
+// observe records a call to method that took d
+func (t *latencyTracker) observe(method string, d time.Duration) {
+	size := settings().GetInt("rpc-latency-window")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// A reload that resizes the window starts it over
+	w := t.windows[method]
+	if w == nil || cap(w.samples) != size {
+		w = &latencyWindow{samples: make([]time.Duration, 0, size)}
+		t.windows[method] = w
+	}
+	if len(w.samples) < cap(w.samples) {
+		w.samples = append(w.samples, d)
+	} else {
+		w.samples[w.next] = d
+	}
+	w.next = (w.next + 1) % cap(w.samples)
+}
+
+// percentile returns the p-th percentile of the recorded latencies of method
+// using the nearest rank, along with the number of samples
+func (t *latencyTracker) percentile(method string, p float64) (time.Duration, int) {
+	t.mu.Lock()
+	w := t.windows[method]
+	var samples []time.Duration
+	if w != nil {
+		samples = slices.Clone(w.samples)
+	}
+	t.mu.Unlock()
+
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	slices.Sort(samples)
+	rank := int(math.Ceil(p / 100 * float64(len(samples))))
+	return samples[max(rank, 1)-1], len(samples)
+}
+
+// summaries returns the configured percentile of every tracked method
+func (t *latencyTracker) summaries() map[string]LatencySummary {
+	p := settings().GetFloat64("rpc-latency-percentile")
+
+	t.mu.Lock()
+	methods := make([]string, 0, len(t.windows))
+	for method := range t.windows {
+		methods = append(methods, method)
+	}
+	t.mu.Unlock()
+
+	summaries := make(map[string]LatencySummary, len(methods))
+	for _, method := range methods {
+		latency, samples := t.percentile(method, p)
+		summaries[method] = LatencySummary{Percentile: p, Seconds: latency.Seconds(), Samples: samples}
+	}
+	return summaries
+}
+
+type latencyTrackerKey struct{}
+
+// withLatencyTracker makes the RPC calls made with ctx record their latency
+// in t
+func withLatencyTracker(ctx context.Context, t *latencyTracker) context.Context {
+	return context.WithValue(ctx, latencyTrackerKey{}, t)
+}
+
+// trackLatency records the latency of a call to method in the tracker of ctx,
+// if any
+func trackLatency(ctx context.Context, method string, d time.Duration) {
+	if t, ok := ctx.Value(latencyTrackerKey{}).(*latencyTracker); ok {
+		t.observe(method, d)
+	}
+}
@@ -2,198 +2,562 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rarecrumb/medic/clients"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// draining is set once a termination signal is received so that readiness
+// fails while the load balancer deregisters the node
+var draining atomic.Bool
+
 func init() {
 	// Set default values
+	pflag.String("config", "", "Path to a YAML, JSON or TOML config file, overridden by flags and environment variables")
+	pflag.Bool("watch-config", false, "Reload the config file when it changes, in addition to on SIGHUP")
+	pflag.String("admin-token", "", "Bearer token required by the admin endpoints; /config, /admin/maintenance, /admin/force-ready, /admin/min-block and /admin/state are only served when set")
+	pflag.Bool("admin-endpoints", true, "Serve the /admin endpoints that change the reported health")
 	pflag.String("log-level", "info", "Log level")
-	pflag.String("eth-url", "http://localhost:8545", "URL of the Ethereum client")
-	pflag.Int("max-seconds-behind", 30, "Maximum number of seconds behind a block can be")
-	pflag.Int("min-peers", 3, "Minimum number of peers the node should have")
-	pflag.Parse()
+	pflag.String("log-format", "json", "Log format: json or console")
+	pflag.String("eth-url", "http://localhost:8545", "URL of the Ethereum client (http, https, ws, wss, ipc:// or a socket path)")
+	pflag.String("cl-url", "", "URL of the consensus client beacon API (optional)")
+	pflag.String("graphql-url", "", "GraphQL endpoint of Geth or Besu whose head is checked against the JSON-RPC head, e.g. http://localhost:8545/graphql (optional)")
+	pflag.Uint64("graphql-max-block-distance", 5, "Maximum number of blocks the GraphQL head may differ from the JSON-RPC head")
+	pflag.String("ws-url", "", "WebSocket endpoint of the node checked with eth_chainId when eth-url is HTTP, e.g. ws://localhost:8546 (optional)")
+	pflag.Duration("ws-head-timeout", 0, "Fail the ws-url check when its newHeads subscription delivers no header within this time (0 skips the subscription)")
+	pflag.String("rollup-url", "", "URL of the op-node RPC of an OP Stack node, whose optimism_syncStatus is checked (optional)")
+	pflag.Duration("rollup-max-unsafe-age", 30*time.Second, "Maximum age of the op-node unsafe L2 head (0 disables the check)")
+	pflag.Duration("rollup-max-safe-age", 0, "Maximum age of the op-node safe L2 head (0 disables the check)")
+	pflag.Duration("rollup-max-finalized-age", 0, "Maximum age of the op-node finalized L2 head (0 disables the check)")
+	pflag.Uint64("rollup-max-l1-origin-lag", 60, "Maximum number of L1 blocks the L1 origin of the unsafe L2 head may trail the L1 head (0 disables the check)")
+	pflag.String("mev-boost-url", "", "URL of mev-boost whose /eth/v1/builder/status is checked every poll, e.g. http://localhost:18550 (optional)")
+	pflag.Bool("mev-boost-required", false, "Fail readiness when mev-boost is unhealthy instead of only warning")
+	pflag.String("client-type", "", "Force the client type instead of detecting it with web3_clientVersion (e.g. Geth, Nethermind, Besu)")
+	pflag.String("nethermind-health-url", "", "Base URL of the Nethermind health checks endpoint (defaults to eth-url)")
+	pflag.String("besu-health-url", "", "Base URL of the Besu readiness and liveness endpoints (defaults to eth-url)")
+	pflag.Int("besu-max-blocks-behind", 2, "Maximum number of blocks behind passed to the Besu readiness endpoint")
+	pflag.Bool("besu-health-only", false, "Rely only on the Besu readiness endpoint for Besu nodes, skipping the generic block, peer and sync checks")
+	pflag.Uint64("max-stage-distance", 32, "Maximum number of blocks an Erigon or Reth sync stage may trail the highest block")
+	pflag.String("reth-metrics-url", "", "URL of the Reth Prometheus metrics endpoint used to read stage checkpoints (optional)")
+	pflag.String("nitro-health-url", "", "URL of a health endpoint in front of an Arbitrum Nitro node, expected to return 200 (optional)")
+	pflag.Uint64("max-nitro-msg-lag", 100, "Maximum number of messages an Arbitrum Nitro node may trail the sequencer feed")
+	pflag.Uint64("expected-chain-id", 0, "Fail readiness if the node reports a different chain ID (0 disables the check)")
+	pflag.Duration("max-block-age", 30*time.Second, "Maximum age of the latest block (defaults to a value for the node's chain, or three L2 block times with rollup-url)")
+	pflag.Duration("max-head-stall", 0, "Maximum time the head block number may stay unchanged (0 disables the check)")
+	pflag.Int("max-seconds-behind", 30, "Deprecated: use max-block-age")
+	pflag.Int("max-seconds-without-new-block", 0, "Deprecated: use max-head-stall")
+	pflag.Duration("max-finalized-lag", 0, "Maximum age of the finalized block (0 disables the check)")
+	pflag.Duration("max-safe-lag", 0, "Maximum age of the safe block (0 disables the check)")
+	pflag.Uint64("cl-min-peers", 0, "Minimum number of peers the cl-url beacon node should have (0 disables the check)")
+	pflag.Duration("cl-optimistic-grace-period", 5*time.Minute, "Time after startup during which an optimistically synced cl-url beacon node only warns instead of failing readiness")
+	pflag.Duration("max-finality-age", 0, "Maximum age of the finalized checkpoint of the cl-url beacon node (0 disables the check)")
+	pflag.Uint64("max-reorg-depth", 64, "Maximum number of blocks the head may roll back below the highest head seen")
+	pflag.String("state-file", "", "File to persist the highest block seen per chain in, to detect rollbacks across restarts (optional)")
+	pflag.Duration("max-rpc-latency", 0, "Maximum rpc-latency-percentile of the eth_getBlockByNumber latency over the last rpc-latency-window calls (0 disables the check)")
+	pflag.Int("rpc-latency-window", 20, "Number of recent calls of each RPC method the latency percentiles are computed over")
+	pflag.Float64("rpc-latency-percentile", 50, "Latency percentile max-rpc-latency bounds and /status reports, e.g. 50 or 99")
+	pflag.Uint64("min-block-number", 0, "Fail readiness while the head is below this block, e.g. after restoring an old snapshot (0 disables the gate)")
+	pflag.String("min-block-file", "", "File holding the minimum block number, overriding min-block-number once it exists (optional)")
+	pflag.String("state-output-file", "", "File atomically rewritten with the health result after every evaluation, e.g. /run/medic/health.json (optional)")
+	pflag.String("healthy-touch-file", "", "File that exists only while the node is ready, for scripts that test -f (optional)")
+	pflag.Int("event-log-size", 500, "Number of health transitions kept in memory for /events")
+	pflag.String("event-log-file", "", "File to append health transitions to as JSON Lines, loaded into /events at startup (optional)")
+	pflag.Uint64("max-restart-rollback", 128, "Maximum number of blocks the head may be below the highest block recorded in the state file")
+	pflag.Int("min-peers", 3, "Minimum number of peers the node should have (0 skips the peer check)")
+	pflag.Bool("peer-check-required", false, "Fail readiness when the node does not serve net_peerCount instead of skipping the peer check")
+	pflag.String("listen-addr", ":8080", "Address for the health server to listen on (host:port or :port)")
+	pflag.Duration("poll-interval", 5*time.Second, "Interval between background health checks (0 checks on every probe)")
+	pflag.Duration("client-detect-interval", 5*time.Minute, "Interval between client type re-detections")
+	pflag.Int("failure-threshold", 1, "Consecutive failed evaluations before the node is reported unhealthy")
+	pflag.Int("success-threshold", 1, "Consecutive passed evaluations before the node is reported healthy again")
+	pflag.Duration("shutdown-delay", 10*time.Second, "Time to fail readiness before shutting down the server on SIGTERM")
+	pflag.Duration("shutdown-timeout", 5*time.Second, "Maximum time to wait for in-flight requests during shutdown")
+	pflag.Bool("rpc-batch", true, "Send the execution client checks as a single JSON-RPC batch")
+	pflag.Duration("probe-cache-ttl", time.Second, "How long an on-demand health result is reused for further probes without a poller; 0 disables the reuse")
+	pflag.Duration("check-timeout", 5*time.Second, "Maximum time a single health evaluation may take")
+	pflag.Bool("one-shot", false, "Run the health checks once, print the result and exit non-zero if unhealthy")
+	pflag.Duration("timeout", 30*time.Second, "Maximum time a one-shot health check may take")
+	pflag.String("pushgateway-url", "", "Prometheus Pushgateway that one-shot runs push their metrics to before exiting")
+	pflag.String("push-job", "medic", "Job label of pushed metrics")
+	pflag.String("push-instance", "", "Instance label of pushed metrics (defaults to the hostname)")
+	pflag.StringSlice("push-grouping", nil, "Extra grouping label of pushed metrics, as label=value (repeatable)")
+	pflag.Bool("push-required", false, "Exit with code 2 when a healthy one-shot run fails to push its metrics")
+	pflag.StringArray("rpc-header", nil, "Header to send with every request to the node, as Key=Value (repeatable)")
+	pflag.String("rpc-basic-auth", "", "Basic auth credentials to send with every request to the node, as user:password, for URLs that cannot carry them")
+	pflag.String("rpc-bearer-token", "", "Bearer token to send with every request to the node")
+	pflag.Int("retry-max", 50, "Maximum number of retries while waiting for the node at startup")
+	pflag.Duration("retry-wait-min", 5*time.Second, "Minimum time to wait between HTTP retries")
+	pflag.Duration("retry-wait-max", 15*time.Second, "Maximum time to wait between HTTP retries")
+	pflag.Bool("wait-for-node", true, "Wait for the node to answer JSON-RPC before starting the health server")
+	pflag.Duration("startup-timeout", 10*time.Minute, "Maximum time to wait for the node at startup")
+	pflag.StringArray("maintenance", nil, "Recurring maintenance window as cron=0 2 * * SUN;duration=4h[;behavior=not-ready|suppress-alerts][;name=...][;timezone=...] (repeatable)")
+	pflag.Duration("initial-grace-period", 0, "Time after startup during which too few peers only warn instead of failing readiness (0 disables the grace period)")
+	pflag.String("initial-grace-from", "start", "When the initial grace period starts: start, when medic starts, or reachable, when the node first answers")
+	pflag.Duration("startup-stall-timeout", 10*time.Minute, "Time without sync progress after which /startup fails until the node has been ready once")
+	pflag.Bool("fail-on-startup", false, "Exit non-zero if the node is not reachable before the startup timeout")
+	pflag.Bool("subscribe", true, "Follow newHeads on WebSocket and IPC endpoints instead of polling the latest block")
+	pflag.Duration("subscription-timeout", 60*time.Second, "Resubscribe to newHeads when no header arrives within this time")
+	pflag.String("profile", "", "Preset of checks and defaults for a kind of node: "+strings.Join(profileNames(), ", ")+" (explicit settings override it)")
+	pflag.Bool("el-p2p-restricted", false, "The execution client only peers with a fixed set of nodes, so the validator profile skips its peer check")
+	pflag.String("checks", "", "Comma-separated list of checks to run (default all): "+strings.Join(checkNames(checkRegistry), ", "))
+	pflag.Bool("check-getlogs", false, "Fail readiness when eth_getLogs over the most recent blocks fails or is slow")
+	pflag.Uint64("getlogs-range", 10, "Number of recent blocks queried by the eth_getLogs check")
+	pflag.Duration("getlogs-max-latency", 2*time.Second, "Maximum latency of the eth_getLogs check")
+	pflag.Bool("check-state", false, "Fail readiness when the node cannot serve state at the latest block")
+	pflag.String("state-address", common.Address{}.Hex(), "Address whose balance is read by the state check")
+	pflag.String("state-call-to", "", "Contract called with eth_call by the state check instead of reading a balance")
+	pflag.String("state-call-data", "0x", "Hex-encoded call data sent to state-call-to")
+	pflag.Uint64("check-archive-block", 0, "Fail readiness when the node cannot serve state at this historical block (0 disables, 1 when given without a value)")
+	pflag.Lookup("check-archive-block").NoOptDefVal = "1"
+	pflag.Bool("check-trace", false, "Fail readiness when the node cannot trace a recent block")
+	pflag.Duration("trace-timeout", 5*time.Second, "Maximum time the trace check may take, capped by check-timeout")
+	pflag.Uint64("max-txpool-pending", 0, "Maximum number of pending transactions in the txpool (0 disables the check)")
+	pflag.Bool("check-gas-price", false, "Fail readiness when eth_gasPrice returns 0, eth_feeHistory fails or the base fee is out of bounds")
+	pflag.Float64("min-gas-price", 0, "Minimum base fee in gwei accepted by the gas price check (0 disables)")
+	pflag.Float64("max-gas-price", 0, "Maximum base fee in gwei accepted by the gas price check (0 disables)")
+	pflag.StringSlice("reference-url", nil, "Independent HTTP RPC endpoint whose head the node is compared against (repeatable)")
+	pflag.Uint64("max-blocks-behind-reference", 5, "Maximum number of blocks the head may trail the median reference head")
+	pflag.String("expected-genesis-hash", "", "Fail readiness when the genesis block hash differs from this value")
+	pflag.StringSlice("verify-block", nil, "Block that must have the given hash, as HEIGHT:HASH (repeatable)")
+	pflag.Bool("admin-peers", true, "Count only peers on the same network using admin_peers when the node serves it")
+	pflag.Duration("admin-peers-timeout", 2*time.Second, "Maximum time the admin_peers call may take")
+	pflag.Duration("clock-skew-tolerance", 0, "Clock skew between the node and medic subtracted from the block delta")
+	pflag.StringArray("target", nil, "Named node to watch instead of eth-url, as name=url (repeatable), served at /ready/{name}")
+	pflag.Int("quorum", 1, "Number of targets that must be healthy for the aggregate /ready to pass")
+	pflag.String("external-url", "", "Base URL medic is reachable at, used to link to /status from notifications")
+	pflag.String("slack-webhook-url", "", "Slack incoming webhook to post health transitions to")
+	pflag.String("slack-channel", "", "Slack channel to post to instead of the webhook's default")
+	pflag.String("slack-mention", "", "Mention added when an outage lasts slack-escalate-after, e.g. @here or <!subteam^ID>")
+	pflag.Duration("slack-escalate-after", 5*time.Minute, "Time a node must stay unhealthy before slack-mention is notified (0 disables escalation)")
+	pflag.Duration("slack-min-interval", time.Minute, "Minimum time between Slack messages about the same node, so a flapping node is not posted every time")
+	pflag.String("on-healthy-cmd", "", "Command run when a node transitions to healthy, with the health result as JSON on stdin (optional)")
+	pflag.String("on-unhealthy-cmd", "", "Command run when a node transitions to unhealthy, with the health result as JSON on stdin (optional)")
+	pflag.Bool("hook-shell", false, "Run the hook commands with /bin/sh -c instead of splitting them on whitespace")
+	pflag.Duration("hook-timeout", 30*time.Second, "Maximum time a hook command may run before it is killed")
+	pflag.StringSlice("heartbeat-url", nil, "URL pinged every heartbeat-interval while healthy and at <url>/fail when unhealthy (repeatable)")
+	pflag.Duration("heartbeat-interval", time.Minute, "Interval between heartbeat pings")
+	pflag.String("otel-endpoint", "", "OTLP/HTTP endpoint to export traces of the health checks to, e.g. http://localhost:4318")
+	pflag.Float64("otel-sample-ratio", 0.1, "Fraction of health evaluations that are traced")
+	pflag.Bool("enable-pprof", false, "Serve pprof and expvar under /debug/ on debug-addr")
+	pflag.String("debug-addr", "localhost:6060", "Address of the debug server, separate from listen-addr")
+	pflag.String("grpc-addr", "", "Address of a gRPC server implementing grpc.health.v1 (disabled when empty)")
+	pflag.String("proxy-listen", "", "Address of a reverse proxy that forwards JSON-RPC requests to eth-url only while the node is healthy (disabled when empty)")
+	pflag.String("fallback-url", "", "HTTP RPC endpoint the proxy routes to while eth-url is unhealthy, e.g. a hosted provider (optional)")
+	pflag.Int("failback-after", 3, "Consecutive healthy evaluations of eth-url before the proxy routes back to it from the fallback")
+	pflag.Bool("proxy-failover-dry-run", false, "Only log when the proxy would switch to or from the fallback")
+	pflag.String("k8s-update", "", "Reflect the health of eth-url on the pod medic runs in: pod-label or pod-condition (disabled when empty)")
+	pflag.String("k8s-label", "medic.rarecrumb.io/ready", "Label set to true or false in pod-label mode")
+	pflag.String("k8s-condition-type", "medic.rarecrumb.io/Ready", "Type of the pod condition set in pod-condition mode, usable as a readiness gate")
+	pflag.String("k8s-pod-name", "", "Name of the pod to update (defaults to POD_NAME, then the hostname)")
+	pflag.String("k8s-pod-namespace", "", "Namespace of the pod to update (defaults to POD_NAMESPACE, then the service account namespace)")
+	pflag.Duration("k8s-min-interval", 10*time.Second, "Minimum time between updates of the pod, so a flapping node does not flood the API server")
+	pflag.StringSlice("proxy-allow-unhealthy-methods", nil, "JSON-RPC methods the proxy forwards even while the node is unhealthy, e.g. eth_syncing")
+	pflag.String("tcp-ready-addr", "", "Address that accepts TCP connections only while the node is ready (disabled when empty)")
+	pflag.String("rpc-proxy-url", "", "Proxy for requests to the node and reference endpoints, overriding HTTP_PROXY and HTTPS_PROXY; may include user:password@")
+	pflag.String("rpc-ca-file", "", "CA bundle to verify the certificate of the node with, in addition to the system roots")
+	pflag.String("rpc-client-cert", "", "Client certificate file to present to the node")
+	pflag.String("rpc-client-key", "", "Private key file of rpc-client-cert")
+	pflag.Bool("rpc-insecure-skip-verify", false, "DANGEROUS: do not verify the certificate of the node, which lets anyone on the path impersonate it")
+	pflag.StringSlice("allow-cidr", nil, "CIDR range allowed to reach the health server (repeatable); other clients get 403")
+	pflag.Bool("trust-proxy-headers", false, "Take the client address from the last X-Forwarded-For entry, for allow-cidr behind a proxy")
+	pflag.String("status-token", "", "Bearer token required by /status, /events, /config and /admin; the probes stay unauthenticated")
+	pflag.String("tls-cert", "", "Certificate file to serve the probe endpoints over HTTPS with, reloaded when it changes")
+	pflag.String("tls-key", "", "Private key file of tls-cert")
+	pflag.String("tls-client-ca", "", "CA file that client certificates must be signed by; requires client certificates when set")
+	pflag.String("live-check", "rpc", "Liveness check mode: rpc (require RPC reachability) or none")
+	documentEnv(pflag.CommandLine)
+	pflag.Usage = usage
+	pflag.CommandLine.Parse(parseCommand(os.Args[1:]))
+	checkCommandFlags()
+	if selectedCommand.name == "version" {
+		return
+	}
+	// The startup settings are built on the global instance before anything
+	// reads them concurrently, and reloads replace them with new instances
 	viper.BindPFlags(pflag.CommandLine)
+	currentSettings.Store(viper.GetViper())
 
-	viper.AutomaticEnv()
-	log.Info().Msg("Service initialized")
-}
+	configureEnv(settings())
+	warnLegacyEnv()
+	warnDeprecatedSettings()
 
-func main() {
-	url := viper.GetString("eth-url")
-	retryClient := retryablehttp.NewClient()
-	retryClient.Logger = nil
-	retryClient.RetryMax = 50
-	retryClient.RetryWaitMin = 5 * time.Second
-	retryClient.RetryWaitMax = 15 * time.Second
-
-	// Making a lightweight request to check node readiness
-	req, err := retryablehttp.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to create a new request")
+	if err := loadConfigFile(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid config file")
+	}
+	if err := configureLogging(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid logging configuration")
 	}
+	if err := applyProfile(settings()); err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	info := buildInfo()
+	log.Info().Str("version", info.Version).Str("commit", info.Commit).Str("build_date", info.BuildDate).Msg("Service initialized")
+}
 
-	resp, err := retryClient.Do(req)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to perform a new request")
+// configureLogging applies the log-level and log-format settings to zerolog
+func configureLogging() error {
+	if err := configureLogLevel(); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	http.HandleFunc("/ready", readinessHandler)
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatal().Err(err).Msg("Failed to start the server")
-		os.Exit(1) // Exit the program after logging the fatal error
+	switch format := settings().GetString("log-format"); format {
+	case "json":
+	case "console":
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	default:
+		return fmt.Errorf("invalid log format %q, expected json or console", format)
 	}
+
+	return nil
 }
 
-func readinessHandler(w http.ResponseWriter, r *http.Request) {
-	url := viper.GetString("eth-url")
-	if nodeHealth(url) {
-		w.WriteHeader(http.StatusOK)
-	} else {
-		log.Warn().Msg("Node is not healthy")
-		w.WriteHeader(http.StatusServiceUnavailable)
+// configureLogLevel applies the log-level setting. It is the only logging
+// setting a reload applies, since the logger is in use by then.
+func configureLogLevel() error {
+	level, err := zerolog.ParseLevel(settings().GetString("log-level"))
+	if err != nil {
+		return err
 	}
+	zerolog.SetGlobalLevel(level)
+	return nil
 }
 
-func blockDelta(url string) (int, error) {
-	// Connect to the Ethereum client
-	client, err := ethclient.Dial(url)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to connect to the Ethereum client")
-		return 0, err
+func main() {
+	os.Exit(selectedCommand.run())
+}
+
+// run checks the node once with one-shot and serves the health endpoints
+// otherwise
+func run() {
+	if err := validateConfig(settings()); err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
 	}
 
-	// Get the latest block
-	blockNumber, err := client.BlockByNumber(context.Background(), nil)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to retrieve the latest block")
-		return 0, err
+	logEffectiveConfig()
+	if err := watchConfig(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to watch the config file")
+	}
+	if err := setupTracing(settings().GetString("otel-endpoint"), settings().GetFloat64("otel-sample-ratio")); err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
 	}
 
-	// Get the block timestamps
-	blockTimestamp := time.Unix(int64(blockNumber.Time()), 0)
-	currentTimestamp := time.Now()
+	// validateConfig has already checked the names
+	checks, _ := selectChecks(settings().GetString("checks"))
+	setEnabledChecks(checks)
+	windows, _ := parseMaintenanceWindows(settings())
+	setMaintenanceWindows(windows)
+	if name := settings().GetString("profile"); name != "" {
+		log.Info().Str("profile", name).Msg("Using profile")
+	}
+	log.Info().Strs("checks", checkNames(checks.Checks())).Msg("Enabled checks")
+	forkPins, _ = pinnedBlocks(settings())
 
-	// Get the max-seconds-behind value
-	maxSecondsBehind := viper.GetInt("max-seconds-behind")
+	retryClient := configureRPC()
 
-	delta := currentTimestamp.Sub(blockTimestamp).Seconds()
+	transitionHooks = startHooks()
 
-	// Compare the timestamps
-	if delta > float64(maxSecondsBehind) {
-		log.Error().Msgf("Node is too far behind: %f", delta)
-		return int(delta), err
+	var err error
+	if path := settings().GetString("state-file"); path != "" {
+		if persistedHeads, err = loadHeadState(path); err != nil {
+			log.Fatal().Err(err).Str("state_file", path).Msg("Failed to read the state file")
+		}
+	}
+	if events, err = openEventLog(settings().GetString("event-log-file"), settings().GetInt("event-log-size")); err != nil {
+		log.Fatal().Err(err).Str("event_log_file", settings().GetString("event-log-file")).Msg("Failed to open the event log file")
 	}
 
-	return int(delta), nil
-}
+	// Named targets replace the single node configured by eth-url
+	if specs, _ := parseTargets(settings()); len(specs) != 0 {
+		startTargets(specs)
+		probeMux.HandleFunc("/ready", targetsReadinessHandler)
+		probeMux.HandleFunc("/ready/", targetReadinessHandler)
+		probeMux.HandleFunc("/live", targetsLivenessHandler)
+		probeMux.HandleFunc("/status", targetsStatusHandler)
+		startHeartbeat(targetsHeartbeat)
+		serve()
+		return
+	}
 
-func checkNodePeers(url string) (int, error) {
-	// Connect to the Ethereum client
-	client, err := ethclient.Dial(url)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to connect to the Ethereum client")
-		return 0, err
+	ethNode = newNodeClient("", settings().GetString("eth-url"))
+	url := ethNode.url
+	if clientType := settings().GetString("client-type"); clientType != "" {
+		// validateConfig has already checked that the name is known
+		canonical, _ := clients.ClientType(clientType)
+		ethNode.forceClientType(canonical)
 	}
+	proxyFailover = newFailover()
+	nodeFiles = newHealthFiles()
 
-	// Get the number of peers
-	peerCount, err := client.PeerCount(context.Background())
-	count := int(peerCount)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to retrieve the number of peers")
-		return 0, err
+	if settings().GetBool("one-shot") {
+		code := runOneShot(ethNode, settings().GetDuration("timeout"))
+		flushTraces()
+		os.Exit(code)
+	}
+
+	if podUpdates, err = startPodUpdater(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up the Kubernetes pod updates")
+	}
+
+	if settings().GetBool("wait-for-node") {
+		if err := awaitNode(retryClient, ethNode); err != nil {
+			log.Fatal().Err(err).Msg("Node did not become reachable before the startup timeout")
+		}
 	}
 
-	// Get the min-peers value
-	minPeers := viper.GetInt("min-peers")
+	resolveChainDefaults(context.Background(), ethNode)
+	ethNode.startClientDetection(settings().GetDuration("client-detect-interval"))
+	if settings().GetString("cl-url") != "" {
+		beaconClient.start(settings().GetDuration("client-detect-interval"))
+	}
 
-	// Compare the number of peers
-	if count < minPeers {
-		return count, err
+	var cache *healthCache
+	if interval := settings().GetDuration("poll-interval"); interval > 0 {
+		cache = ethNode.cache
+		startPoller(ethNode, interval, cache)
+	}
+	if settings().GetBool("subscribe") && clients.IsPersistent(url) {
+		startHeadSubscription(ethNode, settings().GetDuration("subscription-timeout"), cache)
+	}
+	if wsURL := settings().GetString("ws-url"); wsURL != "" && settings().GetDuration("ws-head-timeout") > 0 {
+		startWSHeads(wsURL, settings().GetDuration("ws-head-timeout"))
 	}
 
-	return count, nil
+	probeMux.HandleFunc("/ready", readinessHandler)
+	probeMux.HandleFunc("/live", livenessHandler)
+	probeMux.HandleFunc("/status", statusHandler)
+	probeMux.HandleFunc("/startup", startupHandler)
+	startHeartbeat(nodeHeartbeat)
+	serve()
 }
 
-func nodeHealth(url string) bool {
+// rpcOptions are the headers, TLS and proxy settings the node is reached
+// with, set up once by configureRPC
+var rpcOptions *clients.Options
 
-	var isNodeHealthy bool
-	// Check the block timestamp
-	blockDelta, err := blockDelta(url)
-	if err != nil {
-		log.Error().
-			Err(err).
-			Int("block_delta", int(blockDelta)).
-			Msg("Failed health check by block time delta")
+// rpcContext returns ctx carrying rpcOptions for the calls made with it
+func rpcContext(ctx context.Context) context.Context {
+	return clients.WithOptions(ctx, rpcOptions)
+}
 
-		return false
+// configureRPC sets up rpcOptions from the headers, TLS and proxy settings,
+// returning the retrying client of the startup wait
+func configureRPC() *retryablehttp.Client {
+	headers, err := rpcHeaders(settings())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid RPC headers")
 	}
 
-	// Check the number of peers
-	peerCount, err := checkNodePeers(url)
+	tlsConfig, err := rpcTLSConfig(settings())
 	if err != nil {
-		log.Error().
-			Err(err).
-			Int("peers", peerCount).
-			Msg("Failed health check by peer count")
+		log.Fatal().Err(err).Msg("Invalid RPC TLS configuration")
+	}
+	if settings().GetBool("rpc-insecure-skip-verify") {
+		log.Warn().Msg("Certificates of the node are not verified, connections to it can be intercepted")
+	}
 
-		return false
+	// validateConfig has already checked the proxy URL
+	proxy, _ := rpcProxy(settings())
+	if proxyURL := settings().GetString("rpc-proxy-url"); proxyURL != "" {
+		log.Info().Str("rpc_proxy_url", redactURL(proxyURL)).Msg("Sending RPC requests through the proxy")
+	}
+
+	// Only the startup wait retries. The health checks report a node that
+	// refuses connections right away, within check-timeout.
+	retryClient := newRetryClient(headers, tlsConfig, proxy)
+	rpcOptions = &clients.Options{
+		HTTPClient: newRPCClient(headers, tlsConfig, proxy),
+		Headers:    headers,
+		TLSConfig:  tlsConfig,
+		Proxy:      proxy,
+	}
+	return retryClient
+}
+
+// serve registers the shared handlers and serves until a termination signal,
+// failing readiness for the shutdown delay before stopping the server
+func serve() {
+	probeMux.Handle("/metrics", promhttp.Handler())
+	probeMux.HandleFunc("/version", versionHandler)
+	probeMux.HandleFunc("/events", eventsHandler)
+	probeMux.HandleFunc("/events/stream", streamHandler)
+	if settings().GetString("admin-token") != "" {
+		probeMux.HandleFunc("/config", requireAdminToken(configHandler))
+		if settings().GetBool("admin-endpoints") {
+			probeMux.HandleFunc("/admin/maintenance", requireAdminToken(maintenance.handler))
+			probeMux.HandleFunc("/admin/force-ready", requireAdminToken(forceReady.handler))
+			probeMux.HandleFunc("/admin/min-block", requireAdminToken(minBlock.handler))
+			if persistedHeads != nil {
+				probeMux.HandleFunc("/admin/state", requireAdminToken(stateHandler))
+			}
+		}
 	}
 
-	// Nethermind health check
-	clientType, err := clients.DetectClientType(viper.GetString("eth-url"))
+	listener := listen(settings().GetString("listen-addr"))
+	serverTLS, err := newServerTLS()
 	if err != nil {
-		log.Info().Err(err).Msg("Failed to detect the client type")
-	}
-	if clientType != "Nethermind" {
-		isNodeHealthy = peerCount >= viper.GetInt("min-peers") &&
-			blockDelta <= viper.GetInt("max-seconds-behind")
-
-		log.Info().
-			Bool("is_node_healthy", isNodeHealthy).
-			Int("peer_count", peerCount).
-			Int("block_delta", int(blockDelta)).
-			Str("client_type", clientType).
-			Msg("Node health check")
-
-		return isNodeHealthy
-	} else if clientType == "Nethermind" {
-		health, err := clients.NethermindHealthCheck(url)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to retrieve the Nethermind health")
-			return false
+		log.Fatal().Err(err).Msg("Invalid TLS configuration")
+	}
+	if serverTLS != nil {
+		listener = tls.NewListener(listener, serverTLS.config())
+	}
+
+	server := &http.Server{Handler: restrictAccess(probeMux)}
+	go func() {
+		log.Info().Str("listen_addr", listener.Addr().String()).Bool("tls", serverTLS != nil).Msg("Health server listening")
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("Failed to start the server")
+			os.Exit(1) // Exit the program after logging the fatal error
 		}
-		if len(health.Entries.NodeHealth.Data.Errors) != 0 {
-			log.Error().Msgf("Node health errors: %v", health.Entries.NodeHealth.Data.Errors)
-			return false
+	}()
+	debugServer := startDebugServer()
+	grpcServer := startGRPCHealth()
+	tcpReady := startTCPReady()
+	proxyServer := startRPCProxy()
+
+	// The listeners are open, so systemd may start the units that need medic
+	notifySystemd("READY=1")
+	stopWatchdog := startWatchdog()
+	defer stopWatchdog()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	<-ctx.Done()
+
+	// Fail readiness first so the load balancer stops routing to the node
+	draining.Store(true)
+	notifySystemd("STOPPING=1")
+	if grpcServer != nil {
+		grpcServer.drain()
+	}
+	if tcpReady != nil {
+		tcpReady.drain()
+	}
+	shutdownDelay := settings().GetDuration("shutdown-delay")
+	log.Info().Dur("shutdown_delay", shutdownDelay).Msg("Drain started, failing readiness")
+	time.Sleep(shutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), settings().GetDuration("shutdown-timeout"))
+	defer cancel()
+	// Streams never finish on their own
+	updates.close()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to shut down the server cleanly")
+	}
+	if grpcServer != nil {
+		grpcServer.stop(shutdownCtx)
+	}
+	if proxyServer != nil {
+		if err := proxyServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Failed to shut down the RPC proxy cleanly")
 		}
-		if health.Entries.NodeHealth.Data.IsSyncing {
-			log.Error().Msg("Node is syncing")
-			return false
+	}
+	if nodeFiles != nil {
+		nodeFiles.remove()
+	}
+	if podUpdates != nil {
+		podUpdates.cleanup(shutdownCtx)
+	}
+	if debugServer != nil {
+		if err := debugServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Failed to shut down the debug server cleanly")
 		}
-		isNodeHealthy = !health.Entries.NodeHealth.Data.IsSyncing &&
-			peerCount >= viper.GetInt("min-peers") &&
-			blockDelta <= viper.GetInt("max-seconds-behind")
+	}
+	flushTraces()
+	log.Info().Msg("Drain complete, server stopped")
+}
 
-		log.Info().
-			Bool("is_node_healthy", isNodeHealthy).
-			Bool("is_syncing", health.Entries.NodeHealth.Data.IsSyncing).
-			Int("peer_count", peerCount).
-			Int("block_delta", int(blockDelta)).
-			Str("client_type", clientType).
-			Msg("Node health check")
+// listen binds addr before serving so an address already in use fails startup
+func listen(addr string) net.Listener {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		log.Fatal().Err(err).Str("listen_addr", addr).Msg("Invalid listen address")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal().Err(err).Str("listen_addr", addr).Msg("Failed to bind the listen address")
+	}
+	return listener
+}
+
+func readinessHandler(w http.ResponseWriter, r *http.Request) {
+	if result, ok := forcedUnready(); ok {
+		setHealthHeaders(w, result)
+		writeJSON(w, http.StatusServiceUnavailable, result)
+		return
+	}
+
+	result, ready := forcedReady(&log.Logger, nodeResult(r.Context(), ethNode))
+	setHealthHeaders(w, result)
+	if ready {
+		writeJSON(w, http.StatusOK, result)
+	} else {
+		log.Warn().Msg("Node is not healthy")
+		writeJSON(w, http.StatusServiceUnavailable, result)
+	}
+}
+
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	if nodeLiveness(r.Context(), ethNode.url) {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		log.Warn().Msg("Node is not live")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// nodeLiveness reports whether medic is running and, in rpc mode, whether the
+// RPC endpoint answers a trivial request. Sync thresholds are not considered.
+func nodeLiveness(ctx context.Context, url string) bool {
+	if settings().GetString("live-check") == "none" {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ethNode.context(ctx), settings().GetDuration("check-timeout"))
+	defer cancel()
+
+	// Besu reports its own liveness, which also covers a stuck process
+	if ethNode.clientInfo().Type == "Besu" {
+		if healthCtx, healthURL := clientHealthURL(ctx, "besu-health-url", url); healthURL != "" {
+			health, err := clients.BesuLiveness(healthCtx, healthURL)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to retrieve the Besu liveness")
+				return false
+			}
+			return health.Up()
+		}
+	}
+
+	if _, err := clients.NetVersion(ctx, url); err != nil {
+		log.Error().Err(err).Msg("Failed to reach the Ethereum client")
+		return false
+	}
+
+	return true
+}
 
-		return isNodeHealthy
+// writeJSON writes v as a JSON response body with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("Failed to write the response body")
 	}
-	return false
 }
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// maxWindowDuration bounds maintenance windows, which are found by scanning
+// back minute by minute for the start
+const maxWindowDuration = 7 * 24 * time.Hour
+
+// maintenanceWindow is a recurring window from the maintenance setting.
+// Every window keeps Slack quiet, and not-ready windows also fail readiness.
+type maintenanceWindow struct {
+	name     string
+	cron     *cronSchedule
+	duration time.Duration
+	behavior string
+	location *time.Location
+}
+
+// ActiveWindow is a maintenance window in effect, as reported by /status
+type ActiveWindow struct {
+	Name     string    `json:"name"`
+	Behavior string    `json:"behavior"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+var (
+	windowsMu          sync.RWMutex
+	maintenanceWindows []maintenanceWindow
+)
+
+// setMaintenanceWindows replaces the configured windows, e.g. on a config
+// reload
+func setMaintenanceWindows(windows []maintenanceWindow) {
+	windowsMu.Lock()
+	defer windowsMu.Unlock()
+	maintenanceWindows = windows
+}
+
+// parseMaintenanceWindows parses the maintenance setting. The config file
+// lists windows as maps, while flags and environment variables give each as
+// cron=<spec>;duration=<d>[;behavior=...][;name=...][;timezone=...].
+func parseMaintenanceWindows(v *viper.Viper) ([]maintenanceWindow, error) {
+	var entries []map[string]string
+	switch value := v.Get("maintenance").(type) {
+	case string:
+		if value != "" {
+			entries = append(entries, windowFields(value))
+		}
+	case []string:
+		for _, item := range value {
+			entries = append(entries, windowFields(item))
+		}
+	case []interface{}:
+		for _, item := range value {
+			switch item := item.(type) {
+			case map[string]interface{}:
+				fields := map[string]string{}
+				for key, field := range item {
+					fields[strings.ToLower(key)] = fmt.Sprint(field)
+				}
+				entries = append(entries, fields)
+			default:
+				entries = append(entries, windowFields(fmt.Sprint(item)))
+			}
+		}
+	}
+
+	windows := make([]maintenanceWindow, 0, len(entries))
+	for i, fields := range entries {
+		window, err := newMaintenanceWindow(fields)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %d: %w", i+1, err)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// windowFields splits a window given as key=value pairs separated by ';',
+// since cron lists use commas
+func windowFields(text string) map[string]string {
+	fields := map[string]string{}
+	for _, pair := range strings.Split(text, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		fields[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	return fields
+}
+
+func newMaintenanceWindow(fields map[string]string) (maintenanceWindow, error) {
+	for key := range fields {
+		switch key {
+		case "name", "cron", "duration", "behavior", "timezone":
+		default:
+			return maintenanceWindow{}, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	window := maintenanceWindow{name: fields["name"], behavior: fields["behavior"], location: time.UTC}
+	if window.name == "" {
+		window.name = fields["cron"]
+	}
+	if window.behavior == "" {
+		window.behavior = "not-ready"
+	}
+	if window.behavior != "not-ready" && window.behavior != "suppress-alerts" {
+		return maintenanceWindow{}, fmt.Errorf("unknown behavior %q, expected not-ready or suppress-alerts", window.behavior)
+	}
+
+	var err error
+	if window.cron, err = parseCron(fields["cron"]); err != nil {
+		return maintenanceWindow{}, err
+	}
+	if window.duration, err = time.ParseDuration(fields["duration"]); err != nil {
+		return maintenanceWindow{}, fmt.Errorf("invalid duration %q", fields["duration"])
+	}
+	if window.duration <= 0 || window.duration > maxWindowDuration {
+		return maintenanceWindow{}, fmt.Errorf("duration must be positive and at most %s", maxWindowDuration)
+	}
+	if zone := fields["timezone"]; zone != "" {
+		if window.location, err = time.LoadLocation(zone); err != nil {
+			return maintenanceWindow{}, fmt.Errorf("unknown timezone %q", zone)
+		}
+	}
+	return window, nil
+}
+
+// start returns the latest start of the window that still covers now. The
+// schedule is matched in the window's timezone, so a window keeps its wall
+// clock time across daylight saving changes.
+func (w maintenanceWindow) start(now time.Time) (time.Time, bool) {
+	earliest := now.Add(-w.duration)
+	for t := now.In(w.location).Truncate(time.Minute); t.After(earliest); t = t.Add(-time.Minute) {
+		if w.cron.matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// activeWindows returns the maintenance windows in effect. Windows may
+// overlap, in which case all of them are returned.
+func activeWindows() []ActiveWindow {
+	windowsMu.RLock()
+	defer windowsMu.RUnlock()
+
+	now := time.Now()
+	var active []ActiveWindow
+	for _, window := range maintenanceWindows {
+		if start, ok := window.start(now); ok {
+			active = append(active, ActiveWindow{
+				Name:     window.name,
+				Behavior: window.behavior,
+				Start:    start.UTC(),
+				End:      start.Add(window.duration).UTC(),
+			})
+		}
+	}
+	return active
+}
+
+// inMaintenanceWindow returns the not-ready window in effect, if any
+func inMaintenanceWindow() (ActiveWindow, bool) {
+	for _, window := range activeWindows() {
+		if window.Behavior == "not-ready" {
+			return window, true
+		}
+	}
+	return ActiveWindow{}, false
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowStart(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		window    map[string]string
+		now       time.Time
+		wantStart time.Time
+		active    bool
+	}{
+		{
+			name:      "inside",
+			window:    map[string]string{"cron": "0 2 * * *", "duration": "2h"},
+			now:       at(time.October, 16, 3, 30),
+			wantStart: at(time.October, 16, 2, 0),
+			active:    true,
+		},
+		{
+			name:      "at the start",
+			window:    map[string]string{"cron": "0 2 * * *", "duration": "2h"},
+			now:       at(time.October, 16, 2, 0),
+			wantStart: at(time.October, 16, 2, 0),
+			active:    true,
+		},
+		{
+			name:   "before the start",
+			window: map[string]string{"cron": "0 2 * * *", "duration": "2h"},
+			now:    at(time.October, 16, 1, 59),
+		},
+		{
+			name:   "at the end",
+			window: map[string]string{"cron": "0 2 * * *", "duration": "2h"},
+			now:    at(time.October, 16, 4, 0),
+		},
+		{
+			name:      "across midnight",
+			window:    map[string]string{"cron": "0 23 * * *", "duration": "2h"},
+			now:       at(time.October, 17, 0, 30),
+			wantStart: at(time.October, 16, 23, 0),
+			active:    true,
+		},
+		{
+			// The weekday is that of the start, not of now
+			name:      "across midnight into the next weekday",
+			window:    map[string]string{"cron": "0 23 * * FRI", "duration": "2h"},
+			now:       at(time.October, 17, 0, 30),
+			wantStart: at(time.October, 16, 23, 0),
+			active:    true,
+		},
+		{
+			name:   "across midnight after the end",
+			window: map[string]string{"cron": "0 23 * * FRI", "duration": "2h"},
+			now:    at(time.October, 17, 1, 0),
+		},
+		{
+			name:      "latest of several starts",
+			window:    map[string]string{"cron": "*/15 * * * *", "duration": "1h"},
+			now:       at(time.October, 16, 5, 50),
+			wantStart: at(time.October, 16, 5, 45),
+			active:    true,
+		},
+		{
+			name:      "week long",
+			window:    map[string]string{"cron": "0 0 * * MON", "duration": "168h"},
+			now:       at(time.October, 18, 23, 59),
+			wantStart: at(time.October, 12, 0, 0),
+			active:    true,
+		},
+		{
+			// Noon in New York is 17:00 UTC before the clocks go forward on
+			// March 8 and 16:00 UTC after
+			name:      "timezone before the DST change",
+			window:    map[string]string{"cron": "0 12 * * *", "duration": "1h", "timezone": "America/New_York"},
+			now:       at(time.March, 7, 17, 30),
+			wantStart: time.Date(2026, time.March, 7, 12, 0, 0, 0, newYork),
+			active:    true,
+		},
+		{
+			name:      "timezone after the DST change",
+			window:    map[string]string{"cron": "0 12 * * *", "duration": "1h", "timezone": "America/New_York"},
+			now:       at(time.March, 9, 16, 30),
+			wantStart: time.Date(2026, time.March, 9, 12, 0, 0, 0, newYork),
+			active:    true,
+		},
+		{
+			name:   "timezone after the DST change at the old UTC time",
+			window: map[string]string{"cron": "0 12 * * *", "duration": "1h", "timezone": "America/New_York"},
+			now:    at(time.March, 9, 17, 30),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := newMaintenanceWindow(tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start, active := window.start(tt.now)
+			if active != tt.active {
+				t.Fatalf("start(%s) active = %v, want %v", tt.now, active, tt.active)
+			}
+			if active && !start.Equal(tt.wantStart) {
+				t.Errorf("start(%s) = %s, want %s", tt.now, start.UTC(), tt.wantStart.UTC())
+			}
+		})
+	}
+}
+
+// Overlapping windows are all active, and readiness follows the not-ready
+// one wherever it is listed
+func TestOverlappingMaintenanceWindows(t *testing.T) {
+	first, err := newMaintenanceWindow(map[string]string{"name": "alerts", "cron": "0 2 * * *", "duration": "2h", "behavior": "suppress-alerts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newMaintenanceWindow(map[string]string{"name": "upgrade", "cron": "0 3 * * *", "duration": "2h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, time.October, 16, 3, 30, 0, 0, time.UTC)
+	for _, window := range []maintenanceWindow{first, second} {
+		if _, active := window.start(now); !active {
+			t.Errorf("window %s not active at %s", window.name, now)
+		}
+	}
+
+	// Windows that started a minute ago are in effect now
+	always := func(name, behavior string) maintenanceWindow {
+		window, err := newMaintenanceWindow(map[string]string{"name": name, "cron": "* * * * *", "duration": "1h", "behavior": behavior})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return window
+	}
+	setMaintenanceWindows([]maintenanceWindow{always("alerts", "suppress-alerts"), always("upgrade", "not-ready")})
+	t.Cleanup(func() { setMaintenanceWindows(nil) })
+
+	if active := activeWindows(); len(active) != 2 {
+		t.Errorf("active windows = %v, want both", active)
+	}
+	window, ok := inMaintenanceWindow()
+	if !ok || window.Name != "upgrade" {
+		t.Errorf("inMaintenanceWindow() = %v, %v, want the upgrade window", window, ok)
+	}
+}
+
+func TestNewMaintenanceWindowInvalid(t *testing.T) {
+	for _, fields := range []map[string]string{
+		{"cron": "0 2 * *", "duration": "1h"},
+		{"cron": "0 2 * * *"},
+		{"cron": "0 2 * * *", "duration": "0s"},
+		{"cron": "0 2 * * *", "duration": "169h"},
+		{"cron": "0 2 * * *", "duration": "1h", "behavior": "ignore"},
+		{"cron": "0 2 * * *", "duration": "1h", "timezone": "Mars/Olympus"},
+		{"cron": "0 2 * * *", "duration": "1h", "when": "now"},
+	} {
+		if _, err := newMaintenanceWindow(fields); err == nil {
+			t.Errorf("newMaintenanceWindow(%v) accepted an invalid window", fields)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rarecrumb/medic/health"
+)
+
+var (
+	nodeHealthyGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_node_healthy",
+		Help: "Whether the node passed the last health check (1) or not (0)",
+	}, []string{"client_type"})
+
+	blockDeltaGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_block_delta_seconds",
+		Help: "Seconds between the latest block timestamp and the local clock",
+	})
+
+	blockNumberGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_block_number",
+		Help: "Number of the latest block reported by the node",
+	})
+
+	peerCountGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_peer_count",
+		Help: "Number of peers reported by the node",
+	})
+
+	nodeSyncingGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_node_syncing",
+		Help: "Whether the node reports that it is syncing (1) or not (0)",
+	})
+
+	syncRateGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_sync_rate_blocks_per_second",
+		Help: "Smoothed rate at which the syncing node imports blocks, 0 when not syncing",
+	})
+
+	syncRemainingGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_sync_remaining_blocks",
+		Help: "Blocks between the current and highest block of the syncing node, 0 when not syncing",
+	})
+
+	maintenanceGauge = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "medic_maintenance",
+		Help: "Whether an operator put the node in maintenance (1) or not (0)",
+	}, func() float64 { return boolToFloat(maintenance.get().Enabled) })
+
+	inMaintenanceGauge = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "medic_in_maintenance",
+		Help: "Whether a scheduled maintenance window is in effect (1) or not (0)",
+	}, func() float64 { return boolToFloat(len(activeWindows()) != 0) })
+
+	forcedReadyGauge = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "medic_forced_ready",
+		Help: "Whether an operator forced readiness to pass (1) or not (0)",
+	}, func() float64 { return boolToFloat(forceReady.get().Enabled) })
+
+	heartbeatFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medic_heartbeat_failures_total",
+		Help: "Number of heartbeat pings that failed after retrying, by endpoint host",
+	}, []string{"endpoint"})
+
+	rejectedRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medic_rejected_requests_total",
+		Help: "Number of requests to the health server rejected by the allowed ranges (address) or the status token (token)",
+	}, []string{"reason"})
+
+	probeChecksCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medic_probe_checks_total",
+		Help: "Number of on-demand health requests by whether they ran an evaluation (executed), shared a concurrent one (coalesced) or reused a recent result (cached)",
+	}, []string{"outcome"})
+
+	checkFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medic_check_failures_total",
+		Help: "Number of failed health checks by reason",
+	}, []string{"reason"})
+
+	failureStreakGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_failure_streak",
+		Help: "Number of consecutive failed health evaluations",
+	})
+
+	successStreakGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_success_streak",
+		Help: "Number of consecutive passed health evaluations",
+	})
+
+	clientInfoGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_client_info",
+		Help: "Detected client type and version of the node, always 1",
+	}, []string{"client_type", "client_version"})
+
+	clClientInfoGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_cl_client_info",
+		Help: "Detected client type and version of the cl-url beacon node, always 1",
+	}, []string{"client_type", "client_version"})
+
+	chainInfoGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_chain_info",
+		Help: "Chain ID observed on the node, always 1",
+	}, []string{"chain_id"})
+
+	taggedBlockNumberGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_tagged_block_number",
+		Help: "Number of the finalized and safe blocks reported by the node",
+	}, []string{"tag"})
+
+	taggedBlockAgeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_tagged_block_age_seconds",
+		Help: "Age of the finalized and safe blocks reported by the node",
+	}, []string{"tag"})
+
+	finalizedEpochGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_cl_finalized_epoch",
+		Help: "Epoch of the finalized checkpoint reported by the beacon node",
+	})
+
+	finalityAgeGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_cl_finality_age_seconds",
+		Help: "Seconds since the start of the finalized epoch reported by the beacon node",
+	})
+
+	reorgsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medic_head_reorgs_total",
+		Help: "Number of head rollbacks and repeated hash changes detected, by kind",
+	}, []string{"kind"})
+
+	txPoolGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_txpool_transactions",
+		Help: "Number of transactions in the node's txpool by state",
+	}, []string{"state"})
+
+	feeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_fee_gwei",
+		Help: "Gas price suggested by eth_gasPrice and next base fee from eth_feeHistory, in gwei",
+	}, []string{"kind"})
+
+	adminPeersGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_admin_peers",
+		Help: "Peers reported by admin_peers, all connected (total) and those on the same network (useful)",
+	}, []string{"kind"})
+
+	targetHealthyGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_target_healthy",
+		Help: "Whether the named target is healthy (1) or not (0)",
+	}, []string{"target", "client_type"})
+
+	targetBlockNumberGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_target_block_number",
+		Help: "Latest block number of the named target",
+	}, []string{"target"})
+
+	targetBlockDeltaGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_target_block_delta_seconds",
+		Help: "Seconds between the latest block of the named target and the local clock",
+	}, []string{"target"})
+
+	targetPeerCountGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_target_peer_count",
+		Help: "Peer count of the named target",
+	}, []string{"target"})
+
+	targetClientInfoGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_target_client_info",
+		Help: "Client type and version detected for the named target",
+	}, []string{"target", "client_type", "version"})
+
+	targetCheckFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medic_target_check_failures_total",
+		Help: "Failed health checks of the named target by reason",
+	}, []string{"target", "reason"})
+
+	healthyTargetsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "medic_healthy_targets",
+		Help: "Number of named targets that are healthy",
+	})
+
+	probeLatencyGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medic_probe_latency_seconds",
+		Help: "Latency of the last run of each optional probe",
+	}, []string{"probe"})
+
+	buildInfoGauge = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "medic_build_info",
+		Help:        "Always 1, labeled with the version, commit, build date and Go version of medic",
+		ConstLabels: buildInfo().labels(),
+	}, func() float64 { return 1 })
+
+	rpcDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "medic_rpc_duration_seconds",
+		Help:    "Latency of RPC calls made to the node",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+// observeRPC records the latency of an RPC call that started at start, also
+// in the latency tracker of the node ctx evaluates
+func observeRPC(ctx context.Context, method string, start time.Time) {
+	elapsed := time.Since(start)
+	rpcDurationHistogram.WithLabelValues(method).Observe(elapsed.Seconds())
+	trackLatency(ctx, method, elapsed)
+}
+
+// recordMetrics updates the exported gauges and counters from a health result
+func recordMetrics(result HealthResult) {
+	nodeHealthyGauge.Reset()
+	nodeHealthyGauge.WithLabelValues(result.ClientType).Set(boolToFloat(result.Healthy))
+
+	if _, ok := result.Checks["block_delta"]; ok {
+		blockDeltaGauge.Set(float64(result.intValue("block_delta")))
+	}
+	if result.BlockNumber != 0 {
+		blockNumberGauge.Set(float64(result.BlockNumber))
+	}
+	if result.ChainID != 0 {
+		chainInfoGauge.Reset()
+		chainInfoGauge.WithLabelValues(strconv.FormatUint(result.ChainID, 10)).Set(1)
+	}
+	if check, ok := result.Checks["peers"]; ok && !check.Skipped {
+		peerCountGauge.Set(float64(result.intValue("peers")))
+	}
+	if check, ok := result.Checks["syncing"]; ok {
+		nodeSyncingGauge.Set(boolToFloat(!check.OK && check.Err == nil))
+	} else {
+		nodeSyncingGauge.Set(0)
+	}
+	if result.Syncing != nil {
+		syncRateGauge.Set(result.Syncing.Rate)
+		syncRemainingGauge.Set(float64(result.Syncing.Highest - min(result.Syncing.Current, result.Syncing.Highest)))
+	} else {
+		syncRateGauge.Set(0)
+		syncRemainingGauge.Set(0)
+	}
+
+	for tag, block := range map[string]*health.TaggedBlock{"finalized": result.Finalized, "safe": result.Safe} {
+		if block != nil {
+			taggedBlockNumberGauge.WithLabelValues(tag).Set(float64(block.Number))
+			taggedBlockAgeGauge.WithLabelValues(tag).Set(block.Age)
+		}
+	}
+
+	if result.Consensus != nil && result.Consensus.Finality != nil {
+		finalizedEpochGauge.Set(float64(result.Consensus.Finality.FinalizedEpoch))
+		finalityAgeGauge.Set(result.Consensus.Finality.Age)
+	}
+
+	if result.Peers != nil {
+		adminPeersGauge.WithLabelValues("total").Set(float64(result.Peers.Total))
+		adminPeersGauge.WithLabelValues("useful").Set(float64(result.Peers.Useful))
+	}
+	if result.TxPool != nil {
+		txPoolGauge.WithLabelValues("pending").Set(float64(result.TxPool.Pending))
+		txPoolGauge.WithLabelValues("queued").Set(float64(result.TxPool.Queued))
+	}
+
+	if result.Fees != nil {
+		feeGauge.WithLabelValues("gas_price").Set(result.Fees.GasPrice)
+		feeGauge.WithLabelValues("base_fee").Set(result.Fees.BaseFee)
+	}
+
+	failureStreakGauge.Set(float64(result.FailureStreak))
+	successStreakGauge.Set(float64(result.SuccessStreak))
+
+	for _, reason := range result.Reasons {
+		checkFailuresCounter.WithLabelValues(reason).Inc()
+	}
+}
+
+// recordClientInfo replaces the client info series with the detected client
+func recordClientInfo(clientType, version string) {
+	clientInfoGauge.Reset()
+	clientInfoGauge.WithLabelValues(clientType, version).Set(1)
+}
+
+// recordBeaconClientInfo replaces the beacon client info series with the
+// detected client
+func recordBeaconClientInfo(clientType, version string) {
+	clClientInfoGauge.Reset()
+	clClientInfoGauge.WithLabelValues(clientType, version).Set(1)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// recordTargetMetrics exports the outcome of an evaluation of a named target
+func recordTargetMetrics(name string, result HealthResult) {
+	targetHealthyGauge.DeletePartialMatch(prometheus.Labels{"target": name})
+	targetHealthyGauge.WithLabelValues(name, result.ClientType).Set(boolToFloat(result.Healthy))
+
+	if _, ok := result.Checks["block_delta"]; ok {
+		targetBlockDeltaGauge.WithLabelValues(name).Set(float64(result.intValue("block_delta")))
+	}
+	if result.BlockNumber != 0 {
+		targetBlockNumberGauge.WithLabelValues(name).Set(float64(result.BlockNumber))
+	}
+	if check, ok := result.Checks["peers"]; ok && !check.Skipped {
+		targetPeerCountGauge.WithLabelValues(name).Set(float64(result.intValue("peers")))
+	}
+
+	for _, reason := range result.Reasons {
+		targetCheckFailuresCounter.WithLabelValues(name, reason).Inc()
+	}
+}
+
+// recordTargetClientInfo replaces the client info series of a named target
+func recordTargetClientInfo(name, clientType, version string) {
+	targetClientInfoGauge.DeletePartialMatch(prometheus.Labels{"target": name})
+	targetClientInfoGauge.WithLabelValues(name, clientType, version).Set(1)
+}
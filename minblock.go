@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// minBlockGate holds the height the head must reach before readiness can
+// pass, e.g. after restoring a node from an old snapshot. A value set through
+// /admin/min-block takes precedence over min-block-file, which takes
+// precedence over min-block-number.
+type minBlockGate struct {
+	mu    sync.Mutex
+	admin *uint64
+}
+
+// MinBlockGate is the gate in effect, as served by /admin/min-block and
+// /status
+type MinBlockGate struct {
+	MinBlockNumber uint64 `json:"min_block_number"`
+	// Source is admin, file or flag
+	Source string `json:"source"`
+	Error  string `json:"error,omitempty"`
+}
+
+// MinBlockStatus is the gate reported by /status along with the head
+type MinBlockStatus struct {
+	MinBlockGate
+	BlockNumber uint64 `json:"block_number"`
+	OK          bool   `json:"ok"`
+}
+
+// minBlock is the gate shared by all nodes
+var minBlock = &minBlockGate{}
+
+// current returns the gate in effect. A min-block-file that does not exist
+// yet leaves min-block-number in effect, while one that cannot be read or
+// parsed is reported in Error.
+func (g *minBlockGate) current() MinBlockGate {
+	g.mu.Lock()
+	admin := g.admin
+	g.mu.Unlock()
+	if admin != nil {
+		return MinBlockGate{MinBlockNumber: *admin, Source: "admin"}
+	}
+
+	if path := settings().GetString("min-block-file"); path != "" {
+		number, err := readMinBlockFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return MinBlockGate{Source: "file", Error: err.Error()}
+		default:
+			return MinBlockGate{MinBlockNumber: number, Source: "file"}
+		}
+	}
+	return MinBlockGate{MinBlockNumber: settings().GetUint64("min-block-number"), Source: "flag"}
+}
+
+// readMinBlockFile reads a block number in decimal or 0x-prefixed hex
+func readMinBlockFile(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	text := strings.TrimSpace(string(data))
+	number, err := strconv.ParseUint(text, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number %q in %s", text, path)
+	}
+	return number, nil
+}
+
+// status returns the gate for a node at head, or nil when no gate is set
+func (g *minBlockGate) status(result HealthResult) *MinBlockStatus {
+	gate := g.current()
+	if gate.MinBlockNumber == 0 && gate.Error == "" {
+		return nil
+	}
+	check := result.Checks["min_block"]
+	return &MinBlockStatus{MinBlockGate: gate, BlockNumber: result.BlockNumber, OK: check.OK}
+}
+
+// handler serves the gate: GET returns it, POST sets it from a JSON body such
+// as {"min_block_number": 19000000} and DELETE removes the value set here
+func (g *minBlockGate) handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var body struct {
+			MinBlockNumber *uint64 `json:"min_block_number"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil || body.MinBlockNumber == nil {
+			http.Error(w, "expected a JSON body with min_block_number", http.StatusBadRequest)
+			return
+		}
+		g.mu.Lock()
+		g.admin = body.MinBlockNumber
+		g.mu.Unlock()
+		log.Warn().Uint64("min_block_number", *body.MinBlockNumber).Msg("Minimum block set by an operator")
+	case http.MethodDelete:
+		g.mu.Lock()
+		g.admin = nil
+		g.mu.Unlock()
+		log.Warn().Msg("Minimum block set by an operator removed")
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, g.current())
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+)
+
+// measureNitroHealth calls the health endpoint in front of the Nitro node
+func measureNitroHealth(ctx context.Context, url string, m *health.Client, errs *errorSet) {
+	var err error
+
+	start := time.Now()
+	m.NitroHealth, err = clients.NitroHealth(ctx, url)
+	observeRPC(ctx, "nitro_health", start)
+	if err != nil {
+		log.Error().Err(clients.WithoutURL(err)).Msg("Failed to retrieve the Nitro health")
+		errs.add("nitro_health", err)
+	} else if m.NitroHealth != http.StatusOK {
+		log.Error().Int("status_code", m.NitroHealth).Msg("Nitro health endpoint reports the node as unhealthy")
+	}
+}
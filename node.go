@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// nodeClient lazily dials the Ethereum client and shares the connection
+// between checks, along with the health state kept for the node across
+// evaluations. It is safe for concurrent use.
+type nodeClient struct {
+	// name is the target name, empty for the node configured by eth-url
+	name string
+	url  string
+
+	// credentials are the user info stripped from the URL, sent only to the
+	// node itself
+	credentials *url.Userinfo
+	optionsOnce sync.Once
+	options     *clients.Options
+
+	mu     sync.Mutex
+	client *ethclient.Client
+
+	// batchRejected is set once the node rejects a JSON-RPC batch and is
+	// cleared on reconnect so batching is retried
+	batchRejected atomic.Bool
+
+	// adminUnavailable is set once the node rejects admin_peers and is
+	// cleared on reconnect, falling back to net_peerCount meanwhile
+	adminUnavailable atomic.Bool
+
+	// verifiedChainID caches a chain ID that matched the expected value, or
+	// any chain ID when none is expected, until the next reconnect
+	verifiedChainID uint64
+
+	// forkVerified caches the pinned block hash comparison until the next
+	// reconnect since historical hashes do not change
+	forkVerified *health.ForkVerification
+
+	infoMu   sync.RWMutex
+	info     clients.ClientInfo
+	forced   bool
+	redetect chan struct{}
+
+	cache   *healthCache
+	streaks *healthTracker
+	heads   *headTracker
+	stream  *headSubscription
+	history *healthHistory
+	startup *startupTracker
+	grace   *graceTracker
+	latency *latencyTracker
+	sync    *syncRateTracker
+}
+
+// ethNode is the shared connection to the node configured by eth-url
+var ethNode *nodeClient
+
+// newNodeClient returns the node at rawURL. Credentials in the URL become
+// basic auth, keeping them out of logs and /status.
+func newNodeClient(name, rawURL string) *nodeClient {
+	nodeURL, credentials := clients.StripCredentials(rawURL)
+	return &nodeClient{
+		name:        name,
+		url:         nodeURL,
+		credentials: credentials,
+		redetect:    make(chan struct{}, 1),
+		cache:       &healthCache{},
+		streaks:     &healthTracker{target: name},
+		heads:       &headTracker{target: name},
+		stream:      &headSubscription{},
+		history:     newHealthHistory(historySize),
+		startup:     &startupTracker{},
+		grace:       &graceTracker{},
+		latency:     newLatencyTracker(),
+		sync:        &syncRateTracker{},
+	}
+}
+
+// context returns ctx carrying the RPC options of the node: rpcOptions with
+// its credentials. The options are built once, since persistent connections
+// are kept per options.
+func (n *nodeClient) context(ctx context.Context) context.Context {
+	n.optionsOnce.Do(func() {
+		n.options = rpcOptions.WithCredentials(n.credentials)
+	})
+	return clients.WithOptions(ctx, n.options)
+}
+
+// logger returns a logger that names the target, if any
+func (n *nodeClient) logger() *zerolog.Logger {
+	return targetLogger(n.name)
+}
+
+// targetLogger returns the global logger for the node configured by eth-url
+// and one that adds the target name for named targets
+func targetLogger(name string) *zerolog.Logger {
+	if name == "" {
+		return &log.Logger
+	}
+	logger := log.With().Str("target", name).Logger()
+	return &logger
+}
+
+// recordInfo exports the client info metric of the node
+func (n *nodeClient) recordInfo(clientType, version string) {
+	if n.name == "" {
+		recordClientInfo(clientType, version)
+		return
+	}
+	recordTargetClientInfo(n.name, clientType, version)
+}
+
+// get returns the current connection, dialing a new one if needed
+func (n *nodeClient) get() (*ethclient.Client, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.client != nil {
+		return n.client, nil
+	}
+
+	// Dial through the clients package so HTTP, WebSocket and IPC endpoints
+	// share the same headers and settings
+	rpcClient, err := clients.Dial(n.context(context.Background()), n.url)
+	if err != nil {
+		return nil, err
+	}
+	client := ethclient.NewClient(rpcClient)
+	n.client = client
+
+	return client, nil
+}
+
+// reset closes the current connection so the next get reconnects
+func (n *nodeClient) reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.client != nil {
+		n.client.Close()
+		n.client = nil
+	}
+	n.batchRejected.Store(false)
+	n.adminUnavailable.Store(false)
+	n.verifiedChainID = 0
+	n.forkVerified = nil
+}
+
+// chainID returns the node's chain ID, querying eth_chainId only until it has
+// matched expected once since the last reconnect. Without an expected value
+// (0) the first answer is cached.
+func (n *nodeClient) chainID(ctx context.Context, expected uint64) (uint64, error) {
+	n.mu.Lock()
+	verified := n.verifiedChainID
+	n.mu.Unlock()
+	if verified != 0 {
+		return verified, nil
+	}
+
+	start := time.Now()
+	chainID, err := clients.ChainID(ctx, n.url)
+	observeRPC(ctx, "eth_chainId", start)
+	if err != nil {
+		return 0, err
+	}
+
+	if expected == 0 || chainID == expected {
+		n.mu.Lock()
+		n.verifiedChainID = chainID
+		n.mu.Unlock()
+	}
+	return chainID, nil
+}
+
+// clientDetectRetryInterval is how often detection is retried after a failure
+const clientDetectRetryInterval = 30 * time.Second
+
+// clientInfo returns the cached client info, with type Unknown until the
+// client has been detected
+func (n *nodeClient) clientInfo() clients.ClientInfo {
+	n.infoMu.RLock()
+	defer n.infoMu.RUnlock()
+
+	if n.info.Type == "" {
+		return clients.ClientInfo{Type: "Unknown"}
+	}
+	return n.info
+}
+
+// forceClientType fixes the client type and disables detection, for nodes
+// that do not serve web3_clientVersion
+func (n *nodeClient) forceClientType(clientType string) {
+	n.infoMu.Lock()
+	defer n.infoMu.Unlock()
+
+	n.info = clients.ClientInfo{Type: clientType}
+	n.forced = true
+	n.logger().Info().Str("client_type", clientType).Msg("Client type forced, skipping detection")
+	n.recordInfo(clientType, "")
+}
+
+// detectClient refreshes the cached client type, falling back to Unknown
+func (n *nodeClient) detectClient(ctx context.Context) error {
+	n.infoMu.RLock()
+	forced := n.forced
+	n.infoMu.RUnlock()
+	if forced {
+		return nil
+	}
+
+	start := time.Now()
+	version, err := clients.ClientVersion(ctx, n.url)
+	observeRPC(ctx, "web3_clientVersion", start)
+
+	n.infoMu.Lock()
+	defer n.infoMu.Unlock()
+
+	if err != nil {
+		if n.info.Type == "" {
+			n.info.Type = "Unknown"
+		}
+		return err
+	}
+
+	info := clients.ParseClientVersion(version)
+	if info != n.info {
+		n.logger().Info().
+			Str("client_type", info.Type).
+			Str("client_version", info.Version).
+			Str("raw_client_version", info.Raw).
+			Msg("Detected client type")
+		n.recordInfo(info.Type, info.Version)
+	}
+	n.info = info
+
+	return nil
+}
+
+// requestClientDetection asks the background detector to refresh the client
+// type, e.g. after an RPC error that suggests the node restarted
+func (n *nodeClient) requestClientDetection() {
+	select {
+	case n.redetect <- struct{}{}:
+	default:
+	}
+}
+
+// startClientDetection detects the client type once and then refreshes it
+// every interval in the background, retrying sooner while detection fails
+func (n *nodeClient) startClientDetection(interval time.Duration) {
+	n.infoMu.RLock()
+	forced := n.forced
+	n.infoMu.RUnlock()
+	if forced {
+		return
+	}
+
+	detect := func() time.Duration {
+		ctx, cancel := context.WithTimeout(n.context(context.Background()), settings().GetDuration("check-timeout"))
+		defer cancel()
+
+		if err := n.detectClient(ctx); err != nil {
+			logDetectionError(err)
+			return min(interval, clientDetectRetryInterval)
+		}
+		return interval
+	}
+
+	next := detect()
+	go func() {
+		for {
+			select {
+			case <-time.After(next):
+			case <-n.redetect:
+			}
+			next = detect()
+		}
+	}()
+}
+
+// logDetectionError explains why client detection failed so that a disabled
+// web3 namespace or a rejecting proxy isn't mistaken for an unknown client
+func logDetectionError(err error) {
+	switch {
+	case errors.Is(err, clients.ErrRPCError):
+		log.Warn().Err(err).Msg("Node rejected web3_clientVersion, is the web3 namespace enabled?")
+	case errors.Is(err, clients.ErrHTTPStatus):
+		log.Warn().Err(err).Msg("Node or proxy rejected the client detection request")
+	case errors.Is(err, clients.ErrEmptyResult):
+		log.Warn().Err(err).Msg("Node returned an empty client version")
+	default:
+		log.Warn().Err(err).Msg("Failed to detect the client type")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rarecrumb/medic/clients/clienttest"
+)
+
+func TestDetectClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(node *clienttest.Server)
+		forced     string
+		clientType string
+		calls      int
+	}{
+		{
+			name: "lowercase nethermind",
+			setup: func(node *clienttest.Server) {
+				node.Handle("web3_clientVersion", "nethermind/v1.25.4+20b10b35/linux-x64/dotnet8.0.2")
+			},
+			clientType: "Nethermind",
+			calls:      1,
+		},
+		{
+			name:       "empty result",
+			setup:      func(node *clienttest.Server) { node.Handle("web3_clientVersion", "") },
+			clientType: "Unknown",
+			calls:      1,
+		},
+		{
+			name:       "web3 disabled",
+			setup:      func(node *clienttest.Server) { node.Unhandle("web3_clientVersion") },
+			clientType: "Unknown",
+			calls:      1,
+		},
+		{
+			// The override skips detection entirely
+			name:       "forced",
+			setup:      func(node *clienttest.Server) { node.Unhandle("web3_clientVersion") },
+			forced:     "Nethermind",
+			clientType: "Nethermind",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := clienttest.NewServer()
+			defer node.Close()
+			tt.setup(node)
+
+			client := newNodeClient("", node.URL)
+			if tt.forced != "" {
+				client.forceClientType(tt.forced)
+			}
+			client.detectClient(context.Background())
+
+			if got := client.clientInfo().Type; got != tt.clientType {
+				t.Errorf("client type = %q, want %q", got, tt.clientType)
+			}
+			if calls := node.Calls("web3_clientVersion"); calls != tt.calls {
+				t.Errorf("web3_clientVersion called %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
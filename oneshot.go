@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// pushFailedExitCode is returned by a healthy one-shot run whose required
+// metrics push failed
+const pushFailedExitCode = 2
+
+// runOneShot evaluates node health once, prints the result as JSON to stdout,
+// pushes the metrics when a Pushgateway is configured and returns the process
+// exit code
+func runOneShot(node *nodeClient, timeout time.Duration) int {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := node.detectClient(node.context(ctx)); err != nil {
+		logDetectionError(err)
+	}
+	if err := beaconClient.detect(rpcContext(ctx)); err != nil {
+		log.Warn().Err(err).Msg("Failed to detect the beacon client type")
+	}
+
+	resolveChainDefaults(ctx, node)
+
+	result := nodeHealth(ctx, node)
+	recordMetrics(result)
+	if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+		log.Error().Err(err).Msg("Failed to write the health result")
+	}
+
+	pushFailed := false
+	if settings().GetString("pushgateway-url") != "" {
+		if err := pushMetrics(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Failed to push the metrics to the Pushgateway")
+			pushFailed = settings().GetBool("push-required")
+		}
+	}
+
+	switch {
+	case !result.Healthy:
+		return 1
+	case pushFailed:
+		return pushFailedExitCode
+	}
+	return 0
+}
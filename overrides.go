@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Override is an operator decision that takes precedence over the health
+// checks until it is disabled or expires
+type Override struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// overrideState holds an override set through an admin endpoint. It lives in
+// memory only, so config reloads leave it in place.
+type overrideState struct {
+	name string
+	// requireUntil rejects overrides without an expiry
+	requireUntil bool
+
+	mu      sync.Mutex
+	current Override
+}
+
+// get returns the override in effect, disabling it once it has expired
+func (s *overrideState) get() Override {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current.Enabled && s.current.Until != nil && time.Now().After(*s.current.Until) {
+		log.Warn().Str("override", s.name).Str("reason", s.current.Reason).Msg("Override expired")
+		s.current = Override{}
+	}
+	return s.current
+}
+
+// set replaces the override, rejecting an expiry in the past
+func (s *overrideState) set(override Override) error {
+	if !override.Enabled {
+		s.clear()
+		return nil
+	}
+	if override.Until == nil && s.requireUntil {
+		return errors.New("until is required")
+	}
+	if override.Until != nil && !override.Until.After(time.Now()) {
+		return errors.New("until must be in the future")
+	}
+
+	now := time.Now()
+	override.Since = &now
+
+	s.mu.Lock()
+	s.current = override
+	s.mu.Unlock()
+
+	event := log.Warn().Str("override", s.name).Str("reason", override.Reason)
+	if override.Until != nil {
+		event = event.Time("until", *override.Until)
+	}
+	event.Msg("Override enabled")
+	return nil
+}
+
+// clear disables the override
+func (s *overrideState) clear() {
+	s.mu.Lock()
+	wasEnabled := s.current.Enabled
+	s.current = Override{}
+	s.mu.Unlock()
+
+	if wasEnabled {
+		log.Warn().Str("override", s.name).Msg("Override disabled")
+	}
+}
+
+// handler serves the override: GET returns it, POST sets it from a JSON body
+// and DELETE disables it
+func (s *overrideState) handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var override Override
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&override); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if err := s.set(override); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		s.clear()
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.get())
+}
+
+// maintenance takes the node out of rotation during planned work
+var maintenance = &overrideState{name: "maintenance"}
+
+// forceReady keeps readiness passing while the node is unhealthy, for when
+// every node trips a check at once and stale data beats no data
+var forceReady = &overrideState{name: "force_ready", requireUntil: true}
+
+// forcedReady applies the force-ready override to an unhealthy result. The
+// override never applies to a node that cannot be reached at all.
+func forcedReady(logger *zerolog.Logger, result HealthResult) (HealthResult, bool) {
+	override := forceReady.get()
+	if !override.Enabled || result.Healthy {
+		return result, result.Healthy
+	}
+
+	if unreachable(result) {
+		checks := make(map[string]health.Result, len(result.Checks)+1)
+		for name, check := range result.Checks {
+			checks[name] = check
+		}
+		checks["force_ready"] = health.Result{
+			OK:     false,
+			Err:    errors.New("health override does not apply to an unreachable node"),
+			Reason: "forced_ready_unreachable",
+		}
+		result.Checks = checks
+		result.Reasons = append(append([]string{}, result.Reasons...), "forced_ready_unreachable")
+		return result, false
+	}
+
+	logger.Warn().
+		Strs("reasons", result.Reasons).
+		Str("override_reason", override.Reason).
+		Time("until", *override.Until).
+		Msg("Health is overridden, reporting ready while unhealthy")
+	result.ForcedReady = true
+	return result, true
+}
+
+// readiness reports whether readiness passes for result, taking the operator
+// overrides into account without logging them
+func readiness(result HealthResult) (bool, []string) {
+	if unready, ok := forcedUnready(); ok {
+		return false, unready.Reasons
+	}
+	nop := zerolog.Nop()
+	result, ready := forcedReady(&nop, result)
+	return ready, result.Reasons
+}
+
+// unreachable reports whether the result failed because the RPC endpoint
+// could not be reached, rather than because of what the node returned
+func unreachable(result HealthResult) bool {
+	if check, ok := result.Checks["connection"]; ok && !check.OK {
+		return true
+	}
+	check := result.Checks["block_delta"]
+	return check.Reason == "rpc_error" || check.Reason == "rpc_timeout"
+}
+
+// forcedUnready returns the failed result readiness must report while medic
+// is draining or the node is in maintenance
+func forcedUnready() (HealthResult, bool) {
+	if draining.Load() {
+		return failedResult("draining", health.Result{
+			OK:     false,
+			Err:    errors.New("medic is shutting down"),
+			Reason: "draining",
+		}), true
+	}
+
+	if override := maintenance.get(); override.Enabled {
+		return failedResult("maintenance", health.Result{
+			OK:     false,
+			Err:    errors.New(override.Reason),
+			Reason: "maintenance",
+		}), true
+	}
+
+	if window, ok := inMaintenanceWindow(); ok {
+		return failedResult("maintenance_window", health.Result{
+			OK:     false,
+			Err:    fmt.Errorf("in maintenance window %s until %s", window.Name, window.End.Format(time.RFC3339)),
+			Reason: "maintenance_window",
+		}), true
+	}
+
+	return HealthResult{}, false
+}
+
+// currentReadiness returns whether /ready would pass and, with targets,
+// whether /ready/{name} would pass for every target
+func currentReadiness(ctx context.Context) (bool, map[string]bool) {
+	ctx, cancel := context.WithTimeout(ctx, settings().GetDuration("check-timeout"))
+	defer cancel()
+
+	targets := make(map[string]bool, len(targetNodes))
+	if _, ok := forcedUnready(); ok {
+		for _, node := range targetNodes {
+			targets[node.name] = false
+		}
+		return false, targets
+	}
+
+	if len(targetNodes) == 0 {
+		_, ready := forcedReady(&log.Logger, nodeResult(ctx, ethNode))
+		return ready, targets
+	}
+
+	aggregate := targetsHealth(ctx)
+	for _, node := range targetNodes {
+		_, targets[node.name] = forcedReady(node.logger(), aggregate.Targets[node.name])
+	}
+	return aggregate.Healthy, targets
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+)
+
+// healthCache holds the latest result produced by the background poller, or
+// by on-demand evaluations when polling is disabled
+type healthCache struct {
+	mu        sync.RWMutex
+	result    HealthResult
+	updatedAt time.Time
+}
+
+// healthTracker debounces raw evaluations so the reported state only changes
+// after consecutive failures or successes, mirroring kubelet probe semantics.
+// The state starts unknown, so the first failed evaluation is a transition to
+// unhealthy, like enough passed ones are to healthy.
+type healthTracker struct {
+	target    string
+	mu        sync.Mutex
+	known     bool
+	healthy   bool
+	failures  int
+	successes int
+}
+
+// apply records a raw evaluation and returns it with Healthy replaced by the
+// debounced state, reporting whether the debounced state changed
+func (t *healthTracker) apply(result HealthResult, failureThreshold, successThreshold int) (HealthResult, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if result.Healthy {
+		t.successes++
+		t.failures = 0
+	} else {
+		t.failures++
+		t.successes = 0
+	}
+
+	transitioned := false
+	switch {
+	case !t.healthy && t.successes >= successThreshold:
+		t.known, t.healthy, transitioned = true, true, true
+		targetLogger(t.target).Warn().
+			Int("success_streak", t.successes).
+			Msg("Node transitioned to healthy")
+	case !t.known && t.failures > 0 || t.healthy && t.failures >= failureThreshold:
+		t.known, t.healthy, transitioned = true, false, true
+		targetLogger(t.target).Warn().
+			Int("failure_streak", t.failures).
+			Strs("reasons", result.Reasons).
+			Msg("Node transitioned to unhealthy")
+	}
+
+	result.Healthy = t.healthy
+	result.FailureStreak = t.failures
+	result.SuccessStreak = t.successes
+	return result, transitioned
+}
+
+// headTracker remembers the last head block seen across polls and when it
+// last advanced, so a wedged node returning the same head or a node that
+// rolled its head back can be detected
+type headTracker struct {
+	target      string
+	mu          sync.Mutex
+	url         string
+	chainID     uint64
+	number      uint64
+	hash        common.Hash
+	highest     uint64
+	hashChanges int
+	rolledBack  bool
+	changedAt   time.Time
+}
+
+// observe records the head seen for the given endpoint and chain. Rollbacks
+// deeper than maxDepth and repeated hash changes are logged and counted once
+// per event. The state resets whenever the endpoint or chain changes.
+func (h *headTracker) observe(url string, chainID, number uint64, hash common.Hash, maxDepth uint64) health.HeadObservation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if url != h.url || chainID != h.chainID || h.changedAt.IsZero() {
+		h.url, h.chainID = url, chainID
+		h.number, h.hash, h.highest = number, hash, number
+		h.hashChanges, h.rolledBack = 0, false
+		h.changedAt = now
+		return health.HeadObservation{Highest: number}
+	}
+
+	switch {
+	case number != h.number:
+		h.changedAt = now
+		h.hashChanges = 0
+	case hash != h.hash && hash != (common.Hash{}) && h.hash != (common.Hash{}):
+		h.hashChanges++
+		if h.hashChanges == health.MaxHashChanges {
+			targetLogger(h.target).Warn().Uint64("block_number", number).Str("old_hash", h.hash.Hex()).Str("new_hash", hash.Hex()).Msg("Head hash keeps changing at the same height")
+			reorgsCounter.WithLabelValues("hash_change").Inc()
+		}
+	}
+
+	rolledBack := h.highest > number && h.highest-number > maxDepth
+	if rolledBack && !h.rolledBack {
+		targetLogger(h.target).Warn().Uint64("highest_block", h.highest).Uint64("block_number", number).Msg("Head rolled back")
+		reorgsCounter.WithLabelValues("rollback").Inc()
+	}
+	h.rolledBack = rolledBack
+
+	h.number = number
+	h.hash = hash
+	h.highest = max(h.highest, number)
+
+	return health.HeadObservation{Stalled: now.Sub(h.changedAt), Highest: h.highest, HashChanges: h.hashChanges}
+}
+
+// checkHealth runs nodeHealth, applies the failure and success thresholds and
+// records the outcome in the exported metrics and the /status history
+func checkHealth(ctx context.Context, node *nodeClient) HealthResult {
+	result, transitioned := node.streaks.apply(
+		nodeHealth(ctx, node),
+		settings().GetInt("failure-threshold"),
+		settings().GetInt("success-threshold"),
+	)
+	if transitioned {
+		event := newEvent(node, result)
+		events.record(event)
+		updates.publish("transition", event, true)
+		notifySlack(node, result)
+		if transitionHooks != nil {
+			transitionHooks.notify(node, result)
+		}
+		if node == ethNode && podUpdates != nil {
+			podUpdates.set(result.Healthy)
+		}
+	}
+	if result.Healthy {
+		node.startup.markReady()
+	}
+	if node.name == "" {
+		recordMetrics(result)
+	} else {
+		recordTargetMetrics(node.name, result)
+	}
+	if node == ethNode && proxyFailover != nil {
+		proxyFailover.observe(result)
+	}
+	if node == ethNode && nodeFiles != nil {
+		nodeFiles.write(result)
+	}
+	if node == ethNode {
+		notifyStatus(result)
+	}
+	entry := node.history.add(result)
+	updates.publish("health", HealthUpdate{Target: node.name, HistoryEntry: entry}, false)
+	recordCheckVars(result)
+	return result
+}
+
+func (c *healthCache) set(result HealthResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result = result
+	c.updatedAt = time.Now()
+}
+
+func (c *healthCache) get() (HealthResult, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.result, c.updatedAt
+}
+
+// probeFlight coalesces concurrent on-demand evaluations of the same node
+var probeFlight singleflight.Group
+
+// probeHealth evaluates node on demand. Concurrent callers share a single
+// evaluation and results younger than probe-cache-ttl are reused, so a burst
+// of probes costs one round of RPC calls.
+func probeHealth(ctx context.Context, node *nodeClient) HealthResult {
+	if result, updatedAt := node.cache.get(); !updatedAt.IsZero() {
+		if age := time.Since(updatedAt); age < settings().GetDuration("probe-cache-ttl") {
+			probeChecksCounter.WithLabelValues("cached").Inc()
+			result.CacheAge = age.Seconds()
+			return result
+		}
+	}
+
+	executed := false
+	value, _, _ := probeFlight.Do(node.name, func() (interface{}, error) {
+		executed = true
+		// Other callers may be waiting for the evaluation, so it must not be
+		// cancelled when the caller that started it gives up
+		result := checkHealth(context.WithoutCancel(ctx), node)
+		node.cache.set(result)
+		return result, nil
+	})
+	if executed {
+		probeChecksCounter.WithLabelValues("executed").Inc()
+	} else {
+		probeChecksCounter.WithLabelValues("coalesced").Inc()
+	}
+	return value.(HealthResult)
+}
+
+// startPoller runs checkHealth every interval and stores the result in cache
+func startPoller(node *nodeClient, interval time.Duration, cache *healthCache) {
+	log.Info().Dur("poll_interval", interval).Msg("Starting background health poller")
+
+	lastPoll.Store(time.Now().UnixNano())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cache.set(checkHealth(context.Background(), node))
+			lastPoll.Store(time.Now().UnixNano())
+			<-ticker.C
+		}
+	}()
+}
+
+// cachedHealth returns the cached result, marked unhealthy when the poller has
+// not produced a result within three poll intervals
+func cachedHealth(cache *healthCache, interval time.Duration) HealthResult {
+	result, updatedAt := cache.get()
+	if updatedAt.IsZero() {
+		return failedResult("poller", health.Result{
+			OK:     false,
+			Err:    errors.New("no health result available yet"),
+			Reason: "no_health_result",
+		})
+	}
+
+	age := time.Since(updatedAt)
+	result.CacheAge = age.Seconds()
+
+	if age > 3*interval {
+		log.Warn().Dur("cache_age", age).Msg("Cached health result is stale")
+
+		checks := make(map[string]health.Result, len(result.Checks)+1)
+		for name, check := range result.Checks {
+			checks[name] = check
+		}
+		checks["poller"] = health.Result{
+			OK:        false,
+			Value:     age.Seconds(),
+			Threshold: (3 * interval).Seconds(),
+			Err:       errors.New("stale health result"),
+			Reason:    "stale_health_result",
+		}
+		result.Checks = checks
+		result.Reasons = append(append([]string{}, result.Reasons...), "stale_health_result")
+		result.Healthy = false
+	}
+
+	return result
+}
@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestHealthTrackerTransitions(t *testing.T) {
+	type step struct {
+		healthy      bool
+		want         bool
+		transitioned bool
+	}
+	tests := []struct {
+		name             string
+		failureThreshold int
+		successThreshold int
+		steps            []step
+	}{
+		{
+			// A node that never passes still reports its first failure
+			name:             "unhealthy from the start",
+			failureThreshold: 3,
+			successThreshold: 1,
+			steps: []step{
+				{healthy: false, want: false, transitioned: true},
+				{healthy: false, want: false},
+				{healthy: false, want: false},
+				{healthy: true, want: true, transitioned: true},
+			},
+		},
+		{
+			name:             "healthy from the start",
+			failureThreshold: 1,
+			successThreshold: 1,
+			steps: []step{
+				{healthy: true, want: true, transitioned: true},
+				{healthy: true, want: true},
+				{healthy: false, want: false, transitioned: true},
+			},
+		},
+		{
+			name:             "success threshold at the start",
+			failureThreshold: 1,
+			successThreshold: 2,
+			steps: []step{
+				{healthy: true, want: false},
+				{healthy: true, want: true, transitioned: true},
+			},
+		},
+		{
+			name:             "failure threshold",
+			failureThreshold: 2,
+			successThreshold: 1,
+			steps: []step{
+				{healthy: true, want: true, transitioned: true},
+				{healthy: false, want: true},
+				{healthy: false, want: false, transitioned: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &healthTracker{}
+			for i, step := range tt.steps {
+				var raw HealthResult
+				raw.Healthy = step.healthy
+				result, transitioned := tracker.apply(raw, tt.failureThreshold, tt.successThreshold)
+				if result.Healthy != step.want || transitioned != step.transitioned {
+					t.Errorf("evaluation %d: healthy = %v, transitioned = %v, want %v, %v", i+1, result.Healthy, transitioned, step.want, step.transitioned)
+				}
+			}
+		})
+	}
+}
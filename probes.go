@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+)
+
+// runProbe runs probe bounded by timeout, records its latency and logs
+// failures
+func runProbe(ctx context.Context, name string, timeout time.Duration, probe func(ctx context.Context) error) *health.Probe {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := probe(ctx)
+	result := &health.Probe{Latency: time.Since(start), Err: err}
+	probeLatencyGauge.WithLabelValues(name).Set(result.Latency.Seconds())
+
+	switch {
+	case clients.IsMethodNotFound(err):
+		log.Warn().Err(err).Str("probe", name).Msg("Node does not serve the probed method")
+	case err != nil:
+		log.Error().Err(err).Str("probe", name).Dur("latency", result.Latency).Msg("Probe failed")
+	}
+	return result
+}
+
+// measureProbes runs the optional probes that are enabled
+func measureProbes(ctx context.Context, url string, m *health.Client) {
+	m.Probes = map[string]*health.Probe{}
+
+	if settings().GetBool("check-getlogs") && checkEnabled("getlogs") && m.BlockNumber != 0 {
+		head := m.BlockNumber
+		from := head - min(head, settings().GetUint64("getlogs-range")-1)
+		m.Probes["getlogs"] = runProbe(ctx, "getlogs", settings().GetDuration("getlogs-max-latency"), func(ctx context.Context) error {
+			start := time.Now()
+			_, err := clients.GetLogs(ctx, url, from, head)
+			observeRPC(ctx, "eth_getLogs", start)
+			return err
+		})
+	}
+
+	if settings().GetBool("check-state") && checkEnabled("state") {
+		m.Probes["state"] = runProbe(ctx, "state", settings().GetDuration("check-timeout"), func(ctx context.Context) error {
+			return readState(ctx, url, "latest")
+		})
+	}
+
+	if block := settings().GetUint64("check-archive-block"); block != 0 && checkEnabled("archive") {
+		m.Probes["archive"] = runProbe(ctx, "archive", settings().GetDuration("check-timeout"), func(ctx context.Context) error {
+			start := time.Now()
+			_, err := clients.Balance(ctx, url, common.Address{}, hexutil.EncodeUint64(block))
+			observeRPC(ctx, "eth_getBalance", start)
+			return err
+		})
+	}
+
+	if settings().GetUint64("max-txpool-pending") != 0 && checkEnabled("txpool") {
+		m.Probes["txpool"] = runProbe(ctx, "txpool", settings().GetDuration("check-timeout"), func(ctx context.Context) error {
+			method := "txpool_status"
+			if m.Type == "Besu" {
+				method = "txpool_besuStatistics"
+			}
+			start := time.Now()
+			status, err := clients.TxPool(ctx, url, m.Type)
+			observeRPC(ctx, method, start)
+			m.TxPool = status
+			return err
+		})
+	}
+
+	if settings().GetBool("check-gas-price") && checkEnabled("gas-price") {
+		m.Probes["gas_price"] = runProbe(ctx, "gas_price", settings().GetDuration("check-timeout"), func(ctx context.Context) error {
+			start := time.Now()
+			price, err := clients.GasPrice(ctx, url)
+			observeRPC(ctx, "eth_gasPrice", start)
+			if err != nil {
+				return err
+			}
+
+			start = time.Now()
+			baseFee, err := clients.NextBaseFee(ctx, url, feeHistoryBlocks)
+			observeRPC(ctx, "eth_feeHistory", start)
+			if err != nil {
+				return err
+			}
+
+			m.GasPrice, m.BaseFee = price, baseFee
+			return nil
+		})
+	}
+
+	// Trace the parent of the head, which every client has fully imported
+	if settings().GetBool("check-trace") && checkEnabled("trace") && m.BlockNumber > 1 {
+		block := m.BlockNumber - 1
+		m.Probes["trace"] = runProbe(ctx, "trace", settings().GetDuration("trace-timeout"), func(ctx context.Context) error {
+			start := time.Now()
+			err := clients.TraceBlock(ctx, url, m.Type, block)
+			observeRPC(ctx, clients.TraceMethod(m.Type), start)
+			return err
+		})
+	}
+}
+
+// readState performs the cheap state read configured for the state check,
+// either an eth_call when a contract is set or an eth_getBalance
+func readState(ctx context.Context, url string, block string) error {
+	start := time.Now()
+	if to := settings().GetString("state-call-to"); to != "" {
+		_, err := clients.Call(ctx, url, common.HexToAddress(to), common.FromHex(settings().GetString("state-call-data")), block)
+		observeRPC(ctx, "eth_call", start)
+		return err
+	}
+
+	_, err := clients.Balance(ctx, url, common.HexToAddress(settings().GetString("state-address")), block)
+	observeRPC(ctx, "eth_getBalance", start)
+	return err
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// profile is a named preset over the check registry for a kind of node.
+// Settings that are set explicitly, from flags, the environment or the config
+// file, always win over the profile.
+type profile struct {
+	// checks are the checks selected unless checks is set
+	checks []string
+	// defaults replace the flag defaults of the named settings
+	defaults map[string]interface{}
+	// maxBlockAge caps the max-block-age default, which otherwise comes from
+	// the chain
+	maxBlockAge time.Duration
+}
+
+// profiles are the presets selectable with --profile
+var profiles = map[string]profile{
+	// validator checks what the EL+CL pair needs to attest on time and skips
+	// the RPC serving checks
+	"validator": {
+		checks: []string{
+			"block-delta", "head-progress", "reorg", "restart-rollback", "peers", "syncing",
+			"chain-id", "fork", "finalized-lag", "safe-lag", "reference",
+			"nethermind-health", "besu-readiness", "reth-stages", "consensus", "ws", "builder",
+		},
+		defaults: map[string]interface{}{
+			"max-finality-age": 30 * time.Minute,
+			"cl-min-peers":     16,
+		},
+		maxBlockAge: 24 * time.Second,
+	},
+}
+
+// profileNames returns the names of the profiles in order
+func profileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// activeProfile returns the profile selected with --profile, if any
+func activeProfile() (profile, bool) {
+	p, ok := profiles[settings().GetString("profile")]
+	return p, ok
+}
+
+// applyProfile makes the defaults of the profile selected in v the defaults
+// of v. The peer check of the execution client is left out when its p2p is
+// restricted.
+func applyProfile(v *viper.Viper) error {
+	name := v.GetString("profile")
+	if name == "" {
+		return nil
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q, valid profiles are %s", name, strings.Join(profileNames(), ", "))
+	}
+
+	checks := p.checks
+	if v.GetBool("el-p2p-restricted") {
+		checks = slices.DeleteFunc(slices.Clone(checks), func(check string) bool { return check == "peers" })
+	}
+	v.SetDefault("checks", strings.Join(checks, ","))
+	for key, value := range p.defaults {
+		v.SetDefault(key, value)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+)
+
+// startupTracker follows the sync progress of a node between polls so a
+// startup probe can tell a node that is catching up from one that is stuck
+type startupTracker struct {
+	mu        sync.Mutex
+	observed  bool
+	reachable bool
+	current   uint64
+	distance  uint64
+	changedAt time.Time
+	ready     bool
+}
+
+// StartupStatus is the body served by /startup
+type StartupStatus struct {
+	Started      bool    `json:"started"`
+	Reason       string  `json:"reason"`
+	CurrentBlock uint64  `json:"current_block,omitempty"`
+	SyncDistance uint64  `json:"sync_distance,omitempty"`
+	Stalled      float64 `json:"stalled_seconds,omitempty"`
+}
+
+// observe records the block the node has reached and how far it is from the
+// highest known block. Progress is a higher current block or a shorter
+// distance, since the highest block keeps moving during sync.
+func (s *startupTracker) observe(m *health.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.reachable = m.Errors["connection"] == nil && m.Errors["block_delta"] == nil
+	if !s.reachable {
+		if !s.observed {
+			s.observed, s.changedAt = true, now
+		}
+		return
+	}
+
+	current, distance := m.BlockNumber, uint64(0)
+	if status := m.SyncStatus; status != nil && status.Syncing {
+		current = status.CurrentBlock
+		if status.HighestBlock > current {
+			distance = status.HighestBlock - current
+		}
+	}
+
+	if !s.observed || current > s.current || distance < s.distance {
+		s.changedAt = now
+	}
+	s.observed = true
+	s.current, s.distance = current, distance
+}
+
+// markReady records that the node passed readiness, after which the startup
+// probe always passes
+func (s *startupTracker) markReady() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ready = true
+}
+
+// status reports whether the node has started or is still making progress
+// within stallTimeout
+func (s *startupTracker) status(stallTimeout time.Duration) StartupStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := StartupStatus{CurrentBlock: s.current, SyncDistance: s.distance}
+	switch {
+	case s.ready:
+		status.Started, status.Reason = true, "ready"
+	case !s.observed:
+		status.Reason = "no_health_result"
+	case !s.reachable:
+		status.Reason = "node_unreachable"
+	default:
+		stalled := time.Since(s.changedAt)
+		status.Stalled = stalled.Seconds()
+		if stalled > stallTimeout {
+			status.Reason = "sync_stalled"
+		} else {
+			status.Started, status.Reason = true, "sync_progressing"
+		}
+	}
+	return status
+}
+
+// startupHandler passes while the node is reachable and either ready or still
+// syncing, and always once the node has been ready
+func startupHandler(w http.ResponseWriter, r *http.Request) {
+	// A healthy result marks the node as ready before the status is read
+	nodeResult(r.Context(), ethNode)
+
+	status := ethNode.startup.status(settings().GetDuration("startup-stall-timeout"))
+	if status.Started {
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+
+	log.Warn().Str("reason", status.Reason).Msg("Node has not started")
+	writeJSON(w, http.StatusServiceUnavailable, status)
+}
+
+// syncRateSmoothing is the weight of the newest sample in the moving averages
+// of the sync rate
+const syncRateSmoothing = 0.3
+
+// SyncProgress is the sync progress of a node that is catching up
+type SyncProgress struct {
+	Current uint64  `json:"current"`
+	Highest uint64  `json:"highest"`
+	Rate    float64 `json:"rate_bps"`
+	ETA     string  `json:"eta,omitempty"`
+}
+
+// syncRateTracker samples eth_syncing across polls to estimate how fast a
+// node is syncing. The highest block keeps moving while the node catches up,
+// so the ETA uses the rate at which the remaining distance shrinks.
+type syncRateTracker struct {
+	mu          sync.Mutex
+	sampled     bool
+	current     uint64
+	highest     uint64
+	sampledAt   time.Time
+	rate        float64
+	highestRate float64
+}
+
+// observe records the sync status of m, returning nil and forgetting past
+// samples when the node is not syncing
+func (s *syncRateTracker) observe(m *health.Client) *SyncProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := m.SyncStatus
+	if status == nil || !status.Syncing || m.Errors["syncing"] != nil {
+		s.sampled = false
+		return nil
+	}
+
+	now := time.Now()
+	// A current block below the last sample means the node restarted its
+	// sync, so the samples are not comparable
+	if s.sampled && status.CurrentBlock >= s.current {
+		if elapsed := now.Sub(s.sampledAt).Seconds(); elapsed > 0 {
+			rate := float64(status.CurrentBlock-s.current) / elapsed
+			highestRate := 0.0
+			if status.HighestBlock > s.highest {
+				highestRate = float64(status.HighestBlock-s.highest) / elapsed
+			}
+			s.rate = ewma(s.rate, rate)
+			s.highestRate = ewma(s.highestRate, highestRate)
+		}
+	} else {
+		s.rate, s.highestRate = 0, 0
+	}
+	s.sampled = true
+	s.current, s.highest, s.sampledAt = status.CurrentBlock, status.HighestBlock, now
+
+	progress := &SyncProgress{Current: status.CurrentBlock, Highest: status.HighestBlock, Rate: s.rate}
+	if closing := s.rate - s.highestRate; closing > 0 && status.HighestBlock > status.CurrentBlock {
+		remaining := float64(status.HighestBlock - status.CurrentBlock)
+		progress.ETA = formatETA(time.Duration(remaining / closing * float64(time.Second)))
+	}
+	return progress
+}
+
+// ewma folds sample into the moving average, starting from the first sample
+func ewma(average, sample float64) float64 {
+	if average == 0 {
+		return sample
+	}
+	return syncRateSmoothing*sample + (1-syncRateSmoothing)*average
+}
+
+// formatETA rounds eta to minutes, or seconds under a minute, e.g. 2h14m
+func formatETA(eta time.Duration) string {
+	if eta < time.Minute {
+		return eta.Round(time.Second).String()
+	}
+	return strings.TrimSuffix(eta.Round(time.Minute).String(), "0s")
+}
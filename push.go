@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/model"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/spf13/viper"
+)
+
+// pushGrouping returns the grouping key of pushed metrics: the instance plus
+// every push-grouping label, so nodes pushing to one gateway keep their own
+// series
+func pushGrouping(v *viper.Viper) (map[string]string, error) {
+	instance := v.GetString("push-instance")
+	if instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to default the push instance to the hostname: %w", err)
+		}
+		instance = hostname
+	}
+
+	grouping := map[string]string{"instance": instance}
+	for _, label := range v.GetStringSlice("push-grouping") {
+		name, value, ok := strings.Cut(label, "=")
+		if !ok || !model.LabelName(name).IsValid() || name == "job" {
+			return nil, fmt.Errorf("invalid push-grouping %q, expected label=value", label)
+		}
+		grouping[name] = value
+	}
+	return grouping, nil
+}
+
+// pushMetrics pushes every metric /metrics would expose to the Pushgateway,
+// replacing the metrics of the same job and grouping key
+func pushMetrics(ctx context.Context) error {
+	grouping, err := pushGrouping(settings())
+	if err != nil {
+		return err
+	}
+
+	pusher := push.New(settings().GetString("pushgateway-url"), settings().GetString("push-job")).
+		Gatherer(prometheus.DefaultGatherer)
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, settings().GetDuration("check-timeout"))
+	defer cancel()
+	if err := pusher.PushContext(ctx); err != nil {
+		return clients.WithoutURL(err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+)
+
+// referenceEndpoint strips everything but the host from a reference URL
+func referenceEndpoint(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "invalid"
+	}
+	return u.Host
+}
+
+// measureReferences fetches the head of every reference endpoint concurrently
+func measureReferences(ctx context.Context, urls []string) []health.ReferenceHeight {
+	heights := make([]health.ReferenceHeight, len(urls))
+
+	var wg sync.WaitGroup
+	for i, referenceURL := range urls {
+		wg.Add(1)
+		go func(i int, referenceURL string) {
+			defer wg.Done()
+
+			start := time.Now()
+			number, err := clients.ReferenceBlockNumber(ctx, referenceURL)
+			err = clients.WithoutURL(err)
+			height := health.ReferenceHeight{
+				Endpoint:    referenceEndpoint(referenceURL),
+				BlockNumber: number,
+				Latency:     float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				log.Warn().Err(err).Str("endpoint", height.Endpoint).Msg("Failed to query the reference endpoint")
+				height.Error = err.Error()
+			}
+			heights[i] = height
+		}(i, referenceURL)
+	}
+	wg.Wait()
+
+	return heights
+}
+
+// startReferences queries the reference endpoints in the background so they
+// do not delay the local checks. The result is nil without references.
+func startReferences(ctx context.Context) <-chan []health.ReferenceHeight {
+	urls := settings().GetStringSlice("reference-url")
+	if len(urls) == 0 || !checkEnabled("reference") {
+		return nil
+	}
+
+	heights := make(chan []health.ReferenceHeight, 1)
+	go func() {
+		heights <- measureReferences(ctx, urls)
+	}()
+	return heights
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// restartOnlySettings are only read at startup, so a reload that changes
+// them is rejected rather than silently ignored
+var restartOnlySettings = map[string]bool{
+	"config":                   true,
+	"log-format":               true,
+	"listen-addr":              true,
+	"eth-url":                  true,
+	"target":                   true,
+	"client-type":              true,
+	"profile":                  true,
+	"el-p2p-restricted":        true,
+	"state-file":               true,
+	"event-log-file":           true,
+	"state-output-file":        true,
+	"healthy-touch-file":       true,
+	"event-log-size":           true,
+	"poll-interval":            true,
+	"client-detect-interval":   true,
+	"subscribe":                true,
+	"subscription-timeout":     true,
+	"rpc-header":               true,
+	"rpc-basic-auth":           true,
+	"rpc-bearer-token":         true,
+	"retry-max":                true,
+	"retry-wait-min":           true,
+	"retry-wait-max":           true,
+	"expected-genesis-hash":    true,
+	"verify-block":             true,
+	"admin-token":              true,
+	"admin-endpoints":          true,
+	"heartbeat-url":            true,
+	"on-healthy-cmd":           true,
+	"on-unhealthy-cmd":         true,
+	"hook-shell":               true,
+	"otel-endpoint":            true,
+	"enable-pprof":             true,
+	"debug-addr":               true,
+	"grpc-addr":                true,
+	"tcp-ready-addr":           true,
+	"proxy-listen":             true,
+	"fallback-url":             true,
+	"proxy-failover-dry-run":   true,
+	"graphql-url":              true,
+	"ws-url":                   true,
+	"rollup-url":               true,
+	"ws-head-timeout":          true,
+	"k8s-update":               true,
+	"k8s-label":                true,
+	"k8s-condition-type":       true,
+	"k8s-pod-name":             true,
+	"k8s-pod-namespace":        true,
+	"rpc-proxy-url":            true,
+	"rpc-ca-file":              true,
+	"rpc-client-cert":          true,
+	"rpc-client-key":           true,
+	"rpc-insecure-skip-verify": true,
+	"allow-cidr":               true,
+	"trust-proxy-headers":      true,
+	"status-token":             true,
+	"tls-cert":                 true,
+	"tls-key":                  true,
+	"tls-client-ca":            true,
+	"otel-sample-ratio":        true,
+	"heartbeat-interval":       true,
+	"one-shot":                 true,
+	"wait-for-node":            true,
+	"startup-timeout":          true,
+	"fail-on-startup":          true,
+}
+
+// fileChangeDebounce merges the bursts of events editors and ConfigMap
+// updates produce into one reload
+const fileChangeDebounce = 500 * time.Millisecond
+
+// reloadMu serializes reloads
+var reloadMu sync.Mutex
+
+// currentSettings is the configuration in effect. A reload validates a new
+// instance and swaps it in whole, so an instance is never written once the
+// poller and the handlers can read it.
+var currentSettings atomic.Pointer[viper.Viper]
+
+// settings returns the configuration in effect, which must not be modified
+// after startup
+func settings() *viper.Viper {
+	return currentSettings.Load()
+}
+
+// newSettings returns a viper instance with the same flags and environment
+// bindings as the global one
+func newSettings() *viper.Viper {
+	v := viper.New()
+	v.BindPFlags(pflag.CommandLine)
+	configureEnv(v)
+	return v
+}
+
+// reloadConfig validates the config file as a whole and only then swaps it
+// in. Invalid files and changes to settings that are only read at startup
+// leave the running configuration untouched.
+func reloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	path := settings().GetString("config")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	file, err := parseConfigFile(path, data)
+	if err != nil {
+		return err
+	}
+
+	candidate := newSettings()
+	if err := candidate.MergeConfigMap(file.AllSettings()); err != nil {
+		return err
+	}
+	if err := applyProfile(candidate); err != nil {
+		return err
+	}
+	if err := validateConfig(candidate); err != nil {
+		return err
+	}
+
+	running := settings()
+	changed := changedSettings(running, candidate)
+	var restartOnly []string
+	for _, key := range changed {
+		if restartOnlySettings[key] {
+			restartOnly = append(restartOnly, key)
+		}
+	}
+	if len(restartOnly) != 0 {
+		return fmt.Errorf("settings %s can only be changed with a restart", strings.Join(restartOnly, ", "))
+	}
+	if len(changed) == 0 {
+		log.Info().Msg("Config file reloaded without changes")
+		return nil
+	}
+
+	event := log.Info()
+	for _, key := range changed {
+		event = event.Str(key, fmt.Sprintf("%v -> %v", redactSetting(key, running.Get(key)), redactSetting(key, candidate.Get(key))))
+	}
+
+	currentSettings.Store(candidate)
+	if err := configureLogLevel(); err != nil {
+		return err
+	}
+	checks, _ := selectChecks(settings().GetString("checks"))
+	setEnabledChecks(checks)
+	windows, _ := parseMaintenanceWindows(settings())
+	setMaintenanceWindows(windows)
+
+	event.Strs("changed", changed).Msg("Config file reloaded")
+	return nil
+}
+
+// changedSettings returns the settings whose value differs between from and to
+func changedSettings(from, to *viper.Viper) []string {
+	var changed []string
+	pflag.VisitAll(func(flag *pflag.Flag) {
+		if fmt.Sprint(from.Get(flag.Name)) != fmt.Sprint(to.Get(flag.Name)) {
+			changed = append(changed, flag.Name)
+		}
+	})
+	return changed
+}
+
+// logReload reloads the config file and logs why a reload was rejected
+func logReload(trigger string) {
+	if err := reloadConfig(); err != nil {
+		log.Error().Err(err).Str("trigger", trigger).Msg("Rejected config file reload, keeping the running configuration")
+	}
+}
+
+// watchConfig reloads the config file on SIGHUP and, with watch-config, when
+// the file changes
+func watchConfig() error {
+	path := settings().GetString("config")
+	if path == "" {
+		return nil
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			logReload("sighup")
+		}
+	}()
+
+	if !settings().GetBool("watch-config") {
+		return nil
+	}
+
+	if err := watchFiles([]string{path}, func() { logReload("file_change") }); err != nil {
+		return err
+	}
+
+	log.Info().Str("config", path).Msg("Watching the config file for changes")
+	return nil
+}
+
+// watchFiles calls onChange, debounced, whenever one of paths changes. The
+// directories are watched since editors, Kubernetes ConfigMaps and
+// cert-manager replace files instead of writing to them, but changes to the
+// other files in them are ignored.
+func watchFiles(paths []string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	watched := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, path := range paths {
+		watched[filepath.Clean(path)] = true
+		dir := filepath.Dir(path)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		var debounce *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Chmod) || !watchedChange(watched, event.Name) {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(fileChangeDebounce, onChange)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				if !errors.Is(err, fsnotify.ErrEventOverflow) {
+					log.Error().Err(err).Msg("File watcher failed")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// watchedChange reports whether an event on name changes one of the watched
+// files. Kubernetes updates ConfigMaps and Secrets by swapping the ..data
+// symlink the files point through, so that counts as a change of all of them.
+func watchedChange(watched map[string]bool, name string) bool {
+	name = filepath.Clean(name)
+	return watched[name] || filepath.Base(name) == "..data"
+}
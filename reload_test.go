@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "medic.yaml")
+	t.Setenv("MEDIC_CONFIG", path)
+
+	write := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := settings()
+	t.Cleanup(func() { currentSettings.Store(previous) })
+
+	running := newSettings()
+	if err := running.MergeConfigMap(map[string]interface{}{"min-peers": 4}); err != nil {
+		t.Fatal(err)
+	}
+	currentSettings.Store(running)
+
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  bool
+		want     int
+	}{
+		{name: "valid change", contents: "min-peers: 5\n", want: 5},
+		{name: "unchanged", contents: "min-peers: 5\n", want: 5},
+		{name: "restart-only setting", contents: "min-peers: 6\nlisten-addr: \":9999\"\n", wantErr: true, want: 5},
+		{name: "invalid value", contents: "min-peers: many\n", wantErr: true, want: 5},
+		{name: "unparsable file", contents: "min-peers: [\n", wantErr: true, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write(tt.contents)
+			before := settings()
+
+			err := reloadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reloadConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && settings() != before {
+				t.Error("rejected reload replaced the settings")
+			}
+			if got := settings().GetInt("min-peers"); got != tt.want {
+				t.Errorf("min-peers = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Readers holding the previous settings keep seeing them unchanged
+	if got := running.GetInt("min-peers"); got != 4 {
+		t.Errorf("min-peers of the replaced settings = %d, want 4", got)
+	}
+}
+
+// Only the watched files and the ConfigMap ..data swap trigger a change, not
+// the other files written to the same directory
+func TestWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "medic.yaml")
+	if err := os.WriteFile(path, []byte("min-peers: 4\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{}, 10)
+	if err := watchFiles([]string{path}, func() { changes <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(name string, want bool) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-changes:
+			if !want {
+				t.Errorf("writing %s triggered a change", name)
+			}
+		case <-time.After(3 * fileChangeDebounce):
+			if want {
+				t.Errorf("writing %s did not trigger a change", name)
+			}
+		}
+	}
+	expect("state.json", false)
+	expect("healthy", false)
+	expect("medic.yaml", true)
+	expect("..data", true)
+}
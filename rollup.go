@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+)
+
+// rollupBlocksBehind is how many L2 block times the head may be old by
+// default when rollup-url is set, matching the defaults of OP Mainnet and
+// Base
+const rollupBlocksBehind = 3
+
+var (
+	rollupConfigMu sync.Mutex
+	rollupConfig   *clients.RollupConfig
+)
+
+// rollupConfigInfo returns the rollup config of the op-node. It is fetched
+// once and retried on the next call after a failure.
+func rollupConfigInfo(ctx context.Context, url string) (*clients.RollupConfig, error) {
+	rollupConfigMu.Lock()
+	defer rollupConfigMu.Unlock()
+
+	if rollupConfig == nil {
+		start := time.Now()
+		config, err := clients.OptimismRollupConfig(ctx, url)
+		observeRPC(ctx, "optimism_rollupConfig", start)
+		if err != nil {
+			return nil, err
+		}
+		rollupConfig = config
+	}
+	return rollupConfig, nil
+}
+
+// measureRollup calls optimism_syncStatus on the op-node at url
+func measureRollup(ctx context.Context, url string) *health.Rollup {
+	m := &health.Rollup{}
+
+	start := time.Now()
+	m.Status, m.Err = clients.OptimismSyncStatus(ctx, url)
+	observeRPC(ctx, "optimism_syncStatus", start)
+	if m.Err != nil {
+		log.Error().Err(clients.WithoutURL(m.Err)).Msg("Failed to retrieve the op-node sync status")
+		return m
+	}
+
+	var err error
+	if m.Config, err = rollupConfigInfo(ctx, url); err != nil {
+		log.Debug().Err(clients.WithoutURL(err)).Msg("Failed to retrieve the rollup config")
+	}
+	return m
+}
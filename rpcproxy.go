@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+)
+
+// maxInspectedBody bounds the request bodies read to find the methods of a
+// request while the node is unhealthy
+const maxInspectedBody = 1 << 20
+
+var (
+	proxyRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medic_proxy_requests_total",
+		Help: "Number of requests to the RPC proxy, by upstream host and outcome: proxied, rejected or error",
+	}, []string{"upstream", "outcome"})
+
+	proxyDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "medic_proxy_request_duration_seconds",
+		Help:    "Latency of the requests the RPC proxy forwarded, by upstream host",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream"})
+
+	failoversCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medic_proxy_switchovers_total",
+		Help: "Number of times the RPC proxy switched upstream, by the upstream switched to: fallback or primary",
+	}, []string{"to"})
+)
+
+// gatedProxy forwards JSON-RPC traffic to the node while the cached health
+// result passes, and to the fallback while failed over
+type gatedProxy struct {
+	primary  *upstream
+	fallback *upstream
+}
+
+// upstream is an endpoint the proxy forwards to
+type upstream struct {
+	host  string
+	proxy *httputil.ReverseProxy
+}
+
+// newUpstream returns a proxy to target through transport. Credentials in
+// target are sent as basic auth unless the client sent its own.
+func newUpstream(target *url.URL, transport http.RoundTripper) *upstream {
+	u := &upstream{host: target.Host}
+	user := target.User
+	target = &url.URL{Scheme: target.Scheme, Host: target.Host, Path: target.Path, RawQuery: target.RawQuery}
+
+	u.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.Out.Host = target.Host
+			if user != nil && r.Out.Header.Get("Authorization") == "" {
+				password, _ := user.Password()
+				r.Out.SetBasicAuth(user.Username(), password)
+			}
+		},
+		Transport: transport,
+		ModifyResponse: func(*http.Response) error {
+			proxyRequestsCounter.WithLabelValues(u.host, "proxied").Inc()
+			return nil
+		},
+		// Flush every write so subscriptions and large responses stream
+		FlushInterval: -1,
+		ErrorHandler:  u.upstreamError,
+	}
+	return u
+}
+
+// newGatedProxy returns a proxy to node and to fallback-url. Requests to the
+// node carry the same headers, credentials, TLS and proxy settings as those
+// of the health checks.
+func newGatedProxy(node *nodeClient) *gatedProxy {
+	// validateConfig has already checked the URLs, headers and the TLS and
+	// proxy settings
+	target, _ := url.Parse(clients.HTTPURL(node.url))
+	target.User = node.credentials
+	headers, _ := rpcHeaders(settings())
+	tlsConfig, _ := rpcTLSConfig(settings())
+	upstreamProxy, _ := rpcProxy(settings())
+
+	p := &gatedProxy{primary: newUpstream(target, rpcTransport(headers, tlsConfig, upstreamProxy))}
+	if fallbackURL := settings().GetString("fallback-url"); fallbackURL != "" {
+		// The headers and TLS settings of the node do not apply to the
+		// fallback, which is usually a hosted provider
+		fallbackTransport := http.DefaultTransport.(*http.Transport).Clone()
+		fallbackTransport.Proxy = upstreamProxy
+		fallback, _ := url.Parse(fallbackURL)
+		p.fallback = newUpstream(fallback, fallbackTransport)
+	}
+	return p
+}
+
+// startRPCProxy serves the RPC proxy on proxy-listen when set, returning nil
+// otherwise
+func startRPCProxy() *http.Server {
+	addr := settings().GetString("proxy-listen")
+	if addr == "" {
+		return nil
+	}
+
+	p := newGatedProxy(ethNode)
+	server := &http.Server{Addr: addr, Handler: p}
+	listener := listen(addr)
+	go func() {
+		event := log.Info().Str("proxy_listen", listener.Addr().String()).Str("upstream", p.primary.host)
+		if p.fallback != nil {
+			event = event.Str("fallback", p.fallback.host)
+		}
+		event.Msg("RPC proxy listening")
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("Failed to serve the RPC proxy")
+		}
+	}()
+	return server
+}
+
+// ServeHTTP picks the upstream when the request arrives, so a request in
+// flight during a switchover completes against the upstream it started with
+func (p *gatedProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.fallback != nil && proxyFailover.active() {
+		p.fallback.serve(w, r)
+		return
+	}
+
+	if healthy, reasons := proxyHealth(r.Context()); !healthy {
+		// WebSocket frames are not inspected, so only plain requests for
+		// the allowed methods get through
+		if isUpgrade(r) || !allowedWhileUnhealthy(r) {
+			proxyRequestsCounter.WithLabelValues(p.primary.host, "rejected").Inc()
+			writeRPCError(w, http.StatusServiceUnavailable, "node is unhealthy: "+strings.Join(reasons, ", "))
+			return
+		}
+	}
+	p.primary.serve(w, r)
+}
+
+func (u *upstream) serve(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	u.proxy.ServeHTTP(w, r)
+	proxyDurationHistogram.WithLabelValues(u.host).Observe(time.Since(start).Seconds())
+}
+
+// proxyHealth reports whether the cached health result passes, with the
+// reasons when it does not. It never queries the node.
+func proxyHealth(ctx context.Context) (bool, []string) {
+	return readiness(nodeResult(ctx, ethNode))
+}
+
+// failover tracks whether the proxy routes to the fallback. It fails over as
+// soon as readiness fails and fails back after failback-after consecutive
+// healthy evaluations. In dry run it only logs the switchovers.
+type failover struct {
+	dryRun bool
+
+	mu         sync.Mutex
+	failedOver bool
+}
+
+// proxyFailover is set when the proxy has a fallback
+var proxyFailover *failover
+
+func newFailover() *failover {
+	if settings().GetString("proxy-listen") == "" || settings().GetString("fallback-url") == "" {
+		return nil
+	}
+	return &failover{dryRun: settings().GetBool("proxy-failover-dry-run")}
+}
+
+// observe updates the routing after an evaluation of the primary
+func (f *failover) observe(result HealthResult) {
+	ready, reasons := readiness(result)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case !f.failedOver && !ready:
+		f.failedOver = true
+		if f.dryRun {
+			log.Warn().Strs("reasons", reasons).Msg("Would fail over the RPC proxy to the fallback (dry run)")
+			return
+		}
+		failoversCounter.WithLabelValues("fallback").Inc()
+		log.Warn().Strs("reasons", reasons).Msg("Primary is unhealthy, failing over the RPC proxy to the fallback")
+	case f.failedOver && ready && (result.SuccessStreak >= settings().GetInt("failback-after") || result.ForcedReady):
+		f.failedOver = false
+		if f.dryRun {
+			log.Warn().Int("success_streak", result.SuccessStreak).Msg("Would fail back the RPC proxy to the primary (dry run)")
+			return
+		}
+		failoversCounter.WithLabelValues("primary").Inc()
+		log.Warn().Int("success_streak", result.SuccessStreak).Msg("Primary is healthy again, failing back the RPC proxy")
+	}
+}
+
+// active reports whether requests go to the fallback
+func (f *failover) active() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failedOver && !f.dryRun
+}
+
+// isUpgrade reports whether r asks to upgrade the connection, e.g. to a
+// WebSocket
+func isUpgrade(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" && strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// rpcCall is the part of a JSON-RPC call the proxy inspects
+type rpcCall struct {
+	Method string `json:"method"`
+}
+
+// allowedWhileUnhealthy reports whether every call in the body of r is to a
+// method of proxy-allow-unhealthy-methods. The body is restored for
+// forwarding.
+func allowedWhileUnhealthy(r *http.Request) bool {
+	allowed := settings().GetStringSlice("proxy-allow-unhealthy-methods")
+	if len(allowed) == 0 || r.Body == nil {
+		return false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxInspectedBody+1))
+	if err != nil || len(body) > maxInspectedBody {
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var calls []rpcCall
+	if trimmed := bytes.TrimSpace(body); len(trimmed) != 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &calls)
+	} else {
+		var call rpcCall
+		err = json.Unmarshal(trimmed, &call)
+		calls = []rpcCall{call}
+	}
+	if err != nil || len(calls) == 0 {
+		return false
+	}
+
+	for _, call := range calls {
+		if !slices.Contains(allowed, call.Method) {
+			return false
+		}
+	}
+	return true
+}
+
+// upstreamError answers requests the node could not be reached for with 502
+func (u *upstream) upstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	proxyRequestsCounter.WithLabelValues(u.host, "error").Inc()
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	log.Warn().Err(clients.WithoutURL(err)).Str("upstream", u.host).Msg("Failed to proxy a request")
+	writeRPCError(w, http.StatusBadGateway, "upstream is unreachable")
+}
+
+// writeRPCError answers with a JSON-RPC error, which clients surface better
+// than a plain HTTP error
+func writeRPCError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error":   map[string]interface{}{"code": -32000, "message": message},
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rarecrumb/medic/clients"
+)
+
+// The proxy authenticates to the node like the health checks do, with the
+// configured headers and the credentials of the node URL
+func TestRPCProxyAuthenticatesUpstream(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    map[string]interface{}
+		credentials string
+		header      string
+		want        string
+	}{
+		{
+			name:     "custom header",
+			settings: map[string]interface{}{"rpc-header": []string{"X-Api-Key=secret"}},
+			header:   "X-Api-Key",
+			want:     "secret",
+		},
+		{
+			name:     "bearer token",
+			settings: map[string]interface{}{"rpc-bearer-token": "token"},
+			header:   "Authorization",
+			want:     "Bearer token",
+		},
+		{
+			name:     "basic auth",
+			settings: map[string]interface{}{"rpc-basic-auth": "medic:secret"},
+			header:   "Authorization",
+			want:     clients.BasicAuth("medic", "secret"),
+		},
+		{
+			name:        "credentials in the url",
+			settings:    map[string]interface{}{},
+			credentials: "medic:secret@",
+			header:      "Authorization",
+			want:        clients.BasicAuth("medic", "secret"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := stubNode(gethStub)
+			node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(tt.header) != tt.want {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				stub.ServeHTTP(w, r)
+			}))
+			defer node.Close()
+
+			useSettings(t, tt.settings)
+			p := newGatedProxy(newNodeClient("", strings.Replace(node.URL, "://", "://"+tt.credentials, 1)))
+			proxy := httptest.NewServer(http.HandlerFunc(p.primary.serve))
+			defer proxy.Close()
+
+			resp, err := http.Post(proxy.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("proxied request status = %d, want 200", resp.StatusCode)
+			}
+		})
+	}
+}
+
+// Switchovers are only counted when traffic actually moves, not in dry run
+func TestFailoverSwitchovers(t *testing.T) {
+	useSettings(t, map[string]interface{}{"failback-after": 2})
+
+	var unhealthy, healthy HealthResult
+	unhealthy.Reasons = []string{"block_delta_exceeded"}
+	healthy.Healthy = true
+	healthy.SuccessStreak = 2
+
+	for _, dryRun := range []bool{false, true} {
+		fallback := testutil.ToFloat64(failoversCounter.WithLabelValues("fallback"))
+		primary := testutil.ToFloat64(failoversCounter.WithLabelValues("primary"))
+
+		f := &failover{dryRun: dryRun}
+		f.observe(unhealthy)
+		if f.active() == dryRun {
+			t.Errorf("dry run %v: active = %v after failing over", dryRun, f.active())
+		}
+		f.observe(healthy)
+		if f.active() {
+			t.Errorf("dry run %v: still active after failing back", dryRun)
+		}
+
+		want := 1.0
+		if dryRun {
+			want = 0
+		}
+		if got := testutil.ToFloat64(failoversCounter.WithLabelValues("fallback")) - fallback; got != want {
+			t.Errorf("dry run %v: %v switchovers to the fallback counted, want %v", dryRun, got, want)
+		}
+		if got := testutil.ToFloat64(failoversCounter.WithLabelValues("primary")) - primary; got != want {
+			t.Errorf("dry run %v: %v switchovers to the primary counted, want %v", dryRun, got, want)
+		}
+	}
+}
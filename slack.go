@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+)
+
+// slackQueueSize bounds the messages waiting to be posted. Messages beyond
+// it are dropped so a slow webhook never holds up the health checks.
+const slackQueueSize = 32
+
+// slackAlert is the outage of one node as seen by Slack
+type slackAlert struct {
+	since      time.Time
+	posted     bool
+	escalation *time.Timer
+}
+
+var (
+	slackMu       sync.Mutex
+	slackAlerts   = map[string]*slackAlert{}
+	slackLastPost = map[string]time.Time{}
+
+	slackOnce  sync.Once
+	slackQueue chan slackMessage
+	slackHTTP  = &http.Client{Timeout: 10 * time.Second}
+)
+
+// slackMessage is the payload posted to the incoming webhook
+type slackMessage struct {
+	Channel string `json:"channel,omitempty"`
+	Text    string `json:"text"`
+}
+
+// notifySlack posts a message when a node transitions between healthy and
+// unhealthy. Unhealthy transitions within slack-min-interval of the last
+// message about the node are not posted, and neither is the matching
+// recovery, so a flapping node does not flood the channel.
+func notifySlack(node *nodeClient, result HealthResult) {
+	if settings().GetString("slack-webhook-url") == "" {
+		return
+	}
+	name := nodeDisplayName(node)
+	if windows := activeWindows(); len(windows) != 0 {
+		log.Info().Str("node", name).Str("window", windows[0].Name).Msg("Not posting to Slack during a maintenance window")
+		return
+	}
+
+	slackMu.Lock()
+	defer slackMu.Unlock()
+
+	if !result.Healthy {
+		alert := &slackAlert{since: time.Now()}
+		slackAlerts[name] = alert
+		if time.Since(slackLastPost[name]) < settings().GetDuration("slack-min-interval") {
+			log.Info().Str("node", name).Msg("Not posting the unhealthy node to Slack, a message was posted recently")
+			return
+		}
+		alert.posted = true
+		slackLastPost[name] = alert.since
+		postSlack(fmt.Sprintf(":red_circle: *%s is unhealthy*\n%s", name, slackDetails(result)))
+
+		if after := settings().GetDuration("slack-escalate-after"); after > 0 {
+			alert.escalation = time.AfterFunc(after, func() { escalateSlack(name, alert, result) })
+		}
+		return
+	}
+
+	alert := slackAlerts[name]
+	delete(slackAlerts, name)
+	if alert == nil || !alert.posted {
+		return
+	}
+	if alert.escalation != nil {
+		alert.escalation.Stop()
+	}
+	slackLastPost[name] = time.Now()
+	postSlack(fmt.Sprintf(":large_green_circle: *%s recovered* after %s", name, time.Since(alert.since).Round(time.Second)))
+}
+
+// escalateSlack mentions slack-mention when the outage is still ongoing
+func escalateSlack(name string, alert *slackAlert, result HealthResult) {
+	slackMu.Lock()
+	defer slackMu.Unlock()
+
+	if slackAlerts[name] != alert {
+		return
+	}
+	mention := settings().GetString("slack-mention")
+	if mention != "" {
+		mention = slackMention(mention) + " "
+	}
+	postSlack(fmt.Sprintf(":rotating_light: %s*%s has been unhealthy for %s*\n%s", mention, name, time.Since(alert.since).Round(time.Second), slackDetails(result)))
+}
+
+// slackDetails formats the failure reasons and the key values of result
+func slackDetails(result HealthResult) string {
+	var details strings.Builder
+	fmt.Fprintf(&details, "Reasons: %s\n", strings.Join(result.Reasons, ", "))
+	fmt.Fprintf(&details, "Block delta: %ds, peers: %d", result.intValue("block_delta"), result.intValue("peers"))
+	if externalURL := settings().GetString("external-url"); externalURL != "" {
+		fmt.Fprintf(&details, "\n<%s/status|Status>", strings.TrimSuffix(externalURL, "/"))
+	}
+	return details.String()
+}
+
+// slackMention turns @here, @channel and @everyone into Slack's special
+// mentions and passes anything else, such as <@U123> or <!subteam^S123>,
+// through as-is
+func slackMention(mention string) string {
+	switch name := strings.TrimPrefix(mention, "@"); name {
+	case "here", "channel", "everyone":
+		return "<!" + name + ">"
+	}
+	return mention
+}
+
+// nodeDisplayName names a node in notifications: the target name, or the
+// host medic runs on for the node configured by eth-url
+func nodeDisplayName(node *nodeClient) string {
+	if node.name != "" {
+		return node.name
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return "eth-url"
+}
+
+// postSlack queues text for the webhook, dropping it when the queue is full
+func postSlack(text string) {
+	slackOnce.Do(func() {
+		slackQueue = make(chan slackMessage, slackQueueSize)
+		go sendSlack()
+	})
+
+	select {
+	case slackQueue <- slackMessage{Channel: settings().GetString("slack-channel"), Text: text}:
+	default:
+		log.Warn().Msg("Dropping a Slack message, too many messages are waiting")
+	}
+}
+
+// sendSlack posts the queued messages one at a time
+func sendSlack() {
+	for message := range slackQueue {
+		body, err := json.Marshal(message)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode the Slack message")
+			continue
+		}
+
+		resp, err := slackHTTP.Post(settings().GetString("slack-webhook-url"), "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Error().Err(clients.WithoutURL(err)).Msg("Failed to post to Slack")
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Error().Int("status", resp.StatusCode).Msg("Slack rejected the message")
+		}
+	}
+}
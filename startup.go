@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+)
+
+// rpcTransport returns a transport to the node that adds headers to every
+// request and goes through proxy. A nil tlsConfig keeps the default
+// verification.
+func rpcTransport(headers http.Header, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &clients.TracingTransport{
+		Base: &clients.HeaderTransport{
+			Base:    &clients.BasicAuthTransport{Base: transport},
+			Headers: headers,
+		},
+	}
+}
+
+// newRPCClient builds the HTTP client of the health checks. It does not
+// retry, so an unreachable node fails the evaluation at once instead of
+// running into check-timeout.
+func newRPCClient(headers http.Header, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) *http.Client {
+	return &http.Client{
+		Transport: rpcTransport(headers, tlsConfig, proxy),
+		Timeout:   clients.DefaultTimeout,
+	}
+}
+
+// newRetryClient builds the retrying HTTP client of the startup wait from the
+// retry flags, with the transport of rpcTransport
+func newRetryClient(headers http.Header, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) *retryablehttp.Client {
+	retryClient := retryablehttp.NewClient()
+	retryClient.Logger = nil
+	retryClient.RetryMax = settings().GetInt("retry-max")
+	retryClient.RetryWaitMin = settings().GetDuration("retry-wait-min")
+	retryClient.RetryWaitMax = settings().GetDuration("retry-wait-max")
+	retryClient.CheckRetry = retryUnlessResponded
+	retryClient.HTTPClient = newRPCClient(headers, tlsConfig, proxy)
+
+	return retryClient
+}
+
+// retryUnlessResponded only retries requests that got no response at all.
+// Status codes such as Nethermind's 503 or a beacon node's 206 carry health
+// information and are returned to the caller as-is.
+func retryUnlessResponded(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if err == nil {
+		return false, nil
+	}
+	return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+}
+
+// awaitNode waits up to startup-timeout for node. A node that
+// stays unreachable is logged and left to the health checks, which report it
+// not ready, unless fail-on-startup is set, which returns the error instead.
+func awaitNode(retryClient *retryablehttp.Client, node *nodeClient) error {
+	startupTimeout := settings().GetDuration("startup-timeout")
+	log.Info().Dur("startup_timeout", startupTimeout).Msg("Waiting for the node to become reachable")
+
+	ctx, cancel := context.WithTimeout(node.context(context.Background()), startupTimeout)
+	defer cancel()
+	err := waitForNode(ctx, retryClient, node.url)
+	if err == nil {
+		return nil
+	}
+	if settings().GetBool("fail-on-startup") {
+		return err
+	}
+	log.Error().Err(err).Msg("Node did not become reachable, starting as not ready")
+	return nil
+}
+
+// waitForNode retries a web3_clientVersion request until the node answers or
+// ctx expires. A bare GET is avoided since many nodes reject it on the RPC port.
+func waitForNode(ctx context.Context, retryClient *retryablehttp.Client, url string) error {
+	if clients.IsPersistent(url) {
+		return waitForSocket(ctx, url)
+	}
+
+	payload := []byte(`{"jsonrpc":"2.0","method":"web3_clientVersion","params":[],"id":1}`)
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, url, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := retryClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// waitForSocket retries dialing a WebSocket or IPC endpoint until the node
+// answers or ctx expires, backing off between the retry wait bounds
+func waitForSocket(ctx context.Context, url string) error {
+	wait := settings().GetDuration("retry-wait-min")
+	for attempt := 0; ; attempt++ {
+		_, err := clients.NetVersion(ctx, url)
+		if err == nil || errors.Is(err, clients.ErrRPCError) {
+			return nil
+		}
+		if attempt >= settings().GetInt("retry-max") {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, settings().GetDuration("retry-wait-max"))
+	}
+}
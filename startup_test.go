@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/clients/clienttest"
+	"github.com/rarecrumb/medic/health"
+)
+
+// useSettings replaces the settings with the defaults and values for the
+// duration of the test
+func useSettings(t *testing.T, values map[string]interface{}) {
+	t.Helper()
+	previous := settings()
+	t.Cleanup(func() { currentSettings.Store(previous) })
+
+	v := newSettings()
+	if err := v.MergeConfigMap(values); err != nil {
+		t.Fatal(err)
+	}
+	currentSettings.Store(v)
+}
+
+// refusingURL returns the URL of a server that was closed again, so that
+// connections to it are refused
+func refusingURL() string {
+	server := httptest.NewServer(nil)
+	server.Close()
+	return server.URL
+}
+
+func TestAwaitNode(t *testing.T) {
+	node := clienttest.NewServer()
+	defer node.Close()
+
+	tests := []struct {
+		name          string
+		url           string
+		failOnStartup bool
+		wantErr       bool
+	}{
+		{name: "reachable", url: node.URL},
+		{name: "reachable with fail-on-startup", url: node.URL, failOnStartup: true},
+		{name: "refused", url: refusingURL()},
+		{name: "refused with fail-on-startup", url: refusingURL(), failOnStartup: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSettings(t, map[string]interface{}{
+				"startup-timeout": "5s",
+				"fail-on-startup": tt.failOnStartup,
+				"retry-max":       2,
+				"retry-wait-min":  "1ms",
+				"retry-wait-max":  "5ms",
+			})
+
+			start := time.Now()
+			err := awaitNode(newRetryClient(nil, nil, nil), newNodeClient("", tt.url))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("awaitNode() error = %v, want error %v", err, tt.wantErr)
+			}
+			// Refused connections are retried retry-max times rather than
+			// until the startup timeout
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("awaitNode() took %s", elapsed)
+			}
+		})
+	}
+}
+
+// A node that never became reachable is started as not ready
+func TestUnreachableNodeNotReady(t *testing.T) {
+	useSettings(t, map[string]interface{}{})
+
+	client := newNodeClient("", refusingURL())
+	client.forceClientType("Geth")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result := evaluate(ctx, measure(ctx, client), health.Thresholds{MaxBlockAge: 30 * time.Second})
+
+	if result.Healthy {
+		t.Fatal("unreachable node reported healthy")
+	}
+	if ready, _ := readiness(result); ready {
+		t.Error("unreachable node reported ready")
+	}
+	if !unreachable(result) {
+		t.Errorf("checks = %v, want the node reported unreachable", result.Checks)
+	}
+}
+
+// The health checks do not retry like the startup wait, so with the default
+// retry settings a refused connection fails the evaluation at once and is
+// not mistaken for a timeout
+func TestRefusedNodeFailsFast(t *testing.T) {
+	useSettings(t, map[string]interface{}{})
+	previous := rpcOptions
+	t.Cleanup(func() { rpcOptions = previous })
+	configureRPC()
+
+	client := newNodeClient("", refusingURL())
+	client.forceClientType("Geth")
+
+	start := time.Now()
+	result := nodeHealth(context.Background(), client)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("evaluation took %s, want it to fail fast", elapsed)
+	}
+	if result.Healthy || !unreachable(result) {
+		t.Errorf("checks = %v, want the node reported unreachable", result.Checks)
+	}
+	if slices.Contains(result.Reasons, "rpc_timeout") {
+		t.Errorf("reasons = %v, want a connection error rather than rpc_timeout", result.Reasons)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// headState persists the highest head seen per chain ID, so that a rollback
+// that happened while medic was down is still detected after a restart
+type headState struct {
+	mu      sync.Mutex
+	path    string
+	highest map[string]uint64
+}
+
+// stateFile is the on-disk format of the state file
+type stateFile struct {
+	HighestBlock map[string]uint64 `json:"highest_block"`
+}
+
+// persistedHeads is the state loaded from --state-file, nil when unset
+var persistedHeads *headState
+
+// loadHeadState reads the state file, starting empty when it does not exist
+func loadHeadState(path string) (*headState, error) {
+	state := &headState{path: path, highest: map[string]uint64{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.HighestBlock != nil {
+		state.highest = file.HighestBlock
+	}
+	return state, nil
+}
+
+// get returns the highest head recorded for chainID, or 0 if none
+func (s *headState) get(chainID uint64) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.highest[strconv.FormatUint(chainID, 10)]
+}
+
+// record stores number when it is the highest head seen for chainID. Write
+// failures are logged and otherwise ignored so health checking continues.
+func (s *headState) record(chainID, number uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strconv.FormatUint(chainID, 10)
+	if number <= s.highest[key] {
+		return
+	}
+	s.highest[key] = number
+
+	if err := s.save(); err != nil {
+		log.Error().Err(err).Str("state_file", s.path).Msg("Failed to write the state file")
+	}
+}
+
+// clear forgets every recorded head, e.g. after an operator has accepted a
+// rollback
+func (s *headState) clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.highest = map[string]uint64{}
+	return s.save()
+}
+
+// save writes the state to the state file
+func (s *headState) save() error {
+	data, err := json.Marshal(stateFile{HighestBlock: s.highest})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path,
+// so a crash never leaves a partially written file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// stateHandler clears the persisted heads on DELETE, for operators who have
+// verified that a node may legitimately serve a lower head
+func stateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := persistedHeads.clear(); err != nil {
+		log.Error().Err(err).Msg("Failed to clear the state file")
+		http.Error(w, "failed to clear the state file", http.StatusInternalServerError)
+		return
+	}
+	log.Warn().Str("state_file", persistedHeads.path).Msg("State file cleared by an operator")
+	w.WriteHeader(http.StatusNoContent)
+}
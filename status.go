@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+)
+
+// historySize is the number of evaluations kept for /status
+const historySize = 50
+
+// HistoryEntry summarizes one evaluation in the /status history
+type HistoryEntry struct {
+	Time        time.Time `json:"time"`
+	Healthy     bool      `json:"healthy"`
+	BlockNumber uint64    `json:"block_number"`
+	BlockDelta  int       `json:"block_delta"`
+	PeerCount   int       `json:"peer_count"`
+	Reasons     []string  `json:"reasons,omitempty"`
+}
+
+// healthHistory is a fixed-size ring buffer of recent evaluations
+type healthHistory struct {
+	mu      sync.RWMutex
+	entries []HistoryEntry
+	next    int
+}
+
+func newHealthHistory(size int) *healthHistory {
+	return &healthHistory{entries: make([]HistoryEntry, 0, size)}
+}
+
+// add records an evaluation, overwriting the oldest entry once full, and
+// returns the entry
+func (h *healthHistory) add(result HealthResult) HistoryEntry {
+	entry := HistoryEntry{
+		Time:        time.Now(),
+		Healthy:     result.Healthy,
+		BlockNumber: result.BlockNumber,
+		BlockDelta:  result.intValue("block_delta"),
+		PeerCount:   result.intValue("peers"),
+		Reasons:     result.Reasons,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.entries) < cap(h.entries) {
+		h.entries = append(h.entries, entry)
+	} else {
+		h.entries[h.next] = entry
+	}
+	h.next = (h.next + 1) % cap(h.entries)
+	return entry
+}
+
+// recent returns up to n entries, newest first
+func (h *healthHistory) recent(n int) []HistoryEntry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if n <= 0 || n > len(h.entries) {
+		n = len(h.entries)
+	}
+
+	entries := make([]HistoryEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		idx := (h.next - i + len(h.entries)) % len(h.entries)
+		entries = append(entries, h.entries[idx])
+	}
+	return entries
+}
+
+// StatusResponse is the body served by /status
+type StatusResponse struct {
+	Health  HealthResult       `json:"health"`
+	Client  clients.ClientInfo `json:"client"`
+	Checks  []string           `json:"enabled_checks"`
+	History []HistoryEntry     `json:"history"`
+
+	// MaxBlockAge is the threshold in effect and where it came from
+	MaxBlockAge Setting `json:"max_seconds_behind"`
+
+	// Profile is only set when a profile was selected
+	Profile string `json:"profile,omitempty"`
+
+	// Maintenance is only set while the node is in maintenance
+	Maintenance *Override `json:"maintenance,omitempty"`
+
+	// MaintenanceWindows are the scheduled windows in effect
+	MaintenanceWindows []ActiveWindow `json:"maintenance_windows,omitempty"`
+
+	// ForceReady is only set while readiness is forced to pass
+	ForceReady *Override `json:"force_ready,omitempty"`
+
+	// RPCLatency is the rpc-latency-percentile of every RPC method called
+	RPCLatency map[string]LatencySummary `json:"rpc_latency"`
+
+	// MinBlock is only set while a minimum block is configured
+	MinBlock *MinBlockStatus `json:"min_block,omitempty"`
+
+	// GracePeriod is only set while the initial grace period lasts
+	GracePeriod *GracePeriod `json:"grace_period,omitempty"`
+
+	// Connection is only set for WebSocket and IPC endpoints
+	Connection *clients.ConnectionState `json:"connection,omitempty"`
+
+	// WSConnection is only set once ws-url was dialed
+	WSConnection *clients.ConnectionState `json:"ws_connection,omitempty"`
+}
+
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	n, ok := historyLength(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nodeStatus(r.Context(), ethNode, n))
+}
+
+// historyLength parses the n query parameter, answering 400 when invalid
+func historyLength(w http.ResponseWriter, r *http.Request) (int, bool) {
+	raw := r.URL.Query().Get("n")
+	if raw == "" {
+		return historySize, true
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		http.Error(w, "n must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+// nodeStatus builds the status of node with up to n history entries
+func nodeStatus(ctx context.Context, node *nodeClient, n int) StatusResponse {
+	result := nodeResult(ctx, node)
+	return StatusResponse{
+		Health:      result,
+		Client:      node.clientInfo(),
+		Checks:      checkNames(activeChecks().Checks()),
+		MaxBlockAge: maxBlockAge(),
+		Profile:     settings().GetString("profile"),
+		History:     node.history.recent(n),
+		Connection:  clients.ConnectionStateFor(node.context(ctx), node.url),
+		Maintenance: activeOverride(maintenance),
+		ForceReady:  activeOverride(forceReady),
+		GracePeriod: node.grace.status(),
+		MinBlock:    minBlock.status(result),
+		RPCLatency:  node.latency.summaries(),
+
+		MaintenanceWindows: activeWindows(),
+		WSConnection:       clients.ConnectionStateFor(rpcContext(ctx), settings().GetString("ws-url")),
+	}
+}
+
+// activeOverride returns the override of s while it is enabled
+func activeOverride(s *overrideState) *Override {
+	if override := s.get(); override.Enabled {
+		return &override
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+// streamHeartbeat is the interval of the comments that keep idle streams
+// open through proxies
+const streamHeartbeat = 15 * time.Second
+
+// streamBuffer is the number of messages a stream client may fall behind
+// before it is dropped
+const streamBuffer = 32
+
+// streamMessage is a server-sent event with its data already encoded
+type streamMessage struct {
+	event      string
+	data       []byte
+	transition bool
+}
+
+// HealthUpdate is the data of the health events of /events/stream
+type HealthUpdate struct {
+	// Target is the name of the target, empty for the node of eth-url
+	Target string `json:"target,omitempty"`
+	HistoryEntry
+}
+
+// broadcaster fans the health updates out to the stream clients. Publishing
+// never blocks: a client whose buffer is full is dropped instead.
+type broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan streamMessage]bool
+	closed      bool
+}
+
+// updates is the broadcaster fed by every health evaluation
+var updates = &broadcaster{subscribers: map[chan streamMessage]bool{}}
+
+var streamClientsGauge = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Name: "medic_stream_clients",
+	Help: "Number of clients connected to /events/stream",
+}, func() float64 { return float64(updates.count()) })
+
+// subscribe returns a channel receiving every published message. It is
+// closed when the client is dropped or the broadcaster closes.
+func (b *broadcaster) subscribe() chan streamMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan streamMessage, streamBuffer)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers[ch] = true
+	return ch
+}
+
+// unsubscribe removes ch unless it was already dropped
+func (b *broadcaster) unsubscribe(ch chan streamMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[ch] {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends an event with data encoded as JSON to every subscriber
+func (b *broadcaster) publish(event string, data interface{}, transition bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subscribers) == 0 {
+		return
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Error().Err(err).Str("event", event).Msg("Failed to encode the stream event")
+		return
+	}
+	message := streamMessage{event: event, data: encoded, transition: transition}
+	for ch := range b.subscribers {
+		select {
+		case ch <- message:
+		default:
+			log.Warn().Msg("Dropping a stream client that fell behind")
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// close ends every stream, so that shutdown does not wait for them
+func (b *broadcaster) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (b *broadcaster) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// streamHandler serves /events/stream, sending a health event after every
+// evaluation and a transition event on every debounced transition, or only
+// the latter with transitions-only=true
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	transitionsOnly := r.URL.Query().Get("transitions-only") == "true"
+
+	messages := updates.subscribe()
+	defer updates.unsubscribe(messages)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keep nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			if transitionsOnly && !message.transition {
+				continue
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.event, message.data)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+)
+
+// maxResubscribeBackoff caps the delay between newHeads resubscriptions
+const maxResubscribeBackoff = 30 * time.Second
+
+// headSubscription holds the newest header pushed by a newHeads
+// subscription, so block delta can be computed without fetching blocks
+type headSubscription struct {
+	mu       sync.Mutex
+	head     pushedHead
+	received time.Time
+}
+
+// pushedHead is the part of a pushed header used by the health checks
+type pushedHead struct {
+	Number uint64
+	Hash   common.Hash
+	Time   uint64
+}
+
+// update records a header pushed by the subscription
+func (s *headSubscription) update(header *types.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.head = pushedHead{Number: header.Number.Uint64(), Hash: header.Hash(), Time: header.Time}
+	s.received = time.Now()
+}
+
+// clear forgets the last header once the subscription ends, so checks fall
+// back to fetching the latest block
+func (s *headSubscription) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = time.Time{}
+}
+
+// latest returns the newest pushed header if one arrived within timeout
+func (s *headSubscription) latest(timeout time.Duration) (pushedHead, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.received.IsZero() || time.Since(s.received) > timeout {
+		return pushedHead{}, false
+	}
+	return s.head, true
+}
+
+// startHeadSubscription follows newHeads on the node and resubscribes with
+// backoff when the subscription fails or stays silent for timeout. When cache
+// is set, every new header triggers a health evaluation.
+func startHeadSubscription(node *nodeClient, timeout time.Duration, cache *healthCache) {
+	log.Info().Dur("subscription_timeout", timeout).Msg("Subscribing to newHeads")
+
+	go func() {
+		backoff := time.Second
+		for {
+			received, err := followHeads(node, timeout, cache)
+			node.stream.clear()
+			if received {
+				backoff = time.Second
+			}
+			log.Warn().Err(err).Dur("retry_in", backoff).Msg("newHeads subscription ended, resubscribing")
+
+			time.Sleep(backoff)
+			backoff = min(2*backoff, maxResubscribeBackoff)
+		}
+	}()
+}
+
+// followHeads runs a single newHeads subscription until it fails or no header
+// arrives within timeout. It reports whether any header was received.
+func followHeads(node *nodeClient, timeout time.Duration, cache *healthCache) (bool, error) {
+	client, err := node.get()
+	if err != nil {
+		return false, err
+	}
+
+	headers := make(chan *types.Header, 16)
+	ctx, cancel := context.WithTimeout(context.Background(), settings().GetDuration("check-timeout"))
+	sub, err := client.SubscribeNewHead(ctx, headers)
+	cancel()
+	if err != nil {
+		node.reset()
+		return false, err
+	}
+	defer sub.Unsubscribe()
+
+	received := false
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case err := <-sub.Err():
+			node.reset()
+			return received, err
+		case header := <-headers:
+			received = true
+			node.stream.update(header)
+			timer.Reset(timeout)
+			if cache != nil {
+				cache.set(checkHealth(context.Background(), node))
+			}
+		case <-timer.C:
+			return received, fmt.Errorf("no new head within %s", timeout)
+		}
+	}
+}
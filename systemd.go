@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// lastPoll is when a background poller last finished an evaluation, in Unix
+// nanoseconds, so the systemd watchdog only passes while polling progresses
+var lastPoll atomic.Int64
+
+// sdNotify sends state to the systemd notification socket. It does nothing
+// when medic was not started by systemd with Type=notify.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// notifySystemd sends state to systemd, logging failures
+func notifySystemd(state string) {
+	if err := sdNotify(state); err != nil {
+		log.Warn().Err(err).Msg("Failed to notify systemd")
+	}
+}
+
+// notifyStatus reports the outcome of an evaluation as the systemd status
+func notifyStatus(result HealthResult) {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return
+	}
+
+	status := "unhealthy"
+	if result.Healthy {
+		status = "healthy"
+	}
+	if _, ok := result.Checks["block_delta"]; ok {
+		status += fmt.Sprintf(", delta=%ds", result.intValue("block_delta"))
+	}
+	if check, ok := result.Checks["peers"]; ok && !check.Skipped {
+		status += fmt.Sprintf(" peers=%d", result.intValue("peers"))
+	}
+	if len(result.Reasons) != 0 {
+		status += fmt.Sprintf(" reasons=%v", result.Reasons)
+	}
+	notifySystemd("STATUS=" + status)
+}
+
+// startWatchdog pings the systemd watchdog at half its timeout while the
+// pollers keep producing results, so systemd restarts a wedged medic. The
+// returned function stops the pings.
+func startWatchdog() (stop func()) {
+	stop = func() {}
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return stop
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return stop
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return stop
+	}
+
+	timeout := time.Duration(usec) * time.Microsecond
+	log.Info().Dur("watchdog_timeout", timeout).Msg("Pinging the systemd watchdog")
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if pollerStalled() {
+				log.Warn().Msg("Pollers stalled, withholding the systemd watchdog ping")
+				continue
+			}
+			notifySystemd("WATCHDOG=1")
+		}
+	}()
+	return cancel
+}
+
+// pollerStalled reports whether no poller finished an evaluation within three
+// poll intervals, the same bound that marks cached results stale
+func pollerStalled() bool {
+	interval := settings().GetDuration("poll-interval")
+	last := lastPoll.Load()
+	if interval <= 0 || last == 0 {
+		return false
+	}
+	return time.Since(time.Unix(0, last)) > 3*interval
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/health"
+	"github.com/spf13/viper"
+)
+
+// listenNotify listens on a notification socket at name and points
+// NOTIFY_SOCKET at it
+func listenNotify(t *testing.T, name string) *net.UnixConn {
+	t.Helper()
+	addr := name
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", name)
+	return conn
+}
+
+// socketPath returns a path for a notification socket, short enough for the
+// limit on unix socket paths
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "medic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "notify")
+}
+
+// receive returns the next notification, or false when none arrives within
+// timeout
+func receive(t *testing.T, conn *net.UnixConn, timeout time.Duration) (string, bool) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "", false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n]), true
+}
+
+func TestSdNotify(t *testing.T) {
+	for _, name := range []string{socketPath(t), "@medic-test-" + strconv.Itoa(os.Getpid())} {
+		t.Run(name, func(t *testing.T) {
+			conn := listenNotify(t, name)
+			if err := sdNotify("READY=1"); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := receive(t, conn, time.Second); got != "READY=1" {
+				t.Errorf("notification = %q, want READY=1", got)
+			}
+		})
+	}
+}
+
+func TestSdNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Errorf("sdNotify() without a socket = %v", err)
+	}
+	t.Setenv("NOTIFY_SOCKET", socketPath(t))
+	if err := sdNotify("READY=1"); err == nil {
+		t.Error("sdNotify() to a missing socket succeeded")
+	}
+}
+
+func TestNotifyStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		report health.Report
+		want   string
+	}{
+		{
+			name: "healthy",
+			report: health.Report{Healthy: true, Checks: map[string]health.Result{
+				"block_delta": {OK: true, Value: 3},
+				"peers":       {OK: true, Value: 25},
+			}},
+			want: "STATUS=healthy, delta=3s peers=25",
+		},
+		{
+			name: "unhealthy",
+			report: health.Report{Reasons: []string{"block_delta_exceeded"}, Checks: map[string]health.Result{
+				"block_delta": {Value: 95, Reason: "block_delta_exceeded"},
+				"peers":       {OK: true, Skipped: true, Reason: "min_peers_zero"},
+			}},
+			want: "STATUS=unhealthy, delta=95s reasons=[block_delta_exceeded]",
+		},
+		{name: "no checks", report: health.Report{Reasons: []string{"draining"}}, want: "STATUS=unhealthy reasons=[draining]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := listenNotify(t, socketPath(t))
+			notifyStatus(HealthResult{Report: tt.report})
+			if got, _ := receive(t, conn, time.Second); got != tt.want {
+				t.Errorf("notification = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// The watchdog is pinged at half its timeout, but only while the poller
+// makes progress
+func TestWatchdog(t *testing.T) {
+	interval := viper.GetDuration("poll-interval")
+	t.Cleanup(func() { viper.Set("poll-interval", interval) })
+	viper.Set("poll-interval", time.Hour)
+	previous := lastPoll.Load()
+	t.Cleanup(func() { lastPoll.Store(previous) })
+	lastPoll.Store(time.Now().Add(-4 * time.Hour).UnixNano())
+
+	conn := listenNotify(t, socketPath(t))
+	t.Setenv("WATCHDOG_USEC", "100000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	defer startWatchdog()()
+
+	if got, ok := receive(t, conn, 300*time.Millisecond); ok {
+		t.Errorf("stalled poller pinged the watchdog with %q", got)
+	}
+
+	lastPoll.Store(time.Now().UnixNano())
+	start := time.Now()
+	if got, _ := receive(t, conn, time.Second); got != "WATCHDOG=1" {
+		t.Fatalf("notification = %q, want WATCHDOG=1", got)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("first ping after %s, want about half the timeout", elapsed)
+	}
+}
+
+// Another process is watched, e.g. when medic runs as a child of the unit
+func TestWatchdogOtherPID(t *testing.T) {
+	conn := listenNotify(t, socketPath(t))
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	defer startWatchdog()()
+
+	if got, ok := receive(t, conn, 100*time.Millisecond); ok {
+		t.Errorf("watchdog of another process pinged with %q", got)
+	}
+}
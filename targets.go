@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// targetNamePattern restricts target names to what fits in a URL path and a
+// metric label
+var targetNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// targetSpec is a named node parsed from the target flag
+type targetSpec struct {
+	Name string
+	URL  string
+}
+
+// targetNodes are the nodes watched when targets are configured, in flag
+// order. eth-url is not watched in that case.
+var targetNodes []*nodeClient
+
+// parseTargets parses the repeatable target flag of the form name=url
+func parseTargets(v *viper.Viper) ([]targetSpec, error) {
+	var specs []targetSpec
+	seen := map[string]bool{}
+
+	for _, value := range v.GetStringSlice("target") {
+		name, url, ok := strings.Cut(value, "=")
+		name, url = strings.TrimSpace(name), strings.TrimSpace(url)
+		if !ok || url == "" {
+			return nil, fmt.Errorf("invalid target %q, expected name=url", name)
+		}
+		if !targetNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid target name %q, expected letters, digits, '.', '_' or '-'", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate target name %q", name)
+		}
+		seen[name] = true
+		specs = append(specs, targetSpec{Name: name, URL: url})
+	}
+
+	return specs, nil
+}
+
+// startTargets creates a node for every target and starts its client
+// detection, poller and head subscription
+func startTargets(specs []targetSpec) {
+	for _, spec := range specs {
+		node := newNodeClient(spec.Name, spec.URL)
+		if clientType := settings().GetString("client-type"); clientType != "" {
+			canonical, _ := clients.ClientType(clientType)
+			node.forceClientType(canonical)
+		}
+		targetNodes = append(targetNodes, node)
+	}
+
+	// Thresholds are shared, so the chain default comes from the first target
+	resolveChainDefaults(context.Background(), targetNodes[0])
+	if settings().GetString("cl-url") != "" {
+		beaconClient.start(settings().GetDuration("client-detect-interval"))
+	}
+
+	for _, node := range targetNodes {
+		node.logger().Info().Msg("Watching target")
+		node.startClientDetection(settings().GetDuration("client-detect-interval"))
+
+		var cache *healthCache
+		if interval := settings().GetDuration("poll-interval"); interval > 0 {
+			cache = node.cache
+			startPoller(node, interval, cache)
+		}
+		if settings().GetBool("subscribe") && clients.IsPersistent(node.url) {
+			startHeadSubscription(node, settings().GetDuration("subscription-timeout"), cache)
+		}
+	}
+}
+
+// targetByName returns the target with the given name, or nil
+func targetByName(name string) *nodeClient {
+	for _, node := range targetNodes {
+		if node.name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// nodeResult returns the cached result of node, or evaluates it on demand
+// when polling is disabled
+func nodeResult(ctx context.Context, node *nodeClient) HealthResult {
+	if interval := settings().GetDuration("poll-interval"); interval > 0 {
+		return cachedHealth(node.cache, interval)
+	}
+	return probeHealth(ctx, node)
+}
+
+// TargetsResult is the aggregate readiness of all targets
+type TargetsResult struct {
+	Healthy        bool                    `json:"healthy"`
+	Quorum         int                     `json:"quorum"`
+	HealthyTargets int                     `json:"healthy_targets"`
+	Targets        map[string]HealthResult `json:"targets"`
+}
+
+// targetsHealth evaluates every target concurrently and applies the quorum
+func targetsHealth(ctx context.Context) TargetsResult {
+	results := make([]HealthResult, len(targetNodes))
+
+	var wg sync.WaitGroup
+	for i, node := range targetNodes {
+		wg.Add(1)
+		go func(i int, node *nodeClient) {
+			defer wg.Done()
+			results[i] = nodeResult(ctx, node)
+		}(i, node)
+	}
+	wg.Wait()
+
+	aggregate := TargetsResult{
+		Quorum:  settings().GetInt("quorum"),
+		Targets: make(map[string]HealthResult, len(targetNodes)),
+	}
+	// Forced targets count towards the quorum but not the healthy targets
+	ready := 0
+	for i, node := range targetNodes {
+		result, ok := forcedReady(node.logger(), results[i])
+		aggregate.Targets[node.name] = result
+		if results[i].Healthy {
+			aggregate.HealthyTargets++
+		}
+		if ok {
+			ready++
+		}
+	}
+	aggregate.Healthy = ready >= aggregate.Quorum
+	healthyTargetsGauge.Set(float64(aggregate.HealthyTargets))
+
+	return aggregate
+}
+
+// targetsReadinessHandler serves the aggregate /ready, which passes while at
+// least quorum targets are healthy
+func targetsReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if result, ok := forcedUnready(); ok {
+		setHealthHeaders(w, result)
+		writeJSON(w, http.StatusServiceUnavailable, result)
+		return
+	}
+
+	aggregate := targetsHealth(r.Context())
+	setTargetsHeaders(w, aggregate)
+	if aggregate.Healthy {
+		writeJSON(w, http.StatusOK, aggregate)
+	} else {
+		log.Warn().
+			Int("healthy_targets", aggregate.HealthyTargets).
+			Int("quorum", aggregate.Quorum).
+			Msg("Fewer targets than the quorum are healthy")
+		writeJSON(w, http.StatusServiceUnavailable, aggregate)
+	}
+}
+
+// targetReadinessHandler serves /ready/{name} for a single target
+func targetReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	node := targetByName(strings.TrimPrefix(r.URL.Path, "/ready/"))
+	if node == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if result, ok := forcedUnready(); ok {
+		setHealthHeaders(w, result)
+		writeJSON(w, http.StatusServiceUnavailable, result)
+		return
+	}
+
+	result, ready := forcedReady(node.logger(), nodeResult(r.Context(), node))
+	setHealthHeaders(w, result)
+	if ready {
+		writeJSON(w, http.StatusOK, result)
+	} else {
+		node.logger().Warn().Msg("Node is not healthy")
+		writeJSON(w, http.StatusServiceUnavailable, result)
+	}
+}
+
+// targetsStatusHandler serves /status with the status of every target
+func targetsStatusHandler(w http.ResponseWriter, r *http.Request) {
+	n, ok := historyLength(w, r)
+	if !ok {
+		return
+	}
+
+	statuses := make(map[string]StatusResponse, len(targetNodes))
+	for _, node := range targetNodes {
+		statuses[node.name] = nodeStatus(r.Context(), node, n)
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// targetsLivenessHandler serves /live for targets. Restarting medic does not
+// help a node that is down, so only medic itself is checked.
+func targetsLivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
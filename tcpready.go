@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// tcpReadyInterval is how often readiness is checked when there is no
+// background poller to follow
+const tcpReadyInterval = 5 * time.Second
+
+// tcpReadyListener accepts TCP connections on tcp-ready-addr only while /ready
+// would pass, for load balancers that can only check that a port accepts
+// connections. The listener is closed while the node is not ready, so
+// connection attempts are refused.
+type tcpReadyListener struct {
+	addr   string
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// startTCPReady starts following readiness when tcp-ready-addr is set,
+// returning nil otherwise
+func startTCPReady() *tcpReadyListener {
+	addr := settings().GetString("tcp-ready-addr")
+	if addr == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		log.Fatal().Err(err).Str("tcp_ready_addr", addr).Msg("Invalid TCP readiness address")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &tcpReadyListener{addr: addr, cancel: cancel}
+	go t.follow(ctx)
+	return t
+}
+
+// follow opens or closes the listener every poll interval to match readiness
+func (t *tcpReadyListener) follow(ctx context.Context) {
+	interval := settings().GetDuration("poll-interval")
+	if interval <= 0 {
+		interval = tcpReadyInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ready, _ := currentReadiness(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if ready {
+			t.open()
+		} else {
+			t.close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// open starts listening unless the listener is already open
+func (t *tcpReadyListener) open() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.listener != nil {
+		return
+	}
+
+	listener, err := net.Listen("tcp", t.addr)
+	if err != nil {
+		log.Error().Err(err).Str("tcp_ready_addr", t.addr).Msg("Failed to open the TCP readiness listener")
+		return
+	}
+	t.listener = listener
+	log.Info().Str("tcp_ready_addr", listener.Addr().String()).Msg("Node is ready, accepting TCP readiness checks")
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to accept a TCP readiness check")
+				continue
+			}
+			// A completed handshake is the whole check
+			conn.Close()
+		}
+	}()
+}
+
+// close stops listening so connection attempts are refused
+func (t *tcpReadyListener) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.listener == nil {
+		return
+	}
+
+	if err := t.listener.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close the TCP readiness listener")
+	}
+	t.listener = nil
+	log.Warn().Str("tcp_ready_addr", t.addr).Msg("Node is not ready, refusing TCP readiness checks")
+}
+
+// drain closes the listener for good, as /ready fails while draining
+func (t *tcpReadyListener) drain() {
+	t.cancel()
+	t.close()
+}
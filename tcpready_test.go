@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/health"
+)
+
+// freeAddr returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+// awaitConnect waits until connecting to addr succeeds or fails as wanted
+func awaitConnect(t *testing.T, addr string, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err == nil {
+			conn.Close()
+		}
+		if (err == nil) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("connect to %s succeeded %v, want %v", addr, err == nil, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// The listener follows the cached health within a poll interval and opens
+// again on the same address when the node recovers
+func TestTCPReadyFollowsHealth(t *testing.T) {
+	addr := freeAddr(t)
+	useSettings(t, map[string]interface{}{
+		"tcp-ready-addr": addr,
+		"poll-interval":  "50ms",
+	})
+
+	node := ethNode
+	ethNode = newNodeClient("", refusingURL())
+	t.Cleanup(func() { ethNode = node })
+	setHealthy := func(healthy bool) {
+		ethNode.cache.set(HealthResult{Report: health.Report{Healthy: healthy}})
+	}
+
+	setHealthy(false)
+	listener := startTCPReady()
+	defer listener.drain()
+	awaitConnect(t, addr, false)
+
+	for i := 0; i < 2; i++ {
+		setHealthy(true)
+		awaitConnect(t, addr, true)
+		setHealthy(false)
+		awaitConnect(t, addr, false)
+	}
+
+	setHealthy(true)
+	awaitConnect(t, addr, true)
+	listener.drain()
+	awaitConnect(t, addr, false)
+}
+
+func TestStartTCPReadyDisabled(t *testing.T) {
+	useSettings(t, nil)
+	if listener := startTCPReady(); listener != nil {
+		listener.drain()
+		t.Error("listener started without tcp-ready-addr")
+	}
+}
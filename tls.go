@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// rpcTLSConfig builds the TLS config of connections to the node from the rpc
+// TLS flags, returning nil when none is set
+func rpcTLSConfig(v *viper.Viper) (*tls.Config, error) {
+	caFile, certFile := v.GetString("rpc-ca-file"), v.GetString("rpc-client-cert")
+	insecure := v.GetBool("rpc-insecure-skip-verify")
+	if caFile == "" && certFile == "" && !insecure {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+	if caFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the RPC CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no PEM certificates found in " + caFile)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, v.GetString("rpc-client-key"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the RPC client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// serverTLS holds the certificate and client CAs of the probe server. They
+// are reloaded when the files change, so rotated certificates are picked up
+// by new connections without closing the listener.
+type serverTLS struct {
+	certFile, keyFile, clientCAFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+
+	// files holds the contents the certificate and client CAs were loaded
+	// from, so unrelated changes in the same directories are ignored
+	files []byte
+}
+
+// newServerTLS loads the tls-cert, tls-key and tls-client-ca files, returning
+// nil when TLS is not configured
+func newServerTLS() (*serverTLS, error) {
+	if settings().GetString("tls-cert") == "" {
+		return nil, nil
+	}
+
+	s := &serverTLS{
+		certFile:     settings().GetString("tls-cert"),
+		keyFile:      settings().GetString("tls-key"),
+		clientCAFile: settings().GetString("tls-client-ca"),
+	}
+	if _, err := s.load(); err != nil {
+		return nil, err
+	}
+
+	paths := []string{s.certFile, s.keyFile}
+	if s.clientCAFile != "" {
+		paths = append(paths, s.clientCAFile)
+	}
+	if err := watchFiles(paths, s.reload); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the files, replacing the certificate and client CAs only when
+// every file is valid. It reports whether any of the files changed.
+func (s *serverTLS) load() (bool, error) {
+	certPEM, err := os.ReadFile(s.certFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(s.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load the TLS key: %w", err)
+	}
+	var caPEM []byte
+	if s.clientCAFile != "" {
+		if caPEM, err = os.ReadFile(s.clientCAFile); err != nil {
+			return false, fmt.Errorf("failed to load the TLS client CA: %w", err)
+		}
+	}
+
+	files := bytes.Join([][]byte{certPEM, keyPEM, caPEM}, nil)
+	s.mu.RLock()
+	unchanged := bytes.Equal(files, s.files)
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if caPEM != nil {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return false, errors.New("failed to load the TLS client CA: no PEM certificates found in " + s.clientCAFile)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cert, s.clientCAs, s.files = &cert, clientCAs, files
+	return true, nil
+}
+
+// reload loads the files again after a change, keeping the current ones when
+// the new files are invalid, e.g. while only half of them were written
+func (s *serverTLS) reload() {
+	changed, err := s.load()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to reload the TLS files, keeping the current certificate")
+		return
+	}
+	if changed {
+		log.Info().Str("tls_cert", s.certFile).Msg("Reloaded the TLS certificate")
+	}
+}
+
+// config returns the TLS config of the probe server, which reads the current
+// certificate and client CAs for every handshake
+func (s *serverTLS) config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*s.cert},
+			}
+			if s.clientCAs != nil {
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				config.ClientCAs = s.clientCAs
+			}
+			return config, nil
+		},
+	}
+}
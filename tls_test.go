@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rarecrumb/medic/clients"
+	"github.com/spf13/viper"
+)
+
+// stubNode answers JSON-RPC calls of the methods in results, singly or in a
+// batch, and fails every other method like a node with the namespace
+// disabled
+func stubNode(results map[string]interface{}) http.Handler {
+	type request struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	answer := func(req request) map[string]interface{} {
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if result, ok := results[req.Method]; ok {
+			resp["result"] = result
+		} else {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "the method " + req.Method + " does not exist/is not available"}
+		}
+		return resp
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		var batch []request
+		if json.Unmarshal(body, &batch) == nil {
+			answers := make([]map[string]interface{}, len(batch))
+			for i, req := range batch {
+				answers[i] = answer(req)
+			}
+			json.NewEncoder(w).Encode(answers)
+			return
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(answer(req))
+	})
+}
+
+// gethStub answers the calls the TLS and proxy tests make like Geth
+var gethStub = map[string]interface{}{
+	"web3_clientVersion": "Geth/v1.14.0-stable/linux-amd64/go1.22.0",
+	"eth_chainId":        "0x1",
+}
+
+// writeCA writes cert to a PEM file in the test directory
+func writeCA(t *testing.T, cert []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// untrustedCA returns a self-signed CA that signed nothing the node serves
+func untrustedCA(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "untrusted test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// Connections to a node behind TLS are verified against rpc-ca-file by the
+// startup wait, the clients package and the ethclient connection alike
+func TestRPCTLS(t *testing.T) {
+	node := httptest.NewTLSServer(stubNode(gethStub))
+	defer node.Close()
+
+	tests := []struct {
+		name     string
+		settings func(t *testing.T) map[string]interface{}
+		ok       bool
+	}{
+		{
+			name: "custom CA",
+			settings: func(t *testing.T) map[string]interface{} {
+				return map[string]interface{}{"rpc-ca-file": writeCA(t, node.Certificate().Raw)}
+			},
+			ok: true,
+		},
+		{
+			name:     "system CAs",
+			settings: func(t *testing.T) map[string]interface{} { return map[string]interface{}{} },
+		},
+		{
+			name: "untrusted CA",
+			settings: func(t *testing.T) map[string]interface{} {
+				return map[string]interface{}{"rpc-ca-file": writeCA(t, untrustedCA(t))}
+			},
+		},
+		{
+			name: "insecure skip verify",
+			settings: func(t *testing.T) map[string]interface{} {
+				return map[string]interface{}{"rpc-insecure-skip-verify": true}
+			},
+			ok: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryMax := viper.GetInt("retry-max")
+			viper.Set("retry-max", 0)
+			t.Cleanup(func() { viper.Set("retry-max", retryMax) })
+
+			v := viper.New()
+			if err := v.MergeConfigMap(tt.settings(t)); err != nil {
+				t.Fatal(err)
+			}
+			tlsConfig, err := rpcTLSConfig(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			retryClient := newRetryClient(nil, tlsConfig, nil)
+			previous := rpcOptions
+			rpcOptions = &clients.Options{HTTPClient: retryClient.StandardClient(), TLSConfig: tlsConfig}
+			t.Cleanup(func() { rpcOptions = previous })
+
+			ctx, cancel := context.WithTimeout(rpcContext(context.Background()), 5*time.Second)
+			defer cancel()
+			checkTLS := func(what string, err error) {
+				t.Helper()
+				if tt.ok && err != nil {
+					t.Errorf("%s error = %v", what, err)
+				}
+				if !tt.ok && (err == nil || !strings.Contains(err.Error(), "certificate")) {
+					t.Errorf("%s error = %v, want a certificate error", what, err)
+				}
+			}
+
+			checkTLS("waitForNode()", waitForNode(ctx, retryClient, node.URL))
+			_, err = clients.ClientVersion(ctx, node.URL)
+			checkTLS("ClientVersion()", err)
+			client, err := newNodeClient("", node.URL).get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.ChainID(ctx)
+			checkTLS("ChainID()", err)
+		})
+	}
+}
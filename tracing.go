@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer starts the spans of health evaluations. It does nothing until
+// setupTracing installs an exporter.
+var tracer = otel.Tracer("github.com/rarecrumb/medic")
+
+// shutdownTracing flushes the spans that have not been exported yet
+var shutdownTracing = func(context.Context) error { return nil }
+
+// setupTracing exports spans to the OTLP/HTTP endpoint given by otel-endpoint,
+// sampling otel-sample-ratio of the evaluations. Without an endpoint the
+// global tracer provider stays a no-op.
+func setupTracing(endpoint string, ratio float64) error {
+	if endpoint == "" {
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("medic"),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	shutdownTracing = provider.Shutdown
+	return nil
+}
+
+// flushTraces exports the pending spans before medic exits
+func flushTraces() {
+	ctx, cancel := context.WithTimeout(context.Background(), settings().GetDuration("shutdown-timeout"))
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to export the remaining traces")
+	}
+}
+
+// startEvaluation starts the root span of one health evaluation of node
+func startEvaluation(ctx context.Context, node *nodeClient) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "medic.evaluate", trace.WithAttributes(
+		attribute.String("medic.target", node.name),
+		attribute.String("medic.client_type", node.clientInfo().Type),
+	))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Build information, set with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// startTime is when medic started, for the uptime reported by /version
+var startTime = time.Now()
+
+// versionInfo describes the running build
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// buildInfo returns the build information set with ldflags, falling back to
+// what the Go toolchain embedded for builds without them
+func buildInfo() versionInfo {
+	info := versionInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	for _, setting := range build.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.BuildDate == "":
+			info.BuildDate = setting.Value
+		}
+	}
+	return info
+}
+
+// labels returns the build information as the labels of medic_build_info
+func (v versionInfo) labels() prometheus.Labels {
+	return prometheus.Labels{
+		"version":    v.Version,
+		"commit":     v.Commit,
+		"build_date": v.BuildDate,
+		"go_version": v.GoVersion,
+	}
+}
+
+// runtimeInfo is the response of /version
+type runtimeInfo struct {
+	versionInfo
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	// EthURL is the configured eth-url with its credentials and path
+	// redacted, since either may hold an API key
+	EthURL string `json:"eth_url"`
+}
+
+// versionHandler serves the build information, uptime and node of medic
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, runtimeInfo{
+		versionInfo:   buildInfo(),
+		StartedAt:     startTime.UTC(),
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+		EthURL:        redactURL(settings().GetString("eth-url")),
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rarecrumb/medic/clients"
+	"github.com/rarecrumb/medic/health"
+	"github.com/rs/zerolog/log"
+)
+
+var wsHealthyGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "medic_ws_healthy",
+	Help: "Whether the WebSocket endpoint of ws-url answered and delivered new heads (1) or not (0)",
+})
+
+// wsHeadWatch follows a newHeads subscription on ws-url, so the check can
+// tell whether headers still arrive
+type wsHeadWatch struct {
+	mu sync.Mutex
+	// since is when the last header arrived, or the first subscription
+	// started. Resubscribing does not move it, so a silent endpoint fails.
+	since   time.Time
+	lastErr error
+}
+
+// wsHeads is the subscription started when ws-head-timeout is set
+var wsHeads = &wsHeadWatch{}
+
+// startWSHeads subscribes to newHeads on url, resubscribing with backoff when
+// the subscription fails or stays silent for timeout
+func startWSHeads(url string, timeout time.Duration) {
+	log.Info().Dur("ws_head_timeout", timeout).Msg("Subscribing to newHeads on the WebSocket endpoint")
+
+	go func() {
+		backoff := time.Second
+		for {
+			received, err := wsHeads.follow(url, timeout)
+			if received {
+				backoff = time.Second
+			}
+			wsHeads.end(err)
+			log.Warn().Err(err).Dur("retry_in", backoff).Msg("WebSocket newHeads subscription ended, resubscribing")
+
+			time.Sleep(backoff)
+			backoff = min(2*backoff, maxResubscribeBackoff)
+		}
+	}()
+}
+
+// follow runs a single subscription until it fails or no header arrives
+// within timeout. It reports whether any header was received.
+func (w *wsHeadWatch) follow(url string, timeout time.Duration) (bool, error) {
+	headers := make(chan *types.Header, 16)
+	rpcCtx := rpcContext(context.Background())
+	ctx, cancel := context.WithTimeout(rpcCtx, settings().GetDuration("check-timeout"))
+	sub, err := clients.SubscribeNewHeads(ctx, url, headers)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	defer sub.Unsubscribe()
+	w.subscribed()
+
+	received := false
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case err := <-sub.Err():
+			clients.DropConnection(rpcCtx, url, err)
+			return received, err
+		case <-headers:
+			received = true
+			w.received()
+			timer.Reset(timeout)
+		case <-timer.C:
+			return received, fmt.Errorf("no new head within %s", timeout)
+		}
+	}
+}
+
+// subscribed starts the timeout when the first subscription is established
+func (w *wsHeadWatch) subscribed() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.since.IsZero() {
+		w.since = time.Now()
+	}
+}
+
+// received records that a header arrived
+func (w *wsHeadWatch) received() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.since, w.lastErr = time.Now(), nil
+}
+
+// end records why the subscription ended
+func (w *wsHeadWatch) end(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastErr = err
+}
+
+// err returns why no header arrived within timeout, or nil while headers
+// keep arriving
+func (w *wsHeadWatch) err(timeout time.Duration) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case w.since.IsZero() && w.lastErr != nil:
+		return fmt.Errorf("newHeads subscription failed: %w", w.lastErr)
+	case w.since.IsZero():
+		return errors.New("newHeads subscription not established")
+	case time.Since(w.since) <= timeout:
+		return nil
+	}
+	return fmt.Errorf("no new head within %s", timeout)
+}
+
+// measureWS calls eth_chainId over the WebSocket endpoint at url, reusing the
+// persistent connection across polls
+func measureWS(ctx context.Context, url string) *health.WebSocket {
+	m := &health.WebSocket{}
+
+	start := time.Now()
+	m.ChainID, m.Err = clients.ChainID(ctx, url)
+	observeRPC(ctx, "ws_eth_chainId", start)
+	if m.Err != nil {
+		log.Error().Err(clients.WithoutURL(m.Err)).Msg("Failed to call the WebSocket endpoint")
+	}
+
+	if timeout := settings().GetDuration("ws-head-timeout"); timeout > 0 {
+		m.HeadsErr = wsHeads.err(timeout)
+	}
+	return m
+}